@@ -65,9 +65,130 @@ const docTemplate = `{
                 }
             }
         },
+        "/notifications/export": {
+            "get": {
+                "description": "Returns a file with all the notifications of the user. JSON and CSV files have one row per schedule, with the fields of the notification requests, so they can be imported again. ICS files can be opened by calendar apps: each notification is a recurring event with an alarm. Completed notifications are left out",
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "text/calendar"
+                ],
+                "tags": [
+                    "Notification"
+                ],
+                "summary": "Exports the notifications of the user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "jwt data, must contain the email of the user",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "format of the file: json, csv or ics. Default: json",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/import": {
+            "post": {
+                "description": "Schedules the notifications of a JSON or CSV file, like the ones exported. Each row is validated and scheduled on its own, like a domain.NotificationRequest: the rows with errors are reported, the ones whose notifications already exist are skipped and the rest are imported. At most 500 rows can be imported at once",
+                "consumes": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notification"
+                ],
+                "summary": "Imports notifications of the user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "jwt data, must contain the email of the user",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "format of the file: json or csv. Default: csv if the content type is text/csv, otherwise json",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "description": "file with the notifications",
+                        "name": "file",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.ImportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/notifications/notification": {
             "get": {
-                "description": "Returns all the notifications of the given user",
+                "description": "Returns a page of the notifications of the given user that match the filters, sorted by hour and then by ID. To get the next page, send the next_cursor of the metadata as cursor",
                 "consumes": [
                     "application/json"
                 ],
@@ -77,7 +198,7 @@ const docTemplate = `{
                 "tags": [
                     "Notification"
                 ],
-                "summary": "Search all notifications by user email",
+                "summary": "Search the notifications of the user",
                 "parameters": [
                     {
                         "type": "string",
@@ -85,16 +206,67 @@ const docTemplate = `{
                         "name": "Authorization",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "only notifications sent by this service: telegram, mail, both or webhook",
+                        "name": "via",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only active or expired notifications",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only notifications sent from this hour, HH:MM",
+                        "name": "hour_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only notifications sent until this hour, HH:MM",
+                        "name": "hour_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only notifications whose message contains this text",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only notifications active from this date, RFC 3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only notifications active until this date, RFC 3339",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "cursor returned by the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "notifications per page, from 1 to 100. Default: 20",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/domain.NotificationResponse"
-                            }
+                            "$ref": "#/definitions/domain.NotificationListResponse"
                         }
                     },
                     "400": {
@@ -118,7 +290,7 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Receives a domain.NotificationRequest, performs validations and if it's all OK then one notification per each specified hour is saved.",
+                "description": "Receives a domain.NotificationRequest, performs validations and if it's all OK then one notification per each specified hour is saved. All of them belong to the same schedule, that can be managed as a whole.",
                 "consumes": [
                     "application/json"
                 ],
@@ -174,7 +346,7 @@ const docTemplate = `{
         },
         "/notifications/notification/{notificationID}": {
             "get": {
-                "description": "Fetches notification by ID",
+                "description": "Fetches notification by ID. The ETag header holds its version, to be sent in the If-Match header of updates and deletions",
                 "consumes": [
                     "application/json"
                 ],
@@ -206,6 +378,12 @@ const docTemplate = `{
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/domain.NotificationResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "version of the notification"
+                            }
                         }
                     },
                     "400": {
@@ -229,7 +407,7 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "If exists, deletes the notification with the given notificationID. This action is triggered by the users, if a notification reaches the end date nothing happens",
+                "description": "If exists, deletes the notification with the given notificationID. This action is triggered by the users. Deleted notifications can be restored during the grace period. Notifications that reach their end date are completed by the sweeper and deleted after the retention period",
                 "consumes": [
                     "application/json"
                 ],
@@ -248,6 +426,12 @@ const docTemplate = `{
                         "in": "header",
                         "required": true
                     },
+                    {
+                        "type": "string",
+                        "description": "ETag returned when the notification was fetched",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
                     {
                         "type": "string",
                         "description": "id of the notification",
@@ -277,11 +461,23 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
                     }
                 }
             },
             "patch": {
-                "description": "Updates attributes of certain notification. The attributes that can be updated are: message and end date",
+                "description": "Updates attributes of certain notification. The attributes that can be updated are: message and end date. If the If-Match header is given, the notification is only updated if its ETag matches",
                 "consumes": [
                     "application/json"
                 ],
@@ -300,6 +496,12 @@ const docTemplate = `{
                         "in": "header",
                         "required": true
                     },
+                    {
+                        "type": "string",
+                        "description": "ETag returned when the notification was fetched",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
                     {
                         "type": "string",
                         "description": "id of the notification",
@@ -338,13 +540,25 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/notifications/trigger": {
-            "post": {
-                "description": "Sends notifications to all users that have scheduled one for the hour of this request",
+        "/notifications/notification/{notificationID}/history": {
+            "get": {
+                "description": "Fetches every change made to the notification, oldest first: who made it, when, and the notification before and after it. The history is kept after the notification is deleted",
                 "consumes": [
                     "application/json"
                 ],
@@ -354,7 +568,7 @@ const docTemplate = `{
                 "tags": [
                     "Notification"
                 ],
-                "summary": "sends notifications",
+                "summary": "Fetches the history of a notification",
                 "parameters": [
                     {
                         "type": "string",
@@ -362,14 +576,24 @@ const docTemplate = `{
                         "name": "Authorization",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "id of the notification",
+                        "name": "notificationID",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
-                    },
-                    "204": {
-                        "description": "No Content"
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.AuditEntryResponse"
+                            }
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -377,6 +601,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
@@ -385,90 +615,921 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "domain.NotificationRequest": {
-            "type": "object",
-            "required": [
-                "hours",
-                "message",
-                "start_date",
-                "via"
-            ],
-            "properties": {
-                "email": {
-                    "type": "string"
-                },
-                "end_date": {
-                    "type": "string"
-                },
-                "hours": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
-                },
-                "message": {
-                    "type": "string"
-                },
-                "start_date": {
-                    "type": "string"
-                },
-                "telegram_id": {
-                    "type": "string"
-                },
-                "via": {
-                    "$ref": "#/definitions/domain.Via"
-                }
-            }
-        },
-        "domain.NotificationResponse": {
-            "type": "object",
-            "properties": {
-                "end_date": {
-                    "type": "string"
-                },
-                "hour": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "message": {
-                    "type": "string"
-                },
-                "start_date": {
-                    "type": "string"
-                },
-                "via": {
-                    "$ref": "#/definitions/domain.Via"
-                }
-            }
-        },
-        "domain.UpdateNotificationRequest": {
-            "type": "object",
-            "properties": {
-                "end_date": {
-                    "type": "string"
-                },
-                "message": {
-                    "type": "string"
-                }
-            }
-        },
-        "domain.Via": {
-            "type": "string",
-            "enum": [
-                "telegram",
-                "mail",
-                "both"
-            ],
-            "x-enum-varnames": [
-                "Telegram",
-                "Mail",
-                "Both"
-            ]
+        },
+        "/notifications/notification/{notificationID}/restore": {
+            "post": {
+                "description": "Undoes the deletion of the notification. It's only possible during the grace period after the deletion, then the notification is purged. The ETag header holds the version of the restored notification",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notification"
+                ],
+                "summary": "Restores a deleted notification",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "jwt data",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "id of the notification",
+                        "name": "notificationID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.NotificationResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "version of the restored notification"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/schedule/{scheduleID}": {
+            "get": {
+                "description": "Fetches all the notifications of the schedule, one per hour. The ETag header holds their versions, to be sent in the If-Match header of the changes of the schedule",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedule"
+                ],
+                "summary": "Fetches a schedule by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "jwt data",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "id of the schedule",
+                        "name": "scheduleID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.ScheduleResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "versions of the notifications of the schedule"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes all the notifications of the schedule. If the If-Match header is given, the schedule is only deleted if its ETag matches",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedule"
+                ],
+                "summary": "Deletes a schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "jwt data",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag returned when the schedule was fetched",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "id of the schedule",
+                        "name": "scheduleID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Updates attributes of all the notifications of the schedule. The attributes that can be updated are: message and end date. If the If-Match header is given, the schedule is only updated if its ETag matches",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedule"
+                ],
+                "summary": "Updates a schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "jwt data",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag returned when the schedule was fetched",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "id of the schedule",
+                        "name": "scheduleID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to update",
+                        "name": "UpdateRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.UpdateNotificationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/schedule/{scheduleID}/pause": {
+            "post": {
+                "description": "None of the notifications of the schedule is sent until it's resumed. Occurrences missed while paused are not sent later",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedule"
+                ],
+                "summary": "Pauses a schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "jwt data",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag returned when the schedule was fetched",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "id of the schedule",
+                        "name": "scheduleID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/schedule/{scheduleID}/resume": {
+            "post": {
+                "description": "The notifications of the schedule are sent again, starting from the next slot",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedule"
+                ],
+                "summary": "Resumes a schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "jwt data",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag returned when the schedule was fetched",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "id of the schedule",
+                        "name": "scheduleID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/templates/{name}/preview": {
+            "get": {
+                "description": "Renders the email template with sample data. Templates: reminder, for notifications sent on every occurrence, and one_shot. With format html or text only that version of the body is returned, so it can be opened in a browser",
+                "produces": [
+                    "application/json",
+                    "text/html",
+                    "text/plain"
+                ],
+                "tags": [
+                    "Mail"
+                ],
+                "summary": "Previews an email template",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "jwt data",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "name of the template",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "message of the sample notification",
+                        "name": "message",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "json, html or text. Default: json",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.EmailPreviewResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/trigger": {
+            "post": {
+                "description": "Manual override of the scheduler: enqueues the notifications of all users that have scheduled one for the current slot, catching up the slots missed since the last dispatch. The outbox sends them, retrying the failed ones. The scheduler already does this on every slot, so calling this endpoint is optional. Each slot is dispatched once: if it was already dispatched, by the scheduler or by another instance, nothing is enqueued and 204 is returned",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notification"
+                ],
+                "summary": "sends notifications",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "jwt data",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "notifications enqueued"
+                    },
+                    "204": {
+                        "description": "no notifications due, or slot already dispatched"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/webhook/secret": {
+            "post": {
+                "description": "Generates the secret used to sign the webhooks of the user, replacing the previous one. Each webhook has the headers X-Webhook-Timestamp, the unix time of the request, and X-Webhook-Signature: sha256= followed by the hex encoded HMAC-SHA256 of the timestamp, a dot and the body. The secret is only returned once",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhook"
+                ],
+                "summary": "Creates the webhook secret of the user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "jwt data, must contain the email of the user",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/domain.WebhookSecretResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes the secret used to sign the webhooks of the user. Webhooks are not sent until a new one is created",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhook"
+                ],
+                "summary": "Deletes the webhook secret of the user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "jwt data, must contain the email of the user",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "domain.AuditAction": {
+            "type": "string",
+            "enum": [
+                "created",
+                "updated",
+                "deleted",
+                "restored"
+            ],
+            "x-enum-varnames": [
+                "AuditCreated",
+                "AuditUpdated",
+                "AuditDeleted",
+                "AuditRestored"
+            ]
+        },
+        "domain.AuditEntryResponse": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "$ref": "#/definitions/domain.AuditAction"
+                },
+                "actor": {
+                    "type": "string"
+                },
+                "after": {
+                    "$ref": "#/definitions/domain.NotificationResponse"
+                },
+                "before": {
+                    "$ref": "#/definitions/domain.NotificationResponse"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.CatchUpPolicy": {
+            "type": "string",
+            "enum": [
+                "all",
+                "latest",
+                "skip"
+            ],
+            "x-enum-varnames": [
+                "CatchUpAll",
+                "CatchUpLatest",
+                "CatchUpSkip"
+            ]
+        },
+        "domain.EmailPreviewResponse": {
+            "type": "object",
+            "properties": {
+                "html": {
+                    "type": "string"
+                },
+                "subject": {
+                    "type": "string"
+                },
+                "template": {
+                    "type": "string"
+                },
+                "text": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.ImportResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "imported": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.ImportRowResult"
+                    }
+                },
+                "skipped": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.ImportRowResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "row": {
+                    "type": "integer"
+                },
+                "schedule_id": {
+                    "type": "string"
+                },
+                "skipped": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "domain.ListMetadata": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer"
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.NotificationListResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.NotificationResponse"
+                    }
+                },
+                "metadata": {
+                    "$ref": "#/definitions/domain.ListMetadata"
+                }
+            }
+        },
+        "domain.NotificationRequest": {
+            "type": "object",
+            "required": [
+                "message",
+                "via"
+            ],
+            "properties": {
+                "catch_up": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.CatchUpPolicy"
+                        }
+                    ],
+                    "example": "latest"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "end_date": {
+                    "type": "string"
+                },
+                "fire_at": {
+                    "type": "string"
+                },
+                "hours": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                },
+                "recurrence": {
+                    "type": "string",
+                    "example": "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU"
+                },
+                "start_date": {
+                    "type": "string"
+                },
+                "telegram_id": {
+                    "type": "string"
+                },
+                "time_zone": {
+                    "type": "string",
+                    "example": "America/Argentina/Buenos_Aires"
+                },
+                "via": {
+                    "$ref": "#/definitions/domain.Via"
+                },
+                "webhook_url": {
+                    "type": "string",
+                    "example": "https://clinic.example.com/reminders"
+                }
+            }
+        },
+        "domain.NotificationResponse": {
+            "type": "object",
+            "properties": {
+                "catch_up": {
+                    "$ref": "#/definitions/domain.CatchUpPolicy"
+                },
+                "completed_at": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "end_date": {
+                    "type": "string"
+                },
+                "fire_at": {
+                    "type": "string"
+                },
+                "hour": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "paused": {
+                    "type": "boolean"
+                },
+                "recurrence": {
+                    "type": "string"
+                },
+                "schedule_id": {
+                    "type": "string"
+                },
+                "start_date": {
+                    "type": "string"
+                },
+                "time_zone": {
+                    "type": "string"
+                },
+                "via": {
+                    "$ref": "#/definitions/domain.Via"
+                },
+                "webhook_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.ScheduleResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "notifications": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.NotificationResponse"
+                    }
+                },
+                "paused": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "domain.UpdateNotificationRequest": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.Via": {
+            "type": "string",
+            "enum": [
+                "telegram",
+                "mail",
+                "both",
+                "webhook"
+            ],
+            "x-enum-varnames": [
+                "Telegram",
+                "Mail",
+                "Both",
+                "Webhook"
+            ]
+        },
+        "domain.WebhookSecretResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "secret": {
+                    "type": "string"
+                }
+            }
         },
         "email.Mail": {
             "type": "object",
@@ -482,6 +1543,10 @@ const docTemplate = `{
                     "type": "string",
                     "example": "body of the mail"
                 },
+                "html": {
+                    "type": "string",
+                    "example": "\u003cp\u003ebody of the mail\u003c/p\u003e"
+                },
                 "subject": {
                     "type": "string",
                     "example": "testing subject"
@@ -0,0 +1,55 @@
+package domain
+
+import "time"
+
+// AuditAction change made to a notification
+type AuditAction string
+
+const (
+	AuditCreated  AuditAction = "created"
+	AuditUpdated  AuditAction = "updated"
+	AuditDeleted  AuditAction = "deleted"
+	AuditRestored AuditAction = "restored"
+)
+
+// AuditEntry record of a change made to a notification. Its attributes are:
+// + ID: identifier of the entry. Is a UUID v7, so the entries are sorted by creation when sorted by ID
+//
+// + NotificationID / Email: notification that changed and its owner
+//
+// + Action: what was done to the notification
+//
+// + Actor: who made the change. The ID of the user, or telegram if the change came from the Telegram service
+//
+// + Timestamp: when the change was made
+//
+// + Before / After: the notification before and after the change. Before is nil when it's created
+type AuditEntry struct {
+	ID             string
+	NotificationID string
+	Email          string
+	Action         AuditAction
+	Actor          string
+	Timestamp      time.Time
+	Before         *Notification
+	After          *Notification
+}
+
+// NewAuditEntry returns the entry of the given change. At least one of before and after must not be nil
+func NewAuditEntry(id string, action AuditAction, actor string, before *Notification, after *Notification, timestamp time.Time) AuditEntry {
+	changed := after
+	if changed == nil {
+		changed = before
+	}
+
+	return AuditEntry{
+		ID:             id,
+		NotificationID: changed.ID,
+		Email:          changed.Email,
+		Action:         action,
+		Actor:          actor,
+		Timestamp:      timestamp,
+		Before:         before,
+		After:          after,
+	}
+}
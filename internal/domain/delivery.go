@@ -0,0 +1,78 @@
+package domain
+
+import "time"
+
+// DeliveryStatus state of a delivery in the outbox
+type DeliveryStatus string
+
+const (
+	// DeliveryPending the delivery is waiting to be sent, for the first time or again after a failure
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliverySent the provider accepted the delivery
+	DeliverySent DeliveryStatus = "sent"
+	// DeliveryFailed the delivery failed permanently, or too many times
+	DeliveryFailed DeliveryStatus = "failed"
+	// DeliveryCancelled the notification was deleted or paused before it could be sent
+	DeliveryCancelled DeliveryStatus = "cancelled"
+)
+
+// Delivery occurrence of a notification at a slot through one of its channels, written to the outbox before it's
+// sent. Its attributes are:
+// + ID: depends on the notification, the slot and the channel, so each occurrence is enqueued only once per channel
+//
+// + Channel: channel by which the delivery is sent. Deliveries enqueued before channels were tracked have none, they
+// are sent through every channel of the notification
+//
+// + Attempts: amount of times the delivery was claimed to be sent. A claimed delivery is hidden from other workers
+// until its next attempt, so Attempts also acts as its version
+//
+// + NextAttemptAt: when the delivery can be claimed. Failed attempts move it forward with an exponential backoff
+//
+// + LastError: error of the last failed attempt
+//
+// + FinishedAt: when the delivery stopped being pending. Finished deliveries are purged after the retention period
+type Delivery struct {
+	ID             string
+	NotificationID string
+	Channel        Via
+	Slot           time.Time
+	Status         DeliveryStatus
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastError      string
+	CreatedAt      time.Time
+	FinishedAt     *time.Time
+}
+
+// NewDelivery returns the pending delivery of the occurrence of the notification at the given slot through the given
+// channel. It can be claimed right away
+func NewDelivery(notificationID string, channel Via, slot time.Time, now time.Time) Delivery {
+	return Delivery{
+		ID:             DeliveryID(notificationID, channel, slot),
+		NotificationID: notificationID,
+		Channel:        channel,
+		Slot:           slot,
+		Status:         DeliveryPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+	}
+}
+
+// DeliveryID returns the ID of the delivery of the occurrence of the notification at the given slot through the given
+// channel
+func DeliveryID(notificationID string, channel Via, slot time.Time) string {
+	return notificationID + "@" + slot.UTC().Format(time.RFC3339) + "/" + string(channel)
+}
+
+// Finish sets the final status of the delivery
+func (d *Delivery) Finish(status DeliveryStatus, lastError string, finishedAt time.Time) {
+	d.Status = status
+	d.LastError = lastError
+	d.FinishedAt = &finishedAt
+}
+
+// Retry leaves the delivery pending until the given instant
+func (d *Delivery) Retry(lastError string, nextAttemptAt time.Time) {
+	d.LastError = lastError
+	d.NextAttemptAt = nextAttemptAt
+}
@@ -1,42 +1,260 @@
 package domain
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"notification-scheduler/internal/recurrence"
+	"notification-scheduler/internal/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// firstFireTimeSearchDays amount of days searched to find the first occurrence of a notification
+	firstFireTimeSearchDays = 5 * 366
+	// maxParsedRules amount of recurrence rules kept parsed. Once reached, the rules are parsed again
+	maxParsedRules = 10000
+)
+
+// ruleKey identifies a parsed recurrence rule: the expression, its start day and the location of that day
+type ruleKey struct {
+	expression string
+	start      string
+	location   string
+}
+
+// parsedRules recurrence rules already parsed, shared by all the notifications
+var parsedRules = struct {
+	mutex sync.Mutex
+	rules map[ruleKey]recurrence.Rule
+}{rules: make(map[ruleKey]recurrence.Rule)}
 
 // Notification structure that acts like a DTO. Its attributes are:
 // + ID: identifier of the notification. Needed for the different types of operations. Is a UUID
 //
+// + ScheduleID: identifier shared by all the notifications created from the same request, one per hour. Is a UUID
+//
 // + TelegramID / Email: info needed to send a notification to one of these services
 //
+// + WebhookURL: only for notifications sent by webhook, the URL to which they are posted
+//
 // + Message: message to be sent to the user
 //
-// + Via: can be Telegram, Mail or Both. The notification will be delivery to one of these services, or both
+// + Via: can be Telegram, Mail, Both or Webhook. The notification will be delivery to one of these services, or both
 //
 // + StartDate: when the notification is triggered
 //
 // + EndDate: when the notifications should stop. If none data was pass to this attribute, the notification never ends
 //
 // + Hours: hours of the day on which the notification should be sent
+//
+// + TimeZone: IANA time zone in which the hours are expressed. If it's empty, the time zone of the server is used
+//
+// + LastSent: slot of the last occurrence that was sent. Nil if the notification was never sent
+//
+// + Recurrence: cron expression or iCalendar RRULE that defines on which days the notification is sent. If it's
+// empty, the notification is sent every day
+//
+// + FireAt: only for one-shot notifications, the exact instant on which the notification is sent. Nil for recurring
+// ones
+//
+// + CompletedAt: when the notification was completed. Completed notifications are never sent again
+//
+// + CatchUp: what to do with the occurrences that were missed while the service was down
+//
+// + Paused: paused notifications are not sent until they are resumed. Occurrences missed while paused are not caught up
+//
+// + Version: incremented on every change of the notification. An update or delete of a given version fails if the
+// notification changed since that version was read
+//
+// + DeletedAt: when the notification was deleted. Deleted notifications are never sent, and can be restored until
+// they are purged
 type Notification struct {
-	ID         string
-	TelegramID string
-	Email      string
-	Message    string
-	Via        Via
-	StartDate  time.Time
-	EndDate    *time.Time
-	Hours      []string
+	ID          string
+	ScheduleID  string
+	TelegramID  string
+	Email       string
+	Message     string
+	Via         Via
+	StartDate   time.Time
+	EndDate     *time.Time
+	Hours       []string
+	TimeZone    string
+	LastSent    *time.Time
+	Recurrence  string
+	FireAt      *time.Time
+	CompletedAt *time.Time
+	CatchUp     CatchUpPolicy
+	Paused      bool
+	Version     int64
+	DeletedAt   *time.Time
+	WebhookURL  string
+}
+
+// ETag returns the entity tag of the current version of the notification
+func (n Notification) ETag() string {
+	return fmt.Sprintf(`"%d"`, n.Version)
+}
+
+// ScheduleETag returns the entity tag of the current versions of the notifications of a schedule. It changes when any
+// of them changes, or when one is added or removed
+func ScheduleETag(notifications []Notification) string {
+	versions := make([]string, 0, len(notifications))
+	for idx := range notifications {
+		versions = append(versions, fmt.Sprintf("%s:%d", notifications[idx].ID, notifications[idx].Version))
+	}
+
+	sort.Strings(versions)
+	hash := sha256.Sum256([]byte(strings.Join(versions, ",")))
+	return `"` + hex.EncodeToString(hash[:8]) + `"`
+}
+
+// Location returns the time zone in which the hours of the notification are expressed
+func (n Notification) Location() *time.Location {
+	return utils.LoadLocation(n.TimeZone)
+}
+
+// IsOneShot returns true if the notification is sent only once, at an exact instant
+func (n Notification) IsOneShot() bool {
+	return n.FireAt != nil
+}
+
+// IsDue returns true if the notification has to be sent at the given slot: the slot is between the start and end
+// dates, one of the hours of the notification happens at it and that occurrence was not sent yet. One-shot
+// notifications are due only at their fire instant. Completed, paused and deleted notifications are never due
+func (n Notification) IsDue(slot time.Time) bool {
+	if n.CompletedAt != nil || n.Paused || n.DeletedAt != nil {
+		return false
+	}
+
+	if n.IsOneShot() {
+		return n.FireAt.Equal(slot) && n.LastSent == nil
+	}
+
+	if !n.Active(slot) || !n.FiresAt(slot) || !n.OccursOn(slot) {
+		return false
+	}
+
+	return n.LastSent == nil || n.LastSent.Before(slot)
+}
+
+// Active returns true if the given instant is between the start date and the end date of the notification
+func (n Notification) Active(instant time.Time) bool {
+	if instant.Before(n.StartDate) {
+		return false
+	}
+
+	return n.EndDate == nil || !instant.After(*n.EndDate)
+}
+
+// OccursOn returns true if the day of the given instant, in the time zone of the notification, is one of the days
+// defined by its recurrence. Notifications without recurrence occur every day
+func (n Notification) OccursOn(instant time.Time) bool {
+	if n.Recurrence == "" {
+		return true
+	}
+
+	location := n.Location()
+	rule, err := parseRecurrence(n.Recurrence, n.recurrenceStart(), location)
+	if err != nil {
+		return false
+	}
+
+	return rule.OccursOn(instant.In(location))
+}
+
+// parseRecurrence returns the rule of the given recurrence, that starts on the given day in the given location.
+// Rules are parsed once and kept in parsedRules, as they are checked on every slot
+func parseRecurrence(expression string, start time.Time, location *time.Location) (recurrence.Rule, error) {
+	key := ruleKey{expression: expression, start: start.Format(time.DateOnly), location: location.String()}
+	parsedRules.mutex.Lock()
+	defer parsedRules.mutex.Unlock()
+
+	if rule, found := parsedRules.rules[key]; found {
+		return rule, nil
+	}
+
+	rule, err := recurrence.Parse(expression, start)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parsedRules.rules) >= maxParsedRules {
+		parsedRules.rules = make(map[ruleKey]recurrence.Rule)
+	}
+
+	parsedRules.rules[key] = rule
+	return rule, nil
+}
+
+// recurrenceStart returns the start day of the notification in its time zone. The day is the one that was
+// requested, regardless of the offset of the start date
+func (n Notification) recurrenceStart() time.Time {
+	year, month, day := n.StartDate.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, n.Location())
+}
+
+// FiresAt returns true if one of the hours of the notification, in its time zone, happens at the given slot
+func (n Notification) FiresAt(slot time.Time) bool {
+	location := n.Location()
+	for _, hour := range n.Hours {
+		fireTime, err := utils.FireTime(slot, hour, location)
+		if err == nil && fireTime.Equal(slot) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FirstFireTime returns the first instant at which the first hour of the notification is sent, according to its
+// start date, end date and recurrence. Only the days of the first years are searched, false is returned if the
+// notification is not sent on any of them
+func (n Notification) FirstFireTime() (time.Time, bool) {
+	if n.FireAt != nil {
+		return *n.FireAt, true
+	}
+
+	location := n.Location()
+	start := n.recurrenceStart()
+	for days := 0; days < firstFireTimeSearchDays; days++ {
+		fireTime, err := utils.FireTime(start.AddDate(0, 0, days), n.Hours[0], location)
+		if err != nil || (n.EndDate != nil && fireTime.After(*n.EndDate)) {
+			return time.Time{}, false
+		}
+
+		if n.Active(fireTime) && n.OccursOn(fireTime) {
+			return fireTime, true
+		}
+	}
+
+	return time.Time{}, false
 }
 
 func Merge(notification Notification, update UpdateNotificationRequest) Notification {
 	mergeResult := Notification{
-		ID:         notification.ID,
-		TelegramID: notification.TelegramID,
-		Email:      notification.Email,
-		Message:    notification.Message,
-		Via:        notification.Via,
-		StartDate:  notification.StartDate,
-		EndDate:    notification.EndDate,
-		Hours:      notification.Hours,
+		ID:          notification.ID,
+		ScheduleID:  notification.ScheduleID,
+		TelegramID:  notification.TelegramID,
+		Email:       notification.Email,
+		Message:     notification.Message,
+		Via:         notification.Via,
+		StartDate:   notification.StartDate,
+		EndDate:     notification.EndDate,
+		Hours:       notification.Hours,
+		TimeZone:    notification.TimeZone,
+		LastSent:    notification.LastSent,
+		Recurrence:  notification.Recurrence,
+		FireAt:      notification.FireAt,
+		CompletedAt: notification.CompletedAt,
+		CatchUp:     notification.CatchUp,
+		Paused:      notification.Paused,
+		Version:     notification.Version,
+		DeletedAt:   notification.DeletedAt,
+		WebhookURL:  notification.WebhookURL,
 	}
 
 	if notification.Message != update.Message {
@@ -0,0 +1,127 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestIsDue(t *testing.T) {
+	slot := time.Date(2024, 7, 1, 12, 30, 0, 0, time.UTC)
+	before := slot.Add(-24 * time.Hour)
+	after := slot.Add(24 * time.Hour)
+	notification := Notification{
+		ID:        "1",
+		StartDate: slot.AddDate(0, 0, -7),
+		Hours:     []string{"08:30"},
+		TimeZone:  "America/New_York",
+	}
+
+	testCases := []struct {
+		name     string
+		modify   func(n *Notification)
+		slot     time.Time
+		expected bool
+	}{
+		{
+			name:     "hour of the notification in its time zone",
+			modify:   func(n *Notification) {},
+			slot:     slot,
+			expected: true,
+		},
+		{
+			name:     "other hour",
+			modify:   func(n *Notification) {},
+			slot:     slot.Add(30 * time.Minute),
+			expected: false,
+		},
+		{
+			name:     "same hour in UTC",
+			modify:   func(n *Notification) {},
+			slot:     time.Date(2024, 7, 1, 8, 30, 0, 0, time.UTC),
+			expected: false,
+		},
+		{
+			name:     "before the start date",
+			modify:   func(n *Notification) { n.StartDate = after },
+			slot:     slot,
+			expected: false,
+		},
+		{
+			name:     "after the end date",
+			modify:   func(n *Notification) { n.EndDate = &before },
+			slot:     slot,
+			expected: false,
+		},
+		{
+			name:     "on the end date",
+			modify:   func(n *Notification) { n.EndDate = &slot },
+			slot:     slot,
+			expected: true,
+		},
+		{
+			name:     "sent on a previous slot",
+			modify:   func(n *Notification) { n.LastSent = &before },
+			slot:     slot,
+			expected: true,
+		},
+		{
+			name:     "already sent on the slot",
+			modify:   func(n *Notification) { n.LastSent = &slot },
+			slot:     slot,
+			expected: false,
+		},
+		{
+			name:     "completed",
+			modify:   func(n *Notification) { n.CompletedAt = &before },
+			slot:     slot,
+			expected: false,
+		},
+		{
+			name:     "paused",
+			modify:   func(n *Notification) { n.Paused = true },
+			slot:     slot,
+			expected: false,
+		},
+		{
+			name:     "deleted",
+			modify:   func(n *Notification) { n.DeletedAt = &before },
+			slot:     slot,
+			expected: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			modified := notification
+			testCase.modify(&modified)
+			assert.Equal(t, testCase.expected, modified.IsDue(testCase.slot))
+		})
+	}
+}
+
+func TestIsDueOneShot(t *testing.T) {
+	fireAt := time.Date(2024, 7, 1, 12, 30, 0, 0, time.UTC)
+	notification := Notification{
+		ID:        "1",
+		StartDate: fireAt,
+		FireAt:    &fireAt,
+		TimeZone:  "UTC",
+	}
+
+	assert.True(t, notification.IsDue(fireAt))
+	assert.False(t, notification.IsDue(fireAt.Add(-30*time.Minute)))
+	assert.False(t, notification.IsDue(fireAt.Add(30*time.Minute)))
+
+	sent := notification
+	sent.LastSent = &fireAt
+	assert.False(t, sent.IsDue(fireAt))
+
+	paused := notification
+	paused.Paused = true
+	assert.False(t, paused.IsDue(fireAt))
+
+	firstFireTime, found := notification.FirstFireTime()
+	assert.True(t, found)
+	assert.Equal(t, fireAt, firstFireTime)
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"encoding/json"
+	"notification-scheduler/internal/recurrence"
 	"notification-scheduler/internal/utils"
 	"strings"
 	"time"
@@ -14,12 +15,15 @@ const (
 	Telegram Via = "telegram"
 	Mail     Via = "mail"
 	Both     Via = "both"
+	// Webhook the notification is posted to the webhook URL of the notification
+	Webhook Via = "webhook"
 )
 
 var validVias = []Via{
 	Telegram,
 	Mail,
 	Both,
+	Webhook,
 }
 
 // ValidVia returns true if the given via is valid, otherwise false
@@ -27,6 +31,15 @@ func ValidVia(via Via) bool {
 	return utils.Contains(validVias, via)
 }
 
+// Channels returns the channels by which notifications with this via are sent: both is sent by mail and by Telegram
+func (v Via) Channels() []Via {
+	if v == Both {
+		return []Via{Mail, Telegram}
+	}
+
+	return []Via{v}
+}
+
 // getViaFromString returns the matching via based on the given input. If the input does not match
 // with any Via, is converted to one which might be invalid
 func getViaFromString(input string) Via {
@@ -38,18 +51,49 @@ func getViaFromString(input string) Via {
 		return Mail
 	case string(Both):
 		return Both
+	case string(Webhook):
+		return Webhook
 	default:
 		return Via(input)
 	}
 }
 
+// CatchUpPolicy defines what happens with the occurrences of a notification that were missed, for example
+// because the service was down
+type CatchUpPolicy string
+
+const (
+	// CatchUpAll sends late every missed occurrence
+	CatchUpAll CatchUpPolicy = "all"
+	// CatchUpLatest sends late only the latest missed occurrence. It's the default policy
+	CatchUpLatest CatchUpPolicy = "latest"
+	// CatchUpSkip does not send missed occurrences
+	CatchUpSkip CatchUpPolicy = "skip"
+)
+
+var validCatchUpPolicies = []CatchUpPolicy{
+	CatchUpAll,
+	CatchUpLatest,
+	CatchUpSkip,
+}
+
+// ValidCatchUpPolicy returns true if the given policy is valid, otherwise false
+func ValidCatchUpPolicy(policy CatchUpPolicy) bool {
+	return utils.Contains(validCatchUpPolicies, policy)
+}
+
 type NotificationRequest struct {
-	TelegramID string     `json:"telegram_id"`
-	Via        Via        `json:"via" binding:"required"`
-	Message    string     `json:"message" binding:"required"`
-	StartDate  time.Time  `json:"start_date" binding:"required"`
-	EndDate    *time.Time `json:"end_date"`
-	Hours      []string   `json:"hours" binding:"required"`
+	TelegramID string        `json:"telegram_id"`
+	Via        Via           `json:"via" binding:"required"`
+	Message    string        `json:"message" binding:"required"`
+	StartDate  time.Time     `json:"start_date"`
+	EndDate    *time.Time    `json:"end_date"`
+	Hours      []string      `json:"hours"`
+	TimeZone   string        `json:"time_zone" example:"America/Argentina/Buenos_Aires"`
+	Recurrence string        `json:"recurrence" example:"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU"`
+	FireAt     *time.Time    `json:"fire_at"`
+	CatchUp    CatchUpPolicy `json:"catch_up" example:"latest"`
+	WebhookURL string        `json:"webhook_url" example:"https://clinic.example.com/reminders"`
 	Email      string
 }
 
@@ -61,6 +105,11 @@ func (nr *NotificationRequest) UnmarshalJSON(rawData []byte) error {
 		StartDate  time.Time  `json:"start_date"`
 		EndDate    *time.Time `json:"end_date"`
 		Hours      []string   `json:"hours"`
+		TimeZone   string     `json:"time_zone"`
+		Recurrence string     `json:"recurrence"`
+		FireAt     *time.Time `json:"fire_at"`
+		CatchUp    string     `json:"catch_up"`
+		WebhookURL string     `json:"webhook_url"`
 	}
 
 	err := json.Unmarshal(rawData, &requestData)
@@ -74,10 +123,39 @@ func (nr *NotificationRequest) UnmarshalJSON(rawData []byte) error {
 	nr.StartDate = requestData.StartDate
 	nr.EndDate = requestData.EndDate
 	nr.Hours = requestData.Hours
+	nr.TimeZone = requestData.TimeZone
+	nr.Recurrence = strings.TrimSpace(requestData.Recurrence)
+	nr.FireAt = requestData.FireAt
+	nr.CatchUp = CatchUpPolicy(strings.ToLower(requestData.CatchUp))
+	nr.WebhookURL = strings.TrimSpace(requestData.WebhookURL)
 	return nil
 }
 
+// ToNotification returns the request as a Notification. Hours are converted to their canonical form, HH:MM. If the
+// recurrence is a cron expression, the hours are the ones defined by it. One-shot notifications have a single
+// hour, the one of their fire instant, and start on that instant
 func (nr *NotificationRequest) ToNotification() Notification {
+	if nr.FireAt != nil {
+		return nr.toOneShotNotification()
+	}
+
+	requestHours := nr.Hours
+	if nr.Recurrence != "" && !recurrence.IsRRule(nr.Recurrence) {
+		cronHours, err := recurrence.CronHours(nr.Recurrence)
+		if err == nil {
+			requestHours = cronHours
+		}
+	}
+
+	hours := make([]string, 0, len(requestHours))
+	for _, hour := range requestHours {
+		normalizedHour, err := utils.NormalizeHour(hour)
+		if err != nil {
+			normalizedHour = hour
+		}
+		hours = append(hours, normalizedHour)
+	}
+
 	return Notification{
 		TelegramID: nr.TelegramID,
 		Email:      nr.Email,
@@ -85,8 +163,105 @@ func (nr *NotificationRequest) ToNotification() Notification {
 		Via:        nr.Via,
 		StartDate:  nr.StartDate,
 		EndDate:    nr.EndDate,
-		Hours:      nr.Hours,
+		Hours:      hours,
+		TimeZone:   nr.TimeZone,
+		Recurrence: nr.Recurrence,
+		CatchUp:    nr.catchUpPolicy(),
+		WebhookURL: nr.WebhookURL,
+	}
+}
+
+func (nr *NotificationRequest) toOneShotNotification() Notification {
+	location := utils.LoadLocation(nr.TimeZone)
+	localFireAt := nr.FireAt.In(location)
+
+	startDate := nr.StartDate
+	if startDate.IsZero() {
+		startDate = *nr.FireAt
+	}
+
+	return Notification{
+		TelegramID: nr.TelegramID,
+		Email:      nr.Email,
+		Message:    nr.Message,
+		Via:        nr.Via,
+		StartDate:  startDate,
+		Hours:      []string{utils.FormatHour(localFireAt.Hour(), localFireAt.Minute())},
+		TimeZone:   nr.TimeZone,
+		FireAt:     nr.FireAt,
+		CatchUp:    nr.catchUpPolicy(),
+		WebhookURL: nr.WebhookURL,
+	}
+}
+
+// catchUpPolicy returns the requested catch-up policy, or the default one if none was given
+func (nr *NotificationRequest) catchUpPolicy() CatchUpPolicy {
+	if nr.CatchUp == "" {
+		return CatchUpLatest
+	}
+
+	return nr.CatchUp
+}
+
+const (
+	// DefaultSearchLimit amount of notifications per page when no limit is requested
+	DefaultSearchLimit = 20
+	// MaxSearchLimit maximum amount of notifications per page
+	MaxSearchLimit = 100
+)
+
+// NotificationSearchRequest query parameters to search the notifications of a user. All of them are optional
+type NotificationSearchRequest struct {
+	Via      string    `form:"via" example:"mail"`
+	Status   string    `form:"status" example:"active"`
+	HourFrom string    `form:"hour_from" example:"08:00"`
+	HourTo   string    `form:"hour_to" example:"20:30"`
+	Text     string    `form:"q" example:"pills"`
+	From     time.Time `form:"from"`
+	To       time.Time `form:"to"`
+	Cursor   string    `form:"cursor"`
+	Limit    int       `form:"limit" example:"20"`
+}
+
+// ToQuery returns the search of the notifications of the given user. The request must be valid
+func (sr *NotificationSearchRequest) ToQuery(email string, now time.Time) NotificationQuery {
+	query := NotificationQuery{
+		Email:  email,
+		Via:    getViaFromString(sr.Via),
+		Status: NotificationStatus(strings.ToLower(sr.Status)),
+		Text:   sr.Text,
+		Now:    now,
+		Limit:  sr.Limit,
+	}
+
+	if sr.HourFrom != "" {
+		query.HourFrom, _ = utils.NormalizeHour(sr.HourFrom)
+	}
+
+	if sr.HourTo != "" {
+		query.HourTo, _ = utils.NormalizeHour(sr.HourTo)
 	}
+
+	if !sr.From.IsZero() {
+		query.From = &sr.From
+	}
+
+	if !sr.To.IsZero() {
+		query.To = &sr.To
+	}
+
+	if sr.Cursor != "" {
+		cursor, err := DecodeNotificationCursor(sr.Cursor)
+		if err == nil {
+			query.After = &cursor
+		}
+	}
+
+	if query.Limit == 0 {
+		query.Limit = DefaultSearchLimit
+	}
+
+	return query
 }
 
 type UpdateNotificationRequest struct {
@@ -95,25 +270,154 @@ type UpdateNotificationRequest struct {
 }
 
 type NotificationResponse struct {
-	ID        string     `json:"id"`
-	Via       Via        `json:"via"`
-	Message   string     `json:"message,omitempty"`
-	StartDate time.Time  `json:"start_date"`
-	EndDate   *time.Time `json:"end_date,omitempty"`
-	Hour      string     `json:"hour"`
+	ID          string        `json:"id"`
+	ScheduleID  string        `json:"schedule_id,omitempty"`
+	Via         Via           `json:"via"`
+	Message     string        `json:"message,omitempty"`
+	StartDate   time.Time     `json:"start_date"`
+	EndDate     *time.Time    `json:"end_date,omitempty"`
+	Hour        string        `json:"hour"`
+	TimeZone    string        `json:"time_zone,omitempty"`
+	Recurrence  string        `json:"recurrence,omitempty"`
+	FireAt      *time.Time    `json:"fire_at,omitempty"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty"`
+	CatchUp     CatchUpPolicy `json:"catch_up,omitempty"`
+	Paused      bool          `json:"paused"`
+	DeletedAt   *time.Time    `json:"deleted_at,omitempty"`
+	WebhookURL  string        `json:"webhook_url,omitempty"`
 }
 
 func NewNotificationResponse(notification Notification) NotificationResponse {
 	return NotificationResponse{
-		ID:        notification.ID,
-		Via:       notification.Via,
-		Message:   notification.Message,
-		StartDate: notification.StartDate,
-		EndDate:   notification.EndDate,
-		Hour:      notification.Hours[0],
+		ID:          notification.ID,
+		ScheduleID:  notification.ScheduleID,
+		Via:         notification.Via,
+		Message:     notification.Message,
+		StartDate:   notification.StartDate,
+		EndDate:     notification.EndDate,
+		Hour:        notification.Hours[0],
+		TimeZone:    notification.TimeZone,
+		Recurrence:  notification.Recurrence,
+		FireAt:      notification.FireAt,
+		CompletedAt: notification.CompletedAt,
+		CatchUp:     notification.CatchUp,
+		Paused:      notification.Paused,
+		DeletedAt:   notification.DeletedAt,
+		WebhookURL:  notification.WebhookURL,
 	}
 }
 
 func (nr *NotificationResponse) HideMessage() {
 	nr.Message = ""
 }
+
+// ScheduleResponse all the notifications of a schedule, one per hour. The schedule is paused if all of them are
+type ScheduleResponse struct {
+	ID            string                 `json:"id"`
+	Paused        bool                   `json:"paused"`
+	Notifications []NotificationResponse `json:"notifications"`
+}
+
+func NewScheduleResponse(scheduleID string, notifications []Notification) ScheduleResponse {
+	response := ScheduleResponse{
+		ID:     scheduleID,
+		Paused: len(notifications) > 0,
+	}
+
+	for idx := range notifications {
+		response.Paused = response.Paused && notifications[idx].Paused
+		response.Notifications = append(response.Notifications, NewNotificationResponse(notifications[idx]))
+	}
+
+	return response
+}
+
+// ListMetadata data about a page of a list. NextCursor must be sent to get the next page. It's empty on the last one
+type ListMetadata struct {
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// NotificationListResponse page of notifications
+type NotificationListResponse struct {
+	Data     []NotificationResponse `json:"data"`
+	Metadata ListMetadata           `json:"metadata"`
+}
+
+func NewNotificationListResponse(page NotificationPage, limit int) NotificationListResponse {
+	response := NotificationListResponse{
+		Data: make([]NotificationResponse, 0, len(page.Notifications)),
+		Metadata: ListMetadata{
+			Total: page.Total,
+			Limit: limit,
+		},
+	}
+
+	for idx := range page.Notifications {
+		response.Data = append(response.Data, NewNotificationResponse(page.Notifications[idx]))
+	}
+
+	if page.Next != nil {
+		response.Metadata.NextCursor = page.Next.Encode()
+	}
+
+	return response
+}
+
+// AuditEntryResponse change made to a notification. Before is omitted when the notification is created
+type AuditEntryResponse struct {
+	ID        string                `json:"id"`
+	Action    AuditAction           `json:"action"`
+	Actor     string                `json:"actor"`
+	Timestamp time.Time             `json:"timestamp"`
+	Before    *NotificationResponse `json:"before,omitempty"`
+	After     *NotificationResponse `json:"after,omitempty"`
+}
+
+func NewAuditEntryResponse(entry AuditEntry) AuditEntryResponse {
+	response := AuditEntryResponse{
+		ID:        entry.ID,
+		Action:    entry.Action,
+		Actor:     entry.Actor,
+		Timestamp: entry.Timestamp,
+	}
+
+	if entry.Before != nil {
+		before := NewNotificationResponse(*entry.Before)
+		response.Before = &before
+	}
+
+	if entry.After != nil {
+		after := NewNotificationResponse(*entry.After)
+		response.After = &after
+	}
+
+	return response
+}
+
+// ImportResponse result of importing a file of notifications. Each row of the file is imported on its own, the rows
+// with errors are not imported. Rows whose notifications already exist are skipped
+type ImportResponse struct {
+	Imported int               `json:"imported"`
+	Skipped  int               `json:"skipped"`
+	Failed   int               `json:"failed"`
+	Rows     []ImportRowResult `json:"rows"`
+}
+
+// ImportRowResult result of importing a row. Rows are numbered from 1, in the order of the file. The schedule is
+// the one created for the row
+type ImportRowResult struct {
+	Row        int    `json:"row"`
+	ScheduleID string `json:"schedule_id,omitempty"`
+	Skipped    bool   `json:"skipped,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// EmailPreviewResponse email rendered with a template and sample data
+type EmailPreviewResponse struct {
+	Template string `json:"template"`
+	Subject  string `json:"subject"`
+	Text     string `json:"text"`
+	HTML     string `json:"html"`
+}
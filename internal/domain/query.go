@@ -0,0 +1,158 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NotificationStatus whether a notification is still being sent or not
+type NotificationStatus string
+
+const (
+	// StatusActive notifications that are not completed and did not reach their end date
+	StatusActive NotificationStatus = "active"
+	// StatusExpired notifications that are completed or reached their end date
+	StatusExpired NotificationStatus = "expired"
+)
+
+// ValidNotificationStatus returns true if the given status is valid, otherwise false
+func ValidNotificationStatus(status NotificationStatus) bool {
+	return status == StatusActive || status == StatusExpired
+}
+
+// NotificationCursor position of a notification in the sort order of the searches: by hour and then by ID
+type NotificationCursor struct {
+	Hour string
+	ID   string
+}
+
+// NewNotificationCursor returns the cursor of the given notification
+func NewNotificationCursor(notification Notification) NotificationCursor {
+	return NotificationCursor{
+		Hour: notification.Hours[0],
+		ID:   notification.ID,
+	}
+}
+
+// Precedes returns true if the given notification is after the cursor in the sort order
+func (nc NotificationCursor) Precedes(notification Notification) bool {
+	hour := notification.Hours[0]
+	return hour > nc.Hour || (hour == nc.Hour && notification.ID > nc.ID)
+}
+
+// Encode returns the cursor as an opaque string, to be sent to the clients
+func (nc NotificationCursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(nc.Hour + "|" + nc.ID))
+}
+
+// DecodeNotificationCursor parses a cursor returned by Encode
+func DecodeNotificationCursor(encodedCursor string) (NotificationCursor, error) {
+	rawCursor, err := base64.RawURLEncoding.DecodeString(encodedCursor)
+	if err != nil {
+		return NotificationCursor{}, err
+	}
+
+	hour, id, found := strings.Cut(string(rawCursor), "|")
+	if !found || hour == "" || id == "" {
+		return NotificationCursor{}, errors.New("malformed cursor")
+	}
+
+	return NotificationCursor{Hour: hour, ID: id}, nil
+}
+
+// NotificationQuery search of the notifications of a user. Empty fields do not filter. Its attributes are:
+// + Email: owner of the notifications. Required
+//
+// + Via: only notifications sent by this service
+//
+// + Status: only active or expired notifications, at the instant Now
+//
+// + HourFrom / HourTo: only notifications sent between these hours, both included. In HH:MM format
+//
+// + Text: only notifications whose message contains it, ignoring case
+//
+// + From / To: only notifications active at some point of this date range
+//
+// + After: only notifications after this cursor in the sort order. Nil for the first page
+//
+// + Limit: maximum amount of notifications returned
+type NotificationQuery struct {
+	Email    string
+	Via      Via
+	Status   NotificationStatus
+	HourFrom string
+	HourTo   string
+	Text     string
+	From     *time.Time
+	To       *time.Time
+	Now      time.Time
+	After    *NotificationCursor
+	Limit    int
+}
+
+// Matches returns true if the given notification passes all the filters of the query. The cursor is not checked.
+// Deleted notifications never match
+func (nq NotificationQuery) Matches(notification Notification) bool {
+	if notification.Email != nq.Email || notification.DeletedAt != nil {
+		return false
+	}
+
+	if nq.Via != "" && notification.Via != nq.Via {
+		return false
+	}
+
+	expired := notification.CompletedAt != nil || (notification.EndDate != nil && notification.EndDate.Before(nq.Now))
+	if (nq.Status == StatusActive && expired) || (nq.Status == StatusExpired && !expired) {
+		return false
+	}
+
+	hour := notification.Hours[0]
+	if (nq.HourFrom != "" && hour < nq.HourFrom) || (nq.HourTo != "" && hour > nq.HourTo) {
+		return false
+	}
+
+	if nq.Text != "" && !strings.Contains(strings.ToLower(notification.Message), strings.ToLower(nq.Text)) {
+		return false
+	}
+
+	if nq.To != nil && notification.StartDate.After(*nq.To) {
+		return false
+	}
+
+	return nq.From == nil || notification.EndDate == nil || !notification.EndDate.Before(*nq.From)
+}
+
+// NotificationPage result of a NotificationQuery. Total is the amount of notifications that match the filters, in
+// all the pages. Next is nil if this is the last page
+type NotificationPage struct {
+	Notifications []Notification
+	Total         int
+	Next          *NotificationCursor
+}
+
+// NewNotificationPage returns the page of the given notifications, that must be sorted and start after the cursor of
+// the query. To know if there is a next page, up to limit + 1 notifications should be given
+func NewNotificationPage(notifications []Notification, total int, limit int) NotificationPage {
+	page := NotificationPage{
+		Notifications: notifications,
+		Total:         total,
+	}
+
+	if len(notifications) > limit {
+		page.Notifications = notifications[:limit]
+		next := NewNotificationCursor(page.Notifications[limit-1])
+		page.Next = &next
+	}
+
+	return page
+}
+
+// SortNotifications sorts the given notifications by hour and then by ID, the order of the searches
+func SortNotifications(notifications []Notification) {
+	sort.Slice(notifications, func(i, j int) bool {
+		return NewNotificationCursor(notifications[i]).Precedes(notifications[j])
+	})
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// WebhookSecret secret of a user to sign the webhooks of its notifications. Each user has at most one, creating a new
+// one replaces the previous
+type WebhookSecret struct {
+	Email     string
+	Secret    string
+	CreatedAt time.Time
+}
+
+// WebhookSecretResponse secret returned when it's created. It's not returned again
+type WebhookSecretResponse struct {
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewWebhookSecretResponse(secret WebhookSecret) WebhookSecretResponse {
+	return WebhookSecretResponse{
+		Secret:    secret.Secret,
+		CreatedAt: secret.CreatedAt,
+	}
+}
@@ -45,6 +45,21 @@ func (c *AwsClient) Connect() error {
 
 func (c *AwsClient) SendEmail(mail Mail) error {
 
+	body := &ses.Body{
+		Text: &ses.Content{
+			Charset: aws.String("UTF-8"),
+			Data:    aws.String(mail.Body),
+		},
+	}
+
+	// With both versions SES sends a multipart email
+	if mail.HTML != "" {
+		body.Html = &ses.Content{
+			Charset: aws.String("UTF-8"),
+			Data:    aws.String(mail.HTML),
+		}
+	}
+
 	input := &ses.SendEmailInput{
 		Destination: &ses.Destination{
 			ToAddresses: []*string{
@@ -52,13 +67,10 @@ func (c *AwsClient) SendEmail(mail Mail) error {
 			},
 		},
 		Message: &ses.Message{
-			Body: &ses.Body{
-				Text: &ses.Content{
-					Data: aws.String(mail.Body),
-				},
-			},
+			Body: body,
 			Subject: &ses.Content{
-				Data: aws.String(mail.Subject),
+				Charset: aws.String("UTF-8"),
+				Data:    aws.String(mail.Subject),
 			},
 		},
 		Source: aws.String(c.config.From),
@@ -72,6 +84,6 @@ func (c *AwsClient) SendEmail(mail Mail) error {
 		return fmt.Errorf("%w: %w", errSendingEmail, err)
 	}
 
-	logrus.Info("Email sent correctly! Output: %v", output.MessageId)
+	logrus.Infof("Email sent correctly! Output: %v", aws.StringValue(output.MessageId))
 	return nil
 }
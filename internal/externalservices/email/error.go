@@ -3,6 +3,31 @@ package email
 import "errors"
 
 var (
-	errCreatingSession = errors.New("error creating session")
-	errSendingEmail    = errors.New("error sending email")
+	errCreatingSession      = errors.New("error creating session")
+	errSendingEmail         = errors.New("error sending email")
+	errConnectingSMTP       = errors.New("error connecting to smtp server")
+	errStartTLSUnsupported  = errors.New("error smtp server does not support STARTTLS")
+	errAuthUnsupported      = errors.New("error smtp server does not support authentication")
+	errAuthenticating       = errors.New("error authenticating against smtp server")
+	errInvalidRecipient     = errors.New("error invalid recipient")
+	errInvalidSender        = errors.New("error invalid sender")
+	errUnknownTLSMode       = errors.New("error unknown smtp tls mode")
+	errRejectedBySMTPServer = errors.New("error email rejected by smtp server")
 )
+
+// permanentError error of an email that fails again if it's retried, for example because the recipient is invalid
+type permanentError struct {
+	err error
+}
+
+func (pe permanentError) Error() string {
+	return pe.err.Error()
+}
+
+func (pe permanentError) Unwrap() error {
+	return pe.err
+}
+
+func (pe permanentError) Permanent() bool {
+	return true
+}
@@ -15,8 +15,11 @@ type EmailConfig struct {
 	From      string
 }
 
+// Mail email to send. Body is the plain text version. If HTML is set, the email has both versions, and the clients
+// show the one they support
 type Mail struct {
 	To      string `json:"to" binding:"required" example:"tomasfanciotti@gmail.com"`
 	Subject string `json:"subject" binding:"required" example:"testing subject"`
 	Body    string `json:"body" binding:"required" example:"body of the mail"`
+	HTML    string `json:"html,omitempty" example:"<p>body of the mail</p>"`
 }
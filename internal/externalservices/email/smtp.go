@@ -0,0 +1,321 @@
+package email
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// TLSMode how the connection with the SMTP server is encrypted
+type TLSMode string
+
+const (
+	// StartTLS the connection is upgraded with STARTTLS. Servers that do not support it are rejected
+	StartTLS TLSMode = "starttls"
+	// ImplicitTLS the connection is encrypted from the start, usually on port 465
+	ImplicitTLS TLSMode = "tls"
+	// NoTLS the connection is not encrypted. Only meant for local SMTP servers
+	NoTLS TLSMode = "none"
+
+	// DefaultSMTPPort submission port, used with STARTTLS
+	DefaultSMTPPort = "587"
+	// DefaultPoolSize maximum amount of idle connections kept open by default
+	DefaultPoolSize = 2
+	// DefaultSMTPTimeout maximum time each email takes by default, including the connection
+	DefaultSMTPTimeout = 10 * time.Second
+)
+
+// SMTPConfig configuration of the SMTPClient. Username and Password are optional, without them the client does not
+// authenticate. PoolSize is the maximum amount of idle connections kept open to reuse them
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	TLS      TLSMode
+	PoolSize int
+	Timeout  time.Duration
+}
+
+// ValidTLSMode returns true if the given mode is valid, otherwise false
+func ValidTLSMode(mode TLSMode) bool {
+	return mode == StartTLS || mode == ImplicitTLS || mode == NoTLS
+}
+
+// smtpConnection connection to the SMTP server, ready to send emails
+type smtpConnection struct {
+	conn   net.Conn
+	client *smtp.Client
+}
+
+// SMTPClient sends emails through an SMTP server. The connections are kept open after each email, up to the pool
+// size, so the next emails skip the handshake and authentication. It's safe for concurrent use
+type SMTPClient struct {
+	config SMTPConfig
+	idle   chan *smtpConnection
+}
+
+func NewSMTPClient(config SMTPConfig) *SMTPClient {
+	if config.Port == "" {
+		config.Port = DefaultSMTPPort
+	}
+	if config.TLS == "" {
+		config.TLS = StartTLS
+	}
+	if config.PoolSize <= 0 {
+		config.PoolSize = DefaultPoolSize
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultSMTPTimeout
+	}
+
+	return &SMTPClient{
+		config: config,
+		idle:   make(chan *smtpConnection, config.PoolSize),
+	}
+}
+
+// SendEmail sends the mail, as multipart if it has an HTML version. Errors the server answers with a 5xx code, like
+// the ones of unknown recipients, and invalid addresses have a Permanent method that returns true
+func (c *SMTPClient) SendEmail(mail Mail) error {
+	from, err := netmail.ParseAddress(c.config.From)
+	if err != nil {
+		return permanentError{err: fmt.Errorf("%w: %v", errInvalidSender, err)}
+	}
+
+	to, err := netmail.ParseAddress(mail.To)
+	if err != nil {
+		return permanentError{err: fmt.Errorf("%w: %v", errInvalidRecipient, err)}
+	}
+
+	message, err := newMessage(from, to, mail)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSendingEmail, err)
+	}
+
+	connection, err := c.connection()
+	if err != nil {
+		logrus.Errorf("error sending email: %v", err)
+		return err
+	}
+
+	err = send(connection.client, from.Address, to.Address, message)
+	if err != nil {
+		// The state of the session is unknown, so the connection is not reused
+		_ = connection.client.Close()
+		logrus.Errorf("error sending email: %v", err)
+		return sendingError(err)
+	}
+
+	c.release(connection)
+	logrus.Infof("Email sent correctly through smtp server %s", c.config.Host)
+	return nil
+}
+
+// connection returns an idle connection that is still open, or a new one if there is none
+func (c *SMTPClient) connection() (*smtpConnection, error) {
+	for {
+		select {
+		case connection := <-c.idle:
+			_ = connection.conn.SetDeadline(time.Now().Add(c.config.Timeout))
+			if connection.client.Noop() == nil {
+				return connection, nil
+			}
+			_ = connection.client.Close()
+		default:
+			return c.dial()
+		}
+	}
+}
+
+// release keeps the connection to send the next emails, unless the pool is full
+func (c *SMTPClient) release(connection *smtpConnection) {
+	if connection.client.Reset() != nil {
+		_ = connection.client.Close()
+		return
+	}
+
+	select {
+	case c.idle <- connection:
+	default:
+		_ = connection.client.Quit()
+	}
+}
+
+// dial opens a new connection, encrypted as configured, and authenticates if there is a username
+func (c *SMTPClient) dial() (*smtpConnection, error) {
+	address := net.JoinHostPort(c.config.Host, c.config.Port)
+	dialer := &net.Dialer{Timeout: c.config.Timeout}
+	tlsConfig := &tls.Config{ServerName: c.config.Host}
+
+	var conn net.Conn
+	var err error
+	switch c.config.TLS {
+	case ImplicitTLS:
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	case StartTLS, NoTLS:
+		conn, err = dialer.Dial("tcp", address)
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnknownTLSMode, c.config.TLS)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errConnectingSMTP, err)
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(c.config.Timeout))
+	client, err := smtp.NewClient(conn, c.config.Host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%w: %v", errConnectingSMTP, err)
+	}
+
+	err = c.handshake(client, tlsConfig)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+
+	return &smtpConnection{conn: conn, client: client}, nil
+}
+
+// handshake upgrades the connection with STARTTLS, if configured, and authenticates. Credentials are never sent
+// over an unencrypted connection, unless the server is the local host
+func (c *SMTPClient) handshake(client *smtp.Client, tlsConfig *tls.Config) error {
+	if c.config.TLS == StartTLS {
+		if supported, _ := client.Extension("STARTTLS"); !supported {
+			return errStartTLSUnsupported
+		}
+
+		err := client.StartTLS(tlsConfig)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errConnectingSMTP, err)
+		}
+	}
+
+	if c.config.Username == "" {
+		return nil
+	}
+
+	if supported, _ := client.Extension("AUTH"); !supported {
+		return errAuthUnsupported
+	}
+
+	err := client.Auth(smtp.PlainAuth("", c.config.Username, c.config.Password, c.config.Host))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errAuthenticating, err)
+	}
+
+	return nil
+}
+
+func send(client *smtp.Client, from string, to string, message []byte) error {
+	err := client.Mail(from)
+	if err != nil {
+		return err
+	}
+
+	err = client.Rcpt(to)
+	if err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(message)
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// sendingError wraps the error of the server. The ones with 5xx codes are permanent
+func sendingError(err error) error {
+	var protocolErr *textproto.Error
+	if errors.As(err, &protocolErr) && protocolErr.Code >= 500 {
+		return permanentError{err: fmt.Errorf("%w: %v", errRejectedBySMTPServer, err)}
+	}
+
+	return fmt.Errorf("%w: %v", errSendingEmail, err)
+}
+
+// newMessage returns the mail with its headers. If it has an HTML version, the message is multipart, with the plain
+// text version first, so clients show the last one they support. Line breaks are removed from the subject, so it
+// cannot add headers
+func newMessage(from *netmail.Address, to *netmail.Address, mail Mail) ([]byte, error) {
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(mail.Subject)
+
+	var message bytes.Buffer
+	message.WriteString("From: " + from.String() + "\r\n")
+	message.WriteString("To: " + to.String() + "\r\n")
+	message.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	message.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+
+	if mail.HTML == "" {
+		message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		message.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		err := writeQuotedPrintable(&message, mail.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		return message.Bytes(), nil
+	}
+
+	writer := multipart.NewWriter(&message)
+	message.WriteString("Content-Type: multipart/alternative; boundary=" + writer.Boundary() + "\r\n\r\n")
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain", mail.Body},
+		{"text/html", mail.HTML},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType+"; charset=UTF-8")
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		partWriter, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+
+		err = writeQuotedPrintable(partWriter, part.content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return message.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, content string) error {
+	writer := quotedprintable.NewWriter(w)
+	_, err := writer.Write([]byte(content))
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
@@ -0,0 +1,175 @@
+package email
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpStub SMTP server that accepts every email but the ones to rejected@testmail.com. It does not support STARTTLS
+type smtpStub struct {
+	listener    net.Listener
+	mutex       sync.Mutex
+	connections int
+	messages    []string
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	stub := &smtpStub{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			stub.mutex.Lock()
+			stub.connections++
+			stub.mutex.Unlock()
+			go stub.serve(conn)
+		}
+	}()
+
+	return stub
+}
+
+func (s *smtpStub) serve(conn net.Conn) {
+	protocol := textproto.NewConn(conn)
+	defer protocol.Close()
+
+	_ = protocol.PrintfLine("220 stub ready")
+	for {
+		line, err := protocol.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(strings.Fields(line + " ")[0])
+		switch {
+		case command == "EHLO":
+			_ = protocol.PrintfLine("250-stub\r\n250 8BITMIME")
+		case command == "RCPT" && strings.Contains(line, "rejected@testmail.com"):
+			_ = protocol.PrintfLine("550 mailbox unavailable")
+		case command == "DATA":
+			_ = protocol.PrintfLine("354 go ahead")
+			message, err := protocol.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mutex.Lock()
+			s.messages = append(s.messages, string(message))
+			s.mutex.Unlock()
+			_ = protocol.PrintfLine("250 queued")
+		case command == "QUIT":
+			_ = protocol.PrintfLine("221 bye")
+			return
+		default:
+			_ = protocol.PrintfLine("250 OK")
+		}
+	}
+}
+
+func (s *smtpStub) config(tlsMode TLSMode) SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return SMTPConfig{
+		Host:    host,
+		Port:    port,
+		From:    "Pet Place <noreply@testmail.com>",
+		TLS:     tlsMode,
+		Timeout: time.Second,
+	}
+}
+
+func TestSMTPSendEmailReusesConnections(t *testing.T) {
+	stub := newSMTPStub(t)
+	client := NewSMTPClient(stub.config(NoTLS))
+
+	for _, subject := range []string{"Scheduled notification", "Recordatorio de las pastillas"} {
+		require.NoError(t, client.SendEmail(Mail{
+			To:      "larrycapija@testmail.com",
+			Subject: subject,
+			Body:    "give the pills to Firulais",
+		}))
+	}
+
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+	assert.Equal(t, 1, stub.connections)
+	require.Len(t, stub.messages, 2)
+	assert.Contains(t, stub.messages[0], "To: <larrycapija@testmail.com>")
+	assert.Contains(t, stub.messages[0], "Subject: Scheduled notification")
+	assert.Contains(t, stub.messages[1], "give the pills to Firulais")
+}
+
+func TestSMTPSendMultipartEmail(t *testing.T) {
+	stub := newSMTPStub(t)
+	client := NewSMTPClient(stub.config(NoTLS))
+	require.NoError(t, client.SendEmail(Mail{
+		To:      "larrycapija@testmail.com",
+		Subject: "Reminder",
+		Body:    "give the pills to Firulais",
+		HTML:    "<p>give the pills to <strong>Firulais</strong></p>",
+	}))
+
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+	require.Len(t, stub.messages, 1)
+	message, err := netmail.ReadMessage(strings.NewReader(stub.messages[0]))
+	require.NoError(t, err)
+
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	// The plain text version goes first
+	var contents []string
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		// The reader decodes the quoted-printable parts
+		content, err := io.ReadAll(part)
+		require.NoError(t, err)
+		contents = append(contents, part.Header.Get("Content-Type")+": "+string(content))
+	}
+	assert.Equal(t, []string{
+		"text/plain; charset=UTF-8: give the pills to Firulais",
+		"text/html; charset=UTF-8: <p>give the pills to <strong>Firulais</strong></p>",
+	}, contents)
+}
+
+func TestSMTPSendEmailErrors(t *testing.T) {
+	stub := newSMTPStub(t)
+	client := NewSMTPClient(stub.config(NoTLS))
+
+	// The recipients rejected by the server are not retried
+	err := client.SendEmail(Mail{To: "rejected@testmail.com", Subject: "subject", Body: "body"})
+	assert.ErrorIs(t, err, errRejectedBySMTPServer)
+	var permanent permanentError
+	assert.True(t, errors.As(err, &permanent))
+
+	err = client.SendEmail(Mail{To: "not an email", Subject: "subject", Body: "body"})
+	assert.ErrorIs(t, err, errInvalidRecipient)
+
+	// Credentials are never sent in the clear, so servers without STARTTLS are rejected
+	err = NewSMTPClient(stub.config(StartTLS)).SendEmail(Mail{To: "larrycapija@testmail.com", Subject: "s", Body: "b"})
+	assert.ErrorIs(t, err, errStartTLSUnsupported)
+}
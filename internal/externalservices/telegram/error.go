@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 )
 
 var (
@@ -15,8 +16,45 @@ var (
 	errCreatingRequest            = errors.New("error creating request")
 	errNilResponse                = errors.New("error nil response")
 	errUnmarshallingErrorResponse = errors.New("error unmarshalling error response")
+	errMarshallingRequest         = errors.New("error marshalling notifications")
+	errNotificationRejected       = errors.New("error notification rejected by telegram service")
+	errMissingResult              = errors.New("error missing result of notification")
 )
 
+// permanentRejections reasons of the Telegram Service for notifications that fail again if they are retried: the user
+// blocked the bot or the chat does not exist
+var permanentRejections = []string{"blocked", "chat not found"}
+
+// permanentError error of a notification that fails again if it's retried
+type permanentError struct {
+	err error
+}
+
+func (pe permanentError) Error() string {
+	return pe.err.Error()
+}
+
+func (pe permanentError) Unwrap() error {
+	return pe.err
+}
+
+func (pe permanentError) Permanent() bool {
+	return true
+}
+
+// rejectionError returns the error of a notification rejected by the Telegram Service for the given reason. It's
+// permanent if the reason is one of the permanent rejections
+func rejectionError(reason string) error {
+	err := fmt.Errorf("%w: %s", errNotificationRejected, reason)
+	for _, rejection := range permanentRejections {
+		if strings.Contains(strings.ToLower(reason), rejection) {
+			return permanentError{err: err}
+		}
+	}
+
+	return err
+}
+
 type serviceErrorResponse struct {
 	StatusCode int    `json:"status_code"`
 	Message    string `json:"message"`
@@ -38,5 +76,5 @@ func errPolicyFunc(response *http.Response) error {
 		return fmt.Errorf("%w: %w", errUnmarshallingErrorResponse, err)
 	}
 
-	return fmt.Errorf("%s", errResponse.Message)
+	return fmt.Errorf("status %d: %s", response.StatusCode, errResponse.Message)
 }
@@ -2,14 +2,38 @@ package notification
 
 import "notification-scheduler/internal/domain"
 
+// TelegramNotification notification sent to the Telegram Service. The ID is the one of its delivery, it identifies
+// its result in the summary
 type TelegramNotification struct {
+	ID         string `json:"id"`
 	TelegramID string `json:"telegram_id"`
 	Message    string `json:"message"`
 }
 
-func NewTelegramNotification(notif domain.Notification) TelegramNotification {
+func NewTelegramNotification(deliveryID string, notif domain.Notification) TelegramNotification {
 	return TelegramNotification{
+		ID:         deliveryID,
 		TelegramID: notif.TelegramID,
 		Message:    notif.Message,
 	}
 }
+
+// SendRequest body of the requests to send notifications
+type SendRequest struct {
+	Notifications []TelegramNotification `json:"notifications"`
+}
+
+// Summary response of the Telegram Service with the result of each notification of the request
+type Summary struct {
+	Sent    int      `json:"sent"`
+	Failed  int      `json:"failed"`
+	Results []Result `json:"results"`
+}
+
+// Result of a single notification. Error is the reason why it was not sent
+type Result struct {
+	ID         string `json:"id"`
+	TelegramID string `json:"telegram_id"`
+	Sent       bool   `json:"sent"`
+	Error      string `json:"error,omitempty"`
+}
@@ -1,6 +1,8 @@
 package telegram
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/sirupsen/logrus"
@@ -10,52 +12,139 @@ import (
 	"notification-scheduler/internal/externalservices/telegram/internal/notification"
 	"notification-scheduler/internal/internal/headers"
 	"os"
+	"sort"
 	"time"
 )
 
 const (
 	telegramSecretEnvVar     = "TELEGRAM_SECRET"
 	telegramAccessCodeEnvVar = "TELEGRAM_ACCESS_CODE"
-	url                      = "https://api.lnt.digital/telegram/notifications"
+
+	// DefaultURL endpoint of the Telegram Service that sends the notifications
+	DefaultURL = "https://api.lnt.digital/telegram/notifications"
+	// DefaultChunkSize maximum amount of notifications sent on each request by default
+	DefaultChunkSize = 100
 )
 
+// TelegramConfig configuration of the Telegramer. ChunkSize is the maximum amount of notifications of each request
+type TelegramConfig struct {
+	URL       string
+	ChunkSize int
+}
+
 // Telegramer makes requests against Telegram Service
 type Telegramer struct {
 	clientHTTP http.Client
+	config     TelegramConfig
 }
 
-func NewTelegramer(client http.Client) *Telegramer {
+func NewTelegramer(client http.Client, config TelegramConfig) *Telegramer {
 	return &Telegramer{
 		clientHTTP: client,
+		config:     config,
 	}
 }
 
-// SendNotifications sends all the notifications to Telegram Service
-// ToDo: send chunks of notifications
-func (t *Telegramer) SendNotifications(notifications []domain.Notification) error {
-	var telegramNotifications []notification.TelegramNotification
-	for idx := range notifications {
-		telegramNotifications = append(telegramNotifications, notification.NewTelegramNotification(notifications[idx]))
+// SendNotifications sends all the notifications to Telegram Service, in chunks of the configured size. Notifications
+// are keyed by the ID of their delivery, so the same notification can be sent for several deliveries at once. It
+// returns the result of each delivery: nil if it was sent, otherwise the error. If a request fails, all the
+// deliveries of its chunk get the error of the request
+func (t *Telegramer) SendNotifications(notifications map[string]domain.Notification) map[string]error {
+	chunkSize := t.config.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	deliveryIDs := make([]string, 0, len(notifications))
+	for deliveryID := range notifications {
+		deliveryIDs = append(deliveryIDs, deliveryID)
+	}
+	sort.Strings(deliveryIDs)
+
+	results := make(map[string]error, len(notifications))
+	for start := 0; start < len(deliveryIDs); start += chunkSize {
+		end := start + chunkSize
+		if end > len(deliveryIDs) {
+			end = len(deliveryIDs)
+		}
+
+		t.sendChunk(deliveryIDs[start:end], notifications, results)
 	}
 
-	request, err := http.NewRequest(http.MethodPost, url, nil)
+	return results
+}
+
+// sendChunk sends the notifications of the given deliveries in a single request and saves the result of each one
+func (t *Telegramer) sendChunk(
+	deliveryIDs []string, notifications map[string]domain.Notification, results map[string]error,
+) {
+	summary, err := t.send(deliveryIDs, notifications)
 	if err != nil {
-		err = fmt.Errorf("%w: %v", errCreatingRequest, err)
-		logrus.Errorf("%v", err)
-		return err
+		logrus.Errorf("error sending %d notifications to telegram service: %v", len(deliveryIDs), err)
+		for _, deliveryID := range deliveryIDs {
+			results[deliveryID] = err
+		}
+		return
+	}
+
+	resultsByID := make(map[string]notification.Result, len(summary.Results))
+	for _, result := range summary.Results {
+		resultsByID[result.ID] = result
+	}
+
+	for _, deliveryID := range deliveryIDs {
+		result, found := resultsByID[deliveryID]
+		switch {
+		case !found:
+			results[deliveryID] = fmt.Errorf("%w: %s", errMissingResult, deliveryID)
+		case !result.Sent:
+			results[deliveryID] = rejectionError(result.Error)
+		default:
+			results[deliveryID] = nil
+		}
+	}
+
+	logrus.Infof("Notifications sent to telegram service: %d sent, %d failed", summary.Sent, summary.Failed)
+}
+
+// send performs the request with the notifications of the given deliveries and returns the summary of the Telegram
+// Service
+func (t *Telegramer) send(
+	deliveryIDs []string, notifications map[string]domain.Notification,
+) (notification.Summary, error) {
+	sendRequest := notification.SendRequest{
+		Notifications: make([]notification.TelegramNotification, 0, len(deliveryIDs)),
+	}
+	for _, deliveryID := range deliveryIDs {
+		sendRequest.Notifications = append(sendRequest.Notifications,
+			notification.NewTelegramNotification(deliveryID, notifications[deliveryID]))
+	}
+
+	body, err := json.Marshal(sendRequest)
+	if err != nil {
+		return notification.Summary{}, fmt.Errorf("%w: %v", errMarshallingRequest, err)
+	}
+
+	url := t.config.URL
+	if url == "" {
+		url = DefaultURL
+	}
+
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return notification.Summary{}, fmt.Errorf("%w: %v", errCreatingRequest, err)
 	}
 
 	accessToken, err := createAccessToken()
 	if err != nil {
-		logrus.Errorf("error creating telegram access token: %v", err)
-		return fmt.Errorf("error creating token: %v", err)
+		return notification.Summary{}, fmt.Errorf("error creating token: %v", err)
 	}
 
+	request.Header.Set("Content-Type", "application/json")
 	request.Header.Add(headers.JWT, accessToken)
 	response, err := t.clientHTTP.Do(request)
 	if err != nil {
-		logrus.Errorf("error performing SendNotifications: %v", err)
-		return fmt.Errorf("%w: %v", errPerformingRequest, err)
+		return notification.Summary{}, fmt.Errorf("%w: %v", errPerformingRequest, err)
 	}
 
 	defer func() {
@@ -65,24 +154,26 @@ func (t *Telegramer) SendNotifications(notifications []domain.Notification) erro
 	}()
 
 	if response == nil {
-		logrus.Error("nil response from telegram service")
-		return errNilResponse
+		return notification.Summary{}, errNilResponse
 	}
 
 	err = errPolicyFunc(response)
 	if err != nil {
-		logrus.Errorf("error from telegram service: %v", err)
-		return err
+		return notification.Summary{}, err
 	}
 
 	responseBody, err := io.ReadAll(response.Body)
 	if err != nil {
-		logrus.Errorf("error from telegram service: %v", err)
-		return fmt.Errorf("%w: %v", errUnmarshallingResponse, err)
+		return notification.Summary{}, fmt.Errorf("%w: %v", errReadingResponseBody, err)
+	}
+
+	var summary notification.Summary
+	err = json.Unmarshal(responseBody, &summary)
+	if err != nil {
+		return notification.Summary{}, fmt.Errorf("%w: %v", errUnmarshallingResponse, err)
 	}
-	logrus.Infof("Notification sent summary: %s", string(responseBody))
 
-	return nil
+	return summary, nil
 }
 
 // createAccessToken required token to make requests against Telegram Service
@@ -103,7 +194,7 @@ func createAccessToken() (string, error) {
 			"exp":         time.Now().Add(2 * time.Minute).Unix(),
 		})
 
-	tokenString, err := token.SignedString(secret)
+	tokenString, err := token.SignedString([]byte(secret))
 	if err != nil {
 		return "", err
 	}
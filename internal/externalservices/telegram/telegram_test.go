@@ -0,0 +1,148 @@
+package telegram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/externalservices/telegram/internal/notification"
+	"notification-scheduler/internal/internal/headers"
+	"testing"
+	"time"
+)
+
+// newTelegramService returns a stand-in of the Telegram Service. It records the size of each request and rejects the
+// notifications of the given chats, for the given reasons
+func newTelegramService(t *testing.T, chunkSizes *[]int, rejectedChats map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NotEmpty(t, r.Header.Get(headers.JWT))
+
+		var sendRequest notification.SendRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&sendRequest))
+		*chunkSizes = append(*chunkSizes, len(sendRequest.Notifications))
+
+		var summary notification.Summary
+		for _, telegramNotification := range sendRequest.Notifications {
+			result := notification.Result{
+				ID:         telegramNotification.ID,
+				TelegramID: telegramNotification.TelegramID,
+				Sent:       true,
+			}
+			if reason, rejected := rejectedChats[telegramNotification.TelegramID]; rejected {
+				result.Sent = false
+				result.Error = reason
+			}
+
+			if result.Sent {
+				summary.Sent++
+			} else {
+				summary.Failed++
+			}
+			summary.Results = append(summary.Results, result)
+		}
+
+		_ = json.NewEncoder(w).Encode(summary)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// newTestNotifications returns the given amount of notifications, keyed by the ID of their delivery
+func newTestNotifications(amount int) map[string]domain.Notification {
+	notifications := make(map[string]domain.Notification, amount)
+	for idx := 0; idx < amount; idx++ {
+		notifications[fmt.Sprintf("delivery-%d", idx)] = domain.Notification{
+			ID:         fmt.Sprintf("notification-%d", idx),
+			TelegramID: fmt.Sprintf("chat-%d", idx),
+			Via:        domain.Telegram,
+			Message:    "give the pills to Firulais",
+		}
+	}
+
+	return notifications
+}
+
+func TestSendNotificationsInChunks(t *testing.T) {
+	t.Setenv(telegramAccessCodeEnvVar, "access-code")
+	t.Setenv(telegramSecretEnvVar, "secret")
+
+	var chunkSizes []int
+	server := newTelegramService(t, &chunkSizes, map[string]string{"chat-1": "too many requests", "chat-4": "timeout"})
+	telegramer := NewTelegramer(http.Client{Timeout: time.Second}, TelegramConfig{URL: server.URL, ChunkSize: 2})
+
+	results := telegramer.SendNotifications(newTestNotifications(5))
+	assert.Equal(t, []int{2, 2, 1}, chunkSizes)
+	require.Len(t, results, 5)
+	for deliveryID, err := range results {
+		if deliveryID == "delivery-1" || deliveryID == "delivery-4" {
+			assert.ErrorIs(t, err, errNotificationRejected)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestSendNotificationsOfSeveralDeliveries(t *testing.T) {
+	t.Setenv(telegramAccessCodeEnvVar, "access-code")
+	t.Setenv(telegramSecretEnvVar, "secret")
+
+	var chunkSizes []int
+	server := newTelegramService(t, &chunkSizes, nil)
+	telegramer := NewTelegramer(http.Client{Timeout: time.Second}, TelegramConfig{URL: server.URL})
+
+	// The occurrences of a notification replayed by the catch-up are sent on the same request
+	notification := newTestNotifications(1)["delivery-0"]
+	results := telegramer.SendNotifications(map[string]domain.Notification{
+		"delivery-0": notification,
+		"delivery-1": notification,
+	})
+	assert.Equal(t, []int{2}, chunkSizes)
+	assert.Equal(t, map[string]error{"delivery-0": nil, "delivery-1": nil}, results)
+}
+
+func TestSendNotificationsPermanentRejections(t *testing.T) {
+	t.Setenv(telegramAccessCodeEnvVar, "access-code")
+	t.Setenv(telegramSecretEnvVar, "secret")
+
+	var chunkSizes []int
+	server := newTelegramService(t, &chunkSizes, map[string]string{
+		"chat-0": "Forbidden: bot was blocked by the user",
+		"chat-1": "Bad Request: chat not found",
+		"chat-2": "Too Many Requests: retry after 5",
+	})
+	telegramer := NewTelegramer(http.Client{Timeout: time.Second}, TelegramConfig{URL: server.URL})
+
+	results := telegramer.SendNotifications(newTestNotifications(3))
+	require.Len(t, results, 3)
+	for deliveryID, permanent := range map[string]bool{"delivery-0": true, "delivery-1": true, "delivery-2": false} {
+		err := results[deliveryID]
+		assert.ErrorIs(t, err, errNotificationRejected)
+		var permanentErr permanentError
+		assert.Equal(t, permanent, errors.As(err, &permanentErr), deliveryID)
+	}
+}
+
+func TestSendNotificationsFailedRequest(t *testing.T) {
+	t.Setenv(telegramAccessCodeEnvVar, "access-code")
+	t.Setenv(telegramSecretEnvVar, "secret")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"status_code": 503, "message": "try again later"}`))
+	}))
+	t.Cleanup(server.Close)
+	telegramer := NewTelegramer(http.Client{Timeout: time.Second}, TelegramConfig{URL: server.URL, ChunkSize: 2})
+
+	// Every notification of the chunk gets the error of the request
+	results := telegramer.SendNotifications(newTestNotifications(3))
+	require.Len(t, results, 3)
+	for _, err := range results {
+		assert.ErrorContains(t, err, "try again later")
+	}
+}
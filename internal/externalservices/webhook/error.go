@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	errInvalidURL         = errors.New("error invalid webhook url")
+	errForbiddenAddress   = errors.New("error webhook address is not public")
+	errMarshallingPayload = errors.New("error marshalling webhook payload")
+	errCreatingRequest    = errors.New("error creating request")
+	errPerformingRequest  = errors.New("error performing request")
+	errUnexpectedStatus   = errors.New("error unexpected webhook response status")
+)
+
+// permanentError error of a webhook that fails again if it's retried, for example because the URL does not exist
+type permanentError struct {
+	err error
+}
+
+func (pe permanentError) Error() string {
+	return pe.err.Error()
+}
+
+func (pe permanentError) Unwrap() error {
+	return pe.err
+}
+
+func (pe permanentError) Permanent() bool {
+	return true
+}
+
+// statusPolicyFunc returns nil for successful responses. Timeouts, rate limits and server errors can be retried, the
+// rest of the responses are permanent errors. Redirects are not followed, so they are permanent errors too
+func statusPolicyFunc(statusCode int) error {
+	if statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices {
+		return nil
+	}
+
+	err := fmt.Errorf("%w: %d", errUnexpectedStatus, statusCode)
+	if statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests ||
+		statusCode >= http.StatusInternalServerError {
+		return err
+	}
+
+	return permanentError{err: err}
+}
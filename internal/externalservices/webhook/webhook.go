@@ -0,0 +1,213 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"notification-scheduler/internal/domain"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// SignatureHeader header with the signature of the request: sha256= followed by the hex encoded HMAC-SHA256 of
+	// the timestamp, a dot and the body, keyed with the webhook secret of the user
+	SignatureHeader = "X-Webhook-Signature"
+	// TimestampHeader header with the unix timestamp of the request. Receivers should reject old ones, so a captured
+	// request cannot be replayed
+	TimestampHeader = "X-Webhook-Timestamp"
+
+	// DefaultTimeout maximum time a webhook request takes by default, including the response
+	DefaultTimeout = 10 * time.Second
+
+	// maxResponseSize bytes of the response read before closing it, so the connection can be reused
+	maxResponseSize = 4096
+)
+
+// nonPublicPrefixes networks that are not reachable from the Internet, or that reach IPv4 addresses through IPv6:
+// "this network", the carrier-grade NAT, the NAT64 prefix and 6to4
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// WebhookConfig configuration of the Webhooker
+type WebhookConfig struct {
+	Timeout time.Duration
+}
+
+// Payload body of the webhooks, sent as JSON
+type Payload struct {
+	NotificationID string    `json:"notification_id"`
+	ScheduleID     string    `json:"schedule_id,omitempty"`
+	Message        string    `json:"message"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// Webhooker sends the notifications to the URLs of the users. It only connects to public addresses, checked once the
+// host is resolved, so a URL cannot point to the private network even if its DNS changes after it was validated
+type Webhooker struct {
+	clientHTTP http.Client
+	now        func() time.Time
+}
+
+func NewWebhooker(config WebhookConfig) *Webhooker {
+	return newWebhooker(config, publicAddress)
+}
+
+// newWebhooker returns a Webhooker that only connects to the addresses allowed by the given function
+func newWebhooker(config WebhookConfig, allowed func(addr netip.Addr) bool) *Webhooker {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %v", errForbiddenAddress, err)
+			}
+
+			if !allowed(addrPort.Addr().Unmap()) {
+				return fmt.Errorf("%w: %s", errForbiddenAddress, address)
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Webhooker{
+		clientHTTP: http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+// Send posts the notification to the given URL, signed with the given secret. Errors that fail again if retried,
+// like the rejected requests or forbidden addresses, have a Permanent method that returns true
+func (w *Webhooker) Send(webhookURL string, secret string, notification domain.Notification) error {
+	now := w.now()
+	body, err := json.Marshal(Payload{
+		NotificationID: notification.ID,
+		ScheduleID:     notification.ScheduleID,
+		Message:        notification.Message,
+		Timestamp:      now.UTC(),
+	})
+	if err != nil {
+		return permanentError{err: fmt.Errorf("%w: %v", errMarshallingPayload, err)}
+	}
+
+	request, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err: fmt.Errorf("%w: %v", errCreatingRequest, err)}
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(TimestampHeader, timestamp)
+	request.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+
+	response, err := w.clientHTTP.Do(request)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", errPerformingRequest, err)
+		if errors.Is(err, errForbiddenAddress) {
+			return permanentError{err: err}
+		}
+		return err
+	}
+
+	defer func() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, maxResponseSize))
+		_ = response.Body.Close()
+	}()
+
+	return statusPolicyFunc(response.StatusCode)
+}
+
+// Sign returns the signature of the given body sent at the given timestamp, as it's set in the SignatureHeader
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateURL returns an error unless the URL is an absolute http or https URL without credentials. Hosts that are
+// private IP addresses or localhost are rejected. Names are resolved when the webhook is sent, and checked again then
+func ValidateURL(rawURL string) error {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidURL, err)
+	}
+
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return fmt.Errorf("%w: scheme must be http or https", errInvalidURL)
+	}
+
+	if parsedURL.User != nil {
+		return fmt.Errorf("%w: credentials are not allowed", errInvalidURL)
+	}
+
+	host := strings.ToLower(parsedURL.Hostname())
+	if host == "" {
+		return fmt.Errorf("%w: host is missing", errInvalidURL)
+	}
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", errForbiddenAddress, host)
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err == nil && !publicAddress(addr.Unmap()) {
+		return fmt.Errorf("%w: %s", errForbiddenAddress, host)
+	}
+
+	return nil
+}
+
+// publicAddress returns false for the addresses of the local host and of private networks
+func publicAddress(addr netip.Addr) bool {
+	if !addr.IsValid() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return false
+	}
+
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
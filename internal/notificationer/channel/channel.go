@@ -0,0 +1,67 @@
+package channel
+
+import (
+	"fmt"
+	"notification-scheduler/internal/domain"
+)
+
+// Channel service by which notifications are sent
+type Channel interface {
+	// Name via of the notifications sent by the channel
+	Name() domain.Via
+	// ValidateRecipient returns an error if the notification lacks the recipient the channel needs
+	ValidateRecipient(notification domain.Notification) error
+	// Send sends the notification. It returns an error unless the provider accepted it
+	Send(notification domain.Notification) error
+}
+
+// BatchChannel channel that can send many notifications at once. The notifications are keyed by the ID of their
+// delivery, and SendBatch returns the result of each delivery: nil if it was sent, otherwise the error
+type BatchChannel interface {
+	Channel
+	SendBatch(notifications map[string]domain.Notification) map[string]error
+}
+
+// Registry channels available to send notifications, by name. Adding a channel only means registering it
+type Registry struct {
+	channels map[domain.Via]Channel
+}
+
+func NewRegistry(channels ...Channel) *Registry {
+	registry := &Registry{
+		channels: make(map[domain.Via]Channel, len(channels)),
+	}
+	for _, channel := range channels {
+		registry.channels[channel.Name()] = channel
+	}
+
+	return registry
+}
+
+// Get returns the channel with the given name. An error is returned if it's not registered
+func (r *Registry) Get(name domain.Via) (Channel, error) {
+	channel, found := r.channels[name]
+	if !found {
+		return nil, fmt.Errorf("%w: %s", errUnknownChannel, name)
+	}
+
+	return channel, nil
+}
+
+// ValidateRecipients checks that every channel the notification asks for is registered, and that the notification has
+// the recipient of each one
+func (r *Registry) ValidateRecipients(notification domain.Notification) error {
+	for _, name := range notification.Via.Channels() {
+		channel, err := r.Get(name)
+		if err != nil {
+			return err
+		}
+
+		err = channel.ValidateRecipient(notification)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
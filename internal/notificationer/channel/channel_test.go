@@ -0,0 +1,68 @@
+package channel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"notification-scheduler/internal/domain"
+	"testing"
+)
+
+// secretsMock has the secret of a single user
+type secretsMock struct {
+	email string
+}
+
+type secretNotFoundError struct{}
+
+func (secretNotFoundError) Error() string  { return "webhook secret not found" }
+func (secretNotFoundError) NotFound() bool { return true }
+
+func (sm secretsMock) GetWebhookSecret(email string) (domain.WebhookSecret, error) {
+	if email != sm.email {
+		return domain.WebhookSecret{}, secretNotFoundError{}
+	}
+
+	return domain.WebhookSecret{Email: email, Secret: "secret"}, nil
+}
+
+func TestValidateRecipients(t *testing.T) {
+	registry := NewRegistry(NewMailChannel(nil, nil), NewTelegramChannel(nil))
+	notification := domain.Notification{
+		Email: "larrycapija@testmail.com",
+		Via:   domain.Both,
+	}
+
+	// Both needs the recipients of every channel
+	assert.ErrorIs(t, registry.ValidateRecipients(notification), errMissingTelegramID)
+
+	notification.TelegramID = "larrycapija"
+	assert.NoError(t, registry.ValidateRecipients(notification))
+
+	notification.Email = ""
+	notification.Via = domain.Telegram
+	assert.NoError(t, registry.ValidateRecipients(notification))
+
+	notification.Via = domain.Mail
+	assert.ErrorIs(t, registry.ValidateRecipients(notification), errMissingEmail)
+
+	assert.ErrorIs(t, NewRegistry().ValidateRecipients(notification), errUnknownChannel)
+}
+
+func TestValidateWebhookRecipient(t *testing.T) {
+	webhookChannel := NewWebhookChannel(nil, secretsMock{email: "larrycapija@testmail.com"})
+	notification := domain.Notification{
+		Email: "larrycapija@testmail.com",
+		Via:   domain.Webhook,
+	}
+
+	assert.ErrorIs(t, webhookChannel.ValidateRecipient(notification), errMissingWebhookURL)
+
+	notification.WebhookURL = "http://192.168.0.10/hooks"
+	assert.Error(t, webhookChannel.ValidateRecipient(notification))
+
+	notification.WebhookURL = "https://example.com/hooks/pills"
+	assert.NoError(t, webhookChannel.ValidateRecipient(notification))
+
+	// The user needs a secret to sign the webhooks
+	notification.Email = "sarasa@testmail.com"
+	assert.ErrorIs(t, webhookChannel.ValidateRecipient(notification), errMissingSecret)
+}
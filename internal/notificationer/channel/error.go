@@ -0,0 +1,11 @@
+package channel
+
+import "errors"
+
+var (
+	errUnknownChannel    = errors.New("error unknown channel")
+	errMissingEmail      = errors.New("error missing email")
+	errMissingTelegramID = errors.New("error missing telegramID")
+	errMissingWebhookURL = errors.New("error missing webhook url")
+	errMissingSecret     = errors.New("error missing webhook secret, create one first")
+)
@@ -0,0 +1,56 @@
+package channel
+
+import (
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/externalservices/email"
+	"notification-scheduler/internal/notificationer/templates"
+)
+
+type emailService interface {
+	SendEmail(email email.Mail) error
+}
+
+type emailRenderer interface {
+	Render(name string, data templates.Data) (templates.Email, error)
+}
+
+// MailChannel sends the notifications to the email of the user, rendered with the template of their type
+type MailChannel struct {
+	emailClient emailService
+	renderer    emailRenderer
+}
+
+func NewMailChannel(emailClient emailService, renderer emailRenderer) *MailChannel {
+	return &MailChannel{
+		emailClient: emailClient,
+		renderer:    renderer,
+	}
+}
+
+func (mc *MailChannel) Name() domain.Via {
+	return domain.Mail
+}
+
+func (mc *MailChannel) ValidateRecipient(notification domain.Notification) error {
+	if notification.Email == "" {
+		return errMissingEmail
+	}
+
+	return nil
+}
+
+func (mc *MailChannel) Send(notification domain.Notification) error {
+	rendered, err := mc.renderer.Render(templates.NameOf(notification), templates.NewData(notification))
+	if err != nil {
+		return err
+	}
+
+	mail := email.Mail{
+		To:      notification.Email,
+		Subject: rendered.Subject,
+		Body:    rendered.Text,
+		HTML:    rendered.HTML,
+	}
+
+	return mc.emailClient.SendEmail(mail)
+}
@@ -0,0 +1,40 @@
+package channel
+
+import "notification-scheduler/internal/domain"
+
+type telegramService interface {
+	SendNotifications(notifications map[string]domain.Notification) map[string]error
+}
+
+// TelegramChannel sends the notifications to the Telegram Service, which forwards them to the chat of the user. It's a
+// BatchChannel
+type TelegramChannel struct {
+	telegramer telegramService
+}
+
+func NewTelegramChannel(telegramer telegramService) *TelegramChannel {
+	return &TelegramChannel{
+		telegramer: telegramer,
+	}
+}
+
+func (tc *TelegramChannel) Name() domain.Via {
+	return domain.Telegram
+}
+
+func (tc *TelegramChannel) ValidateRecipient(notification domain.Notification) error {
+	if notification.TelegramID == "" {
+		return errMissingTelegramID
+	}
+
+	return nil
+}
+
+func (tc *TelegramChannel) Send(notification domain.Notification) error {
+	results := tc.telegramer.SendNotifications(map[string]domain.Notification{notification.ID: notification})
+	return results[notification.ID]
+}
+
+func (tc *TelegramChannel) SendBatch(notifications map[string]domain.Notification) map[string]error {
+	return tc.telegramer.SendNotifications(notifications)
+}
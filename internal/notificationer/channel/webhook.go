@@ -0,0 +1,74 @@
+package channel
+
+import (
+	"errors"
+	"fmt"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/externalservices/webhook"
+)
+
+type webhookService interface {
+	Send(webhookURL string, secret string, notification domain.Notification) error
+}
+
+type secretsService interface {
+	GetWebhookSecret(email string) (domain.WebhookSecret, error)
+}
+
+// notFoundError errors of the service for secrets that do not exist
+type notFoundError interface {
+	NotFound() bool
+}
+
+// WebhookChannel posts the notifications to the URL they carry, signed with the webhook secret of the user
+type WebhookChannel struct {
+	webhooker webhookService
+	secrets   secretsService
+}
+
+func NewWebhookChannel(webhooker webhookService, secrets secretsService) *WebhookChannel {
+	return &WebhookChannel{
+		webhooker: webhooker,
+		secrets:   secrets,
+	}
+}
+
+func (wc *WebhookChannel) Name() domain.Via {
+	return domain.Webhook
+}
+
+// ValidateRecipient checks that the URL of the notification is valid and public, and that the user has a secret to
+// sign the webhooks
+func (wc *WebhookChannel) ValidateRecipient(notification domain.Notification) error {
+	if notification.WebhookURL == "" {
+		return errMissingWebhookURL
+	}
+
+	err := webhook.ValidateURL(notification.WebhookURL)
+	if err != nil {
+		return err
+	}
+
+	_, err = wc.secret(notification.Email)
+	return err
+}
+
+// Send posts the notification. If the user has no secret the delivery is retried, in case a new one is created
+func (wc *WebhookChannel) Send(notification domain.Notification) error {
+	secret, err := wc.secret(notification.Email)
+	if err != nil {
+		return err
+	}
+
+	return wc.webhooker.Send(notification.WebhookURL, secret.Secret, notification)
+}
+
+func (wc *WebhookChannel) secret(email string) (domain.WebhookSecret, error) {
+	secret, err := wc.secrets.GetWebhookSecret(email)
+	var notFound notFoundError
+	if errors.As(err, &notFound) && notFound.NotFound() {
+		return domain.WebhookSecret{}, fmt.Errorf("%w: %s", errMissingSecret, email)
+	}
+
+	return secret, err
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"github.com/google/uuid"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/notificationer/db/internal/item"
+	"time"
+)
+
+// creationEntries returns the audit entries of the creation of the given notifications by the given actor
+func creationEntries(createdNotifications []domain.Notification, actor string) ([]domain.AuditEntry, error) {
+	now := time.Now()
+	entries := make([]domain.AuditEntry, 0, len(createdNotifications))
+	for idx := range createdNotifications {
+		entryID, err := uuid.NewV7()
+		if err != nil {
+			return nil, err
+		}
+
+		createdNotification := createdNotifications[idx]
+		entry := domain.NewAuditEntry(entryID.String(), domain.AuditCreated, actor, nil, &createdNotification, now)
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// auditItemsOf returns the items of the given audit entries
+func auditItemsOf(entries []domain.AuditEntry) []item.AuditItem {
+	auditItems := make([]item.AuditItem, 0, len(entries))
+	for idx := range entries {
+		auditItems = append(auditItems, item.CreateItemFromAuditEntry(entries[idx]))
+	}
+
+	return auditItems
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"errors"
+	"github.com/stretchr/testify/require"
+	"notification-scheduler/internal/notificationer/db/dbtest"
+	"path/filepath"
+	"testing"
+)
+
+func TestFakeDBConformance(t *testing.T) {
+	dbtest.Run(t, dbtest.Backend{
+		New: func(t *testing.T) dbtest.Store {
+			return NewFakeDB(nil)
+		},
+		NewFailing: func(t *testing.T) dbtest.Store {
+			return NewFakeDB(errors.New("error injected"))
+		},
+	})
+}
+
+func TestJournaledFakeDBConformance(t *testing.T) {
+	dbtest.Run(t, dbtest.Backend{
+		New: func(t *testing.T) dbtest.Store {
+			fake, err := NewJournaledFakeDB(t.TempDir())
+			require.NoError(t, err)
+			return fake
+		},
+		NewPersistent: func(t *testing.T) (dbtest.Store, func() dbtest.Store) {
+			directory := t.TempDir()
+			fake, err := NewJournaledFakeDB(directory)
+			require.NoError(t, err)
+			return fake, func() dbtest.Store {
+				reopened, err := NewJournaledFakeDB(directory)
+				require.NoError(t, err)
+				return reopened
+			}
+		},
+	})
+}
+
+func TestSQLiteDBConformance(t *testing.T) {
+	dbtest.Run(t, dbtest.Backend{
+		New: func(t *testing.T) dbtest.Store {
+			return newTestSQLiteDB(t)
+		},
+		NewPersistent: func(t *testing.T) (dbtest.Store, func() dbtest.Store) {
+			dbPath := filepath.Join(t.TempDir(), "notifications.db")
+			sqliteDB, err := NewSQLiteDB(dbPath)
+			require.NoError(t, err)
+			return sqliteDB, func() dbtest.Store {
+				require.NoError(t, sqliteDB.Close())
+				reopened, err := NewSQLiteDB(dbPath)
+				require.NoError(t, err)
+				t.Cleanup(func() {
+					_ = reopened.Close()
+				})
+				return reopened
+			}
+		},
+		// A closed database fails every operation
+		NewFailing: func(t *testing.T) dbtest.Store {
+			sqliteDB := newTestSQLiteDB(t)
+			require.NoError(t, sqliteDB.Close())
+			return sqliteDB
+		},
+	})
+}
+
+func TestPersistorConformance(t *testing.T) {
+	dbtest.Run(t, dbtest.Backend{
+		New: func(t *testing.T) dbtest.Store {
+			return newTestPersistor(t)
+		},
+	})
+}
+
+func newTestSQLiteDB(t *testing.T) *SQLiteDB {
+	sqliteDB, err := NewSQLiteDB(filepath.Join(t.TempDir(), "notifications.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = sqliteDB.Close()
+	})
+
+	return sqliteDB
+}
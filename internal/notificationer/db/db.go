@@ -1,24 +1,1079 @@
 package db
 
 import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/guregu/dynamo"
 	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/notificationer/db/internal/item"
+	"notification-scheduler/internal/utils"
+	"strings"
+	"time"
 )
 
+const (
+	emailIndex     = "email-index"
+	emailSortIndex = "email-sort-index"
+	hourIndex      = "hour-index"
+	scheduleIndex  = "schedule-index"
+	pendingIndex   = "pending-index"
+
+	expiringIndex  = "expiring-index"
+	completedIndex = "completed-index"
+	deletedIndex   = "deleted-index"
+	savedIndex     = "saved-index"
+	finishedIndex  = "finished-index"
+
+	timeZoneKind   = "timezone"
+	leaseKind      = "lease"
+	checkpointKind = "checkpoint"
+	webhookKind    = "webhook-secret"
+
+	lastDispatchedSlotName = "last_dispatched_slot"
+	backfillName           = "search_backfill"
+	auditBackfillName      = "audit_backfill"
+	deliveryBackfillName   = "delivery_backfill"
+
+	// indexPollInterval time between checks of the status of an index that is being created
+	indexPollInterval = 5 * time.Second
+)
+
+// addedIndexes indexes of the notifications table added after its first version, that Migrate creates on the
+// existing tables. The email index sorted by hour and ID is used by the searches. The others are the sparse indexes
+// used by the sweeper: completed and deleted notifications are only purged, so their indexes have only the keys
+var addedIndexes = []dynamo.Index{
+	{
+		Name:           emailSortIndex,
+		HashKey:        "email",
+		HashKeyType:    dynamo.StringType,
+		RangeKey:       "sort_key",
+		RangeKeyType:   dynamo.StringType,
+		ProjectionType: dynamo.AllProjection,
+	},
+	{
+		Name:              expiringIndex,
+		HashKey:           "sweep",
+		HashKeyType:       dynamo.StringType,
+		RangeKey:          "end_date_unix",
+		RangeKeyType:      dynamo.NumberType,
+		ProjectionType:    dynamo.IncludeProjection,
+		ProjectionAttribs: []string{"completed_at_unix"},
+	},
+	{
+		Name:           completedIndex,
+		HashKey:        "sweep",
+		HashKeyType:    dynamo.StringType,
+		RangeKey:       "completed_at_unix",
+		RangeKeyType:   dynamo.NumberType,
+		ProjectionType: dynamo.KeysOnlyProjection,
+	},
+	{
+		Name:           deletedIndex,
+		HashKey:        "sweep",
+		HashKeyType:    dynamo.StringType,
+		RangeKey:       "deleted_at_unix",
+		RangeKeyType:   dynamo.NumberType,
+		ProjectionType: dynamo.KeysOnlyProjection,
+	},
+}
+
+// addedAuditIndexes indexes of the audit table added after its first version, that Migrate creates on the existing
+// tables. The index of the entries by save date is used by the sweeper, that only needs their keys
+var addedAuditIndexes = []dynamo.Index{
+	{
+		Name:           savedIndex,
+		HashKey:        "sweep",
+		HashKeyType:    dynamo.StringType,
+		RangeKey:       "timestamp_unix",
+		RangeKeyType:   dynamo.NumberType,
+		ProjectionType: dynamo.KeysOnlyProjection,
+	},
+}
+
+// addedDeliveryIndexes indexes of the deliveries table added after its first version, that Migrate creates on the
+// existing tables. The sparse index of the finished deliveries is used by the sweeper, that only needs their keys
+var addedDeliveryIndexes = []dynamo.Index{
+	{
+		Name:           finishedIndex,
+		HashKey:        "sweep",
+		HashKeyType:    dynamo.StringType,
+		RangeKey:       "finished_at",
+		RangeKeyType:   dynamo.NumberType,
+		ProjectionType: dynamo.KeysOnlyProjection,
+	},
+}
+
+// DynamoConfig configuration needed to connect to DynamoDB. Endpoint is only needed to use DynamoDB Local, and the
+// keys can be omitted to use the default AWS credentials
+type DynamoConfig struct {
+	Region             string
+	Endpoint           string
+	AccessKey          string
+	SecretKey          string
+	NotificationsTable string
+	MetaTable          string
+	AuditTable         string
+	DeliveriesTable    string
+}
+
+// Persistor stores the notifications in DynamoDB. It uses four tables:
+// + Notifications table: one item per notification and hour. Its hash key is the ID of the notification. It has
+// three global secondary indexes: by email, sorted by hour and ID, by schedule and by hour, that is used to find the
+// notifications of a slot. Three more sparse indexes have the notifications with end date, the completed ones and the
+// deleted ones, for the sweeper
+// + Meta table: data of the scheduler itself. Its hash key is the kind of data and its range key the name
+// + Audit table: changes made to the notifications. Its hash key is the ID of the notification and its range key the
+// ID of the entry. Its index has the entries sorted by save date, for the sweeper
+// + Deliveries table: outbox of the deliveries. Its hash key is the ID of the delivery. Its sparse indexes have the
+// pending deliveries sorted by next attempt and, for the sweeper, the finished ones
 type Persistor struct {
-	db *dynamo.DB
+	db                 *dynamo.DB
+	notificationsTable dynamo.Table
+	metaTable          dynamo.Table
+	auditTable         dynamo.Table
+	deliveriesTable    dynamo.Table
+}
+
+func NewPersistor(config *DynamoConfig) (*Persistor, error) {
+	awsConfig := &aws.Config{
+		Region: aws.String(config.Region),
+	}
+	if config.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(config.Endpoint)
+	}
+	if config.AccessKey != "" {
+		awsConfig.Credentials = credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, "")
+	}
+
+	newSession, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errCreatingDynamoSession, err)
+	}
+
+	db := dynamo.New(newSession)
+	return &Persistor{
+		db:                 db,
+		notificationsTable: db.Table(config.NotificationsTable),
+		metaTable:          db.Table(config.MetaTable),
+		auditTable:         db.Table(config.AuditTable),
+		deliveriesTable:    db.Table(config.DeliveriesTable),
+	}, nil
+}
+
+// CreateTables creates the tables used by the Persistor, if they do not exist. Useful for DynamoDB Local
+func (p *Persistor) CreateTables() error {
+	tables, err := p.db.ListTables().All()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errCreatingTables, err)
+	}
+
+	if !utils.Contains(tables, p.notificationsTable.Name()) {
+		createTable := p.db.CreateTable(p.notificationsTable.Name(), item.DynamoNotificationItem{}).
+			OnDemand(true).
+			Project(emailIndex, dynamo.AllProjection).
+			Project(hourIndex, dynamo.AllProjection).
+			Project(scheduleIndex, dynamo.AllProjection)
+		for _, index := range addedIndexes {
+			createTable.Project(index.Name, index.ProjectionType, index.ProjectionAttribs...)
+		}
+
+		err = createTable.Wait()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", errCreatingTables, p.notificationsTable.Name(), err)
+		}
+	}
+
+	if !utils.Contains(tables, p.metaTable.Name()) {
+		err = p.db.CreateTable(p.metaTable.Name(), item.MetaItem{}).OnDemand(true).Wait()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", errCreatingTables, p.metaTable.Name(), err)
+		}
+
+		// Expired leases are removed by DynamoDB
+		err = p.metaTable.UpdateTTL("expires_at", true).Run()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", errCreatingTables, p.metaTable.Name(), err)
+		}
+	}
+
+	if !utils.Contains(tables, p.auditTable.Name()) {
+		createTable := p.db.CreateTable(p.auditTable.Name(), item.DynamoAuditItem{}).OnDemand(true)
+		for _, index := range addedAuditIndexes {
+			createTable.Project(index.Name, index.ProjectionType, index.ProjectionAttribs...)
+		}
+
+		err = createTable.Wait()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", errCreatingTables, p.auditTable.Name(), err)
+		}
+	}
+
+	if !utils.Contains(tables, p.deliveriesTable.Name()) {
+		createTable := p.db.CreateTable(p.deliveriesTable.Name(), item.DynamoDeliveryItem{}).
+			OnDemand(true).
+			Project(pendingIndex, dynamo.AllProjection)
+		for _, index := range addedDeliveryIndexes {
+			createTable.Project(index.Name, index.ProjectionType, index.ProjectionAttribs...)
+		}
+
+		err = createTable.Wait()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", errCreatingTables, p.deliveriesTable.Name(), err)
+		}
+	}
+
+	return nil
 }
 
-func NewPersistor() *Persistor {
-	return &Persistor{}
+// Migrate updates the tables created by previous versions: it creates the indexes they lack, one at a time, and
+// waits until they are active. Then the attributes of the keys of those indexes are set on the items saved before
+// them. Each backfill is recorded in the meta table, so it's done once
+func (p *Persistor) Migrate() error {
+	err := createIndexes(p.notificationsTable, addedIndexes)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errMigrating, err)
+	}
+
+	err = createIndexes(p.auditTable, addedAuditIndexes)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errMigrating, err)
+	}
+
+	err = createIndexes(p.deliveriesTable, addedDeliveryIndexes)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errMigrating, err)
+	}
+
+	err = p.backfillNotifications()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errMigrating, err)
+	}
+
+	err = p.backfillAuditEntries()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errMigrating, err)
+	}
+
+	err = p.backfillDeliveries()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errMigrating, err)
+	}
+
+	return nil
 }
 
-// BatchInsert inserts multiple notifications
-func (p *Persistor) BatchInsert(notifications []domain.Notification) error {
+// createIndexes creates the given indexes that the table lacks, one at a time, and waits until they are active
+func createIndexes(table dynamo.Table, indexes []dynamo.Index) error {
+	description, err := table.Describe().Run()
+	if err != nil {
+		return err
+	}
+
+	for _, index := range indexes {
+		if hasIndex(description, index.Name) {
+			continue
+		}
+
+		_, err = table.UpdateTable().CreateIndex(index).Run()
+		if err != nil {
+			return fmt.Errorf("creating index %s: %v", index.Name, err)
+		}
+
+		err = waitForIndex(table, index.Name)
+		if err != nil {
+			return fmt.Errorf("creating index %s: %v", index.Name, err)
+		}
+	}
+
 	return nil
 }
 
-// BatchGet inserts multiple notifications
-func (p *Persistor) BatchGet(notifications []domain.Notification) ([]domain.Notification, error) {
-	return nil, nil
+// hasIndex returns true if the table has a global secondary index with the given name
+func hasIndex(description dynamo.Description, name string) bool {
+	for _, index := range description.GSI {
+		if index.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// waitForIndex blocks until the index of the table with the given name is active
+func waitForIndex(table dynamo.Table, name string) error {
+	for {
+		description, err := table.Describe().Run()
+		if err != nil {
+			return err
+		}
+
+		for _, index := range description.GSI {
+			if index.Name == name && index.Status == dynamo.ActiveStatus {
+				return nil
+			}
+		}
+
+		time.Sleep(indexPollInterval)
+	}
+}
+
+// backfillNotifications sets the keys of the added indexes, and the message in lower case, on the notifications saved
+// before them. The condition on the message skips the notifications changed after the scan: they were saved whole
+func (p *Persistor) backfillNotifications() error {
+	done, err := p.backfilled(backfillName)
+	if err != nil || done {
+		return err
+	}
+
+	var notifItems []item.DynamoNotificationItem
+	err = p.notificationsTable.Scan().
+		Filter("attribute_not_exists('sweep') OR attribute_not_exists('sort_key')").
+		Project("id", "hour", "message").
+		All(&notifItems)
+	if err != nil {
+		return err
+	}
+
+	for idx := range notifItems {
+		notifItem := item.NewDynamoNotificationItem(notifItems[idx].NotificationItem, notifItems[idx].Hour)
+		update := p.notificationsTable.Update("id", notifItem.ID).
+			Set("sweep", notifItem.Sweep).
+			Set("sort_key", notifItem.SortKey).
+			If("attribute_exists('id')")
+		if notifItem.SearchMessage != "" {
+			update.Set("search_message", notifItem.SearchMessage).If("'message' = ?", notifItem.Message)
+		}
+
+		err = update.Run()
+		// The notification might have been deleted or changed after the scan
+		if err != nil && !dynamo.IsCondCheckFailed(err) {
+			return err
+		}
+	}
+
+	return p.saveBackfill(backfillName)
+}
+
+// backfillAuditEntries sets the keys of the index by save date on the audit entries saved before it
+func (p *Persistor) backfillAuditEntries() error {
+	done, err := p.backfilled(auditBackfillName)
+	if err != nil || done {
+		return err
+	}
+
+	var auditItems []item.AuditItem
+	err = p.auditTable.Scan().
+		Filter("attribute_not_exists('timestamp_unix')").
+		Project("notification_id", "id", "timestamp").
+		All(&auditItems)
+	if err != nil {
+		return err
+	}
+
+	for idx := range auditItems {
+		auditItem := item.NewDynamoAuditItem(auditItems[idx])
+		err = p.auditTable.Update("notification_id", auditItem.NotificationID).
+			Range("id", auditItem.ID).
+			Set("sweep", auditItem.Sweep).
+			Set("timestamp_unix", auditItem.TimestampUnix.Unix()).
+			If("attribute_exists('id')").
+			Run()
+		// The entry might have been purged after the scan
+		if err != nil && !dynamo.IsCondCheckFailed(err) {
+			return err
+		}
+	}
+
+	return p.saveBackfill(auditBackfillName)
+}
+
+// backfillDeliveries sets the hash key of the index of the finished deliveries on the deliveries saved before it.
+// Deliveries are saved whole, so the ones saved after the scan already have it
+func (p *Persistor) backfillDeliveries() error {
+	done, err := p.backfilled(deliveryBackfillName)
+	if err != nil || done {
+		return err
+	}
+
+	var deliveryItems []item.DynamoDeliveryItem
+	err = p.deliveriesTable.Scan().
+		Filter("attribute_not_exists('sweep')").
+		Project("id").
+		All(&deliveryItems)
+	if err != nil {
+		return err
+	}
+
+	for idx := range deliveryItems {
+		err = p.deliveriesTable.Update("id", deliveryItems[idx].ID).
+			Set("sweep", item.SweepValue).
+			If("attribute_exists('id')").
+			Run()
+		// The delivery might have been purged after the scan
+		if err != nil && !dynamo.IsCondCheckFailed(err) {
+			return err
+		}
+	}
+
+	return p.saveBackfill(deliveryBackfillName)
+}
+
+// backfilled returns true if the backfill with the given name was already done
+func (p *Persistor) backfilled(name string) (bool, error) {
+	var checkpoint item.MetaItem
+	err := p.metaTable.Get("kind", checkpointKind).Range("name", dynamo.Equal, name).One(&checkpoint)
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// saveBackfill records that the backfill with the given name is done
+func (p *Persistor) saveBackfill(name string) error {
+	now := time.Now()
+	return p.metaTable.Put(item.MetaItem{
+		Kind: checkpointKind,
+		Name: name,
+		Time: &now,
+	}).Run()
+}
+
+// CreateNotifications creates one item per hour of the notification. All the items, the audit entries of their
+// creation by the given actor and the time zone of the notification are written in the same transaction, that holds
+// up to 100 items: two per hour, plus the time zone
+func (p *Persistor) CreateNotifications(notification domain.Notification, actor string) ([]domain.Notification, error) {
+	transaction := p.db.WriteTx()
+	var createdNotifications []domain.Notification
+	for _, hour := range notification.Hours {
+		if !utils.ValidHour(hour) {
+			return nil, fmt.Errorf("error creating notifications: invalid key")
+		}
+
+		// Keys are always saved as HH:MM
+		hour, _ = utils.NormalizeHour(hour)
+
+		notificationItem := item.CreateItemFromNotification(notification)
+		notificationItem.Version = 1
+		transaction.Put(p.notificationsTable.Put(item.NewDynamoNotificationItem(notificationItem, hour)))
+
+		createdNotification := notificationItem.ToNotification()
+		createdNotification.Hours = []string{hour}
+		createdNotifications = append(createdNotifications, createdNotification)
+	}
+
+	auditEntries, err := creationEntries(createdNotifications, actor)
+	if err != nil {
+		return nil, err
+	}
+	p.putAuditEntries(transaction, auditEntries)
+
+	if notification.TimeZone != "" {
+		transaction.Put(p.metaTable.Put(item.MetaItem{Kind: timeZoneKind, Name: notification.TimeZone}))
+	}
+
+	err = transaction.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	return createdNotifications, nil
+}
+
+func (p *Persistor) GetNotificationsByEmail(email string) ([]domain.Notification, error) {
+	var notifItems []item.DynamoNotificationItem
+	err := p.notificationsTable.Get("email", email).Index(emailIndex).All(&notifItems)
+	if err != nil {
+		return nil, err
+	}
+
+	return toNotifications(notifItems), nil
+}
+
+// SearchNotifications returns a page of the notifications of the user that match the query. It queries the email
+// index, which is sorted by hour and ID, so the page starts right after the cursor
+func (p *Persistor) SearchNotifications(query domain.NotificationQuery) (domain.NotificationPage, error) {
+	total, err := p.searchQuery(query).Count()
+	if err != nil {
+		return domain.NotificationPage{}, err
+	}
+
+	search := p.searchQuery(query)
+	if query.After != nil {
+		search.Range("sort_key", dynamo.Greater, item.SortKey(query.After.Hour, query.After.ID))
+	}
+
+	var notifItems []item.DynamoNotificationItem
+	// The limit is applied after the filters
+	err = search.Limit(int64(query.Limit + 1)).All(&notifItems)
+	if err != nil {
+		return domain.NotificationPage{}, err
+	}
+
+	return domain.NewNotificationPage(toNotifications(notifItems), int(total), query.Limit), nil
+}
+
+// searchQuery returns the query of the email index with the filters of the given query
+func (p *Persistor) searchQuery(query domain.NotificationQuery) *dynamo.Query {
+	search := p.notificationsTable.Get("email", query.Email).Index(emailSortIndex).
+		Filter("attribute_not_exists('deleted_at_unix')")
+
+	if query.Via != "" {
+		search.Filter("'via' = ?", query.Via)
+	}
+
+	switch query.Status {
+	case domain.StatusActive:
+		search.Filter("attribute_not_exists('completed_at_unix') AND "+
+			"(attribute_not_exists('end_date_unix') OR 'end_date_unix' >= ?)", query.Now.Unix())
+	case domain.StatusExpired:
+		search.Filter("attribute_exists('completed_at_unix') OR 'end_date_unix' < ?", query.Now.Unix())
+	}
+
+	if query.HourFrom != "" {
+		search.Filter("'hour' >= ?", query.HourFrom)
+	}
+
+	if query.HourTo != "" {
+		search.Filter("'hour' <= ?", query.HourTo)
+	}
+
+	if query.Text != "" {
+		search.Filter("contains('search_message', ?)", strings.ToLower(query.Text))
+	}
+
+	if query.To != nil {
+		search.Filter("'start_date_unix' <= ?", query.To.Unix())
+	}
+
+	if query.From != nil {
+		search.Filter("attribute_not_exists('end_date_unix') OR 'end_date_unix' >= ?", query.From.Unix())
+	}
+
+	return search
+}
+
+// GetNotificationsBySchedule returns all the notifications of the given schedule. Notifications created before
+// schedules existed are not in the schedule index: they are a schedule by themselves, whose ID is theirs
+func (p *Persistor) GetNotificationsBySchedule(scheduleID string) ([]domain.Notification, error) {
+	var notifItems []item.DynamoNotificationItem
+	err := p.notificationsTable.Get("schedule_id", scheduleID).Index(scheduleIndex).All(&notifItems)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(notifItems) > 0 {
+		return toNotifications(notifItems), nil
+	}
+
+	notification, err := p.GetNotification(scheduleID)
+	if err != nil || notification == nil || notification.ScheduleID != scheduleID {
+		return nil, err
+	}
+
+	return []domain.Notification{*notification}, nil
+}
+
+func (p *Persistor) GetNotification(notificationID string) (*domain.Notification, error) {
+	var notifItem item.DynamoNotificationItem
+	err := p.notificationsTable.Get("id", notificationID).One(&notifItem)
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	notification := toNotification(notifItem)
+	return &notification, nil
+}
+
+// UpdateNotification replaces the notification if its version is the given one, and increments the version. The
+// given audit entries are written in the same transaction. It returns false if there is no notification with that ID
+// and version
+func (p *Persistor) UpdateNotification(
+	updatedNotification domain.Notification, auditEntries ...domain.AuditEntry,
+) (bool, error) {
+	if len(auditEntries) > 0 {
+		return p.UpdateNotifications([]domain.Notification{updatedNotification}, auditEntries...)
+	}
+
+	err := p.notificationUpdate(updatedNotification).Run()
+	if dynamo.IsCondCheckFailed(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// UpdateNotifications replaces all the given notifications in the same transaction, along with the given audit
+// entries. If any of them is not stored with the given version, the transaction is canceled and false is returned. A
+// transaction holds up to 100 items: more than the notifications of a schedule and their entries, two per hour
+func (p *Persistor) UpdateNotifications(
+	updatedNotifications []domain.Notification, auditEntries ...domain.AuditEntry,
+) (bool, error) {
+	transaction := p.db.WriteTx()
+	for idx := range updatedNotifications {
+		transaction.Update(p.notificationUpdate(updatedNotifications[idx]))
+	}
+	p.putAuditEntries(transaction, auditEntries)
+
+	err := transaction.Run()
+	if dynamo.IsCondCheckFailed(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// notificationUpdate returns the update of the notification, with the incremented version, that only succeeds if the
+// stored one has the given version. The last sent and completion dates are kept: they are set by the scheduler, that
+// does not change the version
+func (p *Persistor) notificationUpdate(updatedNotification domain.Notification) *dynamo.Update {
+	hour := updatedNotification.Hours[0]
+	notifItem := item.NewDynamoNotificationItem(item.CreateItemFromNotification(updatedNotification), hour)
+	update := p.notificationsTable.Update("id", notifItem.ID).
+		Set("schedule_id", notifItem.ScheduleID).
+		Set("telegram_id", notifItem.TelegramID).
+		Set("email", notifItem.Email).
+		Set("message", notifItem.Message).
+		Set("search_message", notifItem.SearchMessage).
+		Set("via", notifItem.Via).
+		Set("start_date", notifItem.StartDate).
+		Set("start_date_unix", notifItem.StartDate.Unix()).
+		Set("end_date", notifItem.EndDate).
+		Set("time_zone", notifItem.TimeZone).
+		Set("recurrence", notifItem.Recurrence).
+		Set("fire_at", notifItem.FireAt).
+		Set("catch_up", notifItem.CatchUp).
+		Set("paused", notifItem.Paused).
+		Set("deleted_at", notifItem.DeletedAt).
+		Set("webhook_url", notifItem.WebhookURL).
+		Set("version", notifItem.Version+1).
+		If("attribute_exists('id')").
+		If("'hour' = ?", hour)
+
+	// Empty values remove the attribute, so the unix timestamps are only set with their date
+	unixDates := map[string]*time.Time{
+		"end_date_unix":   notifItem.EndDate,
+		"deleted_at_unix": notifItem.DeletedAt,
+	}
+	for name, date := range unixDates {
+		if date == nil {
+			update.Remove(name)
+		} else {
+			update.Set(name, date.Unix())
+		}
+	}
+
+	if updatedNotification.Version == 0 {
+		// Items created before the versions were added
+		update.If("attribute_not_exists('version')")
+	} else {
+		update.If("'version' = ?", updatedNotification.Version)
+	}
+
+	return update
+}
+
+// SetLastSent records the last slot sent of the notification. Its version is not changed, since the notification
+// was not changed by its user
+func (p *Persistor) SetLastSent(notificationID string, lastSent time.Time) error {
+	err := p.notificationsTable.Update("id", notificationID).
+		Set("last_sent", lastSent).
+		If("attribute_exists('id')").
+		Run()
+	if dynamo.IsCondCheckFailed(err) {
+		return fmt.Errorf("error notification not found")
+	}
+
+	return err
+}
+
+func (p *Persistor) CompleteNotification(notificationID string, completedAt time.Time) error {
+	err := p.completeNotification(notificationID, completedAt)
+	if dynamo.IsCondCheckFailed(err) {
+		return fmt.Errorf("error notification not found")
+	}
+
+	return err
+}
+
+// CompleteExpired completes all the notifications whose end date is before the given instant. They are searched in the
+// sparse index of the notifications with end date
+func (p *Persistor) CompleteExpired(now time.Time) (int, error) {
+	var notifItems []item.DynamoNotificationItem
+	err := p.notificationsTable.Get("sweep", item.SweepValue).
+		Index(expiringIndex).
+		Range("end_date_unix", dynamo.Less, now.Unix()).
+		Filter("attribute_not_exists('completed_at_unix')").
+		All(&notifItems)
+	if err != nil {
+		return 0, err
+	}
+
+	completed := 0
+	for idx := range notifItems {
+		err = p.completeNotification(notifItems[idx].ID, now)
+		// The notification might have been deleted after the query
+		if dynamo.IsCondCheckFailed(err) {
+			continue
+		}
+		if err != nil {
+			return completed, err
+		}
+		completed++
+	}
+
+	return completed, nil
+}
+
+// PurgeCompleted deletes all the notifications completed before the given instant
+func (p *Persistor) PurgeCompleted(completedBefore time.Time) (int, error) {
+	return p.purge(completedIndex, "completed_at_unix", completedBefore)
+}
+
+// PurgeDeleted deletes all the notifications deleted before the given instant. Their audit entries are kept
+func (p *Persistor) PurgeDeleted(deletedBefore time.Time) (int, error) {
+	return p.purge(deletedIndex, "deleted_at_unix", deletedBefore)
+}
+
+// purge deletes all the notifications of the given sparse index whose range key is before the given instant
+func (p *Persistor) purge(index string, rangeKey string, before time.Time) (int, error) {
+	var notifItems []item.DynamoNotificationItem
+	err := p.notificationsTable.Get("sweep", item.SweepValue).
+		Index(index).
+		Range(rangeKey, dynamo.Less, before.Unix()).
+		All(&notifItems)
+	if err != nil {
+		return 0, err
+	}
+
+	var keys []dynamo.Keyed
+	for idx := range notifItems {
+		keys = append(keys, dynamo.Keys{notifItems[idx].ID})
+	}
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	purged, err := p.notificationsTable.Batch("id").Write().Delete(keys...).Run()
+	return purged, err
+}
+
+// DeleteNotification deletes the notification if its version is the given one. Version 0 deletes any version. It
+// returns false if there is no notification with that ID and version
+func (p *Persistor) DeleteNotification(notificationID string, version int64) (bool, error) {
+	deletion := p.notificationsTable.Delete("id", notificationID)
+	if version != 0 {
+		deletion.If("'version' = ?", version)
+	}
+
+	var deletedItem item.DynamoNotificationItem
+	err := deletion.OldValue(&deletedItem)
+	if errors.Is(err, dynamo.ErrNotFound) || dynamo.IsCondCheckFailed(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetAll returns the notifications scheduled at the given hour, using the hour index
+func (p *Persistor) GetAll(key string) ([]domain.Notification, error) {
+	key, err := utils.NormalizeHour(key)
+	if err != nil {
+		return nil, nil
+	}
+
+	var notifItems []item.DynamoNotificationItem
+	err = p.notificationsTable.Get("hour", key).Index(hourIndex).All(&notifItems)
+	if err != nil {
+		return nil, err
+	}
+
+	return toNotifications(notifItems), nil
+}
+
+// GetTimeZones returns all the time zones registered when the notifications were created
+func (p *Persistor) GetTimeZones() ([]string, error) {
+	var metaItems []item.MetaItem
+	err := p.metaTable.Get("kind", timeZoneKind).All(&metaItems)
+	if err != nil {
+		return nil, err
+	}
+
+	var timeZones []string
+	for idx := range metaItems {
+		timeZones = append(timeZones, metaItems[idx].Name)
+	}
+
+	return timeZones, nil
+}
+
+func (p *Persistor) GetLastDispatchedSlot() (*time.Time, error) {
+	var checkpoint item.MetaItem
+	err := p.metaTable.Get("kind", checkpointKind).Range("name", dynamo.Equal, lastDispatchedSlotName).One(&checkpoint)
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return checkpoint.Time, nil
+}
+
+func (p *Persistor) SaveLastDispatchedSlot(slot time.Time) error {
+	return p.metaTable.Put(item.MetaItem{
+		Kind: checkpointKind,
+		Name: lastDispatchedSlotName,
+		Time: &slot,
+	}).Run()
+}
+
+// AcquireLease takes the lease with the given name for the given owner. The lease is written with a conditional
+// put that only succeeds if the lease does not exist, is expired or already belongs to the owner
+func (p *Persistor) AcquireLease(name string, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	err := p.metaTable.Put(item.MetaItem{
+		Kind:      leaseKind,
+		Name:      name,
+		Owner:     owner,
+		ExpiresAt: &expiresAt,
+	}).
+		If("attribute_not_exists('kind') OR 'expires_at' <= ? OR 'owner' = ?", now.Unix(), owner).
+		Run()
+	if dynamo.IsCondCheckFailed(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SaveWebhookSecret saves the webhook secret of the user, replacing the previous one
+func (p *Persistor) SaveWebhookSecret(secret domain.WebhookSecret) error {
+	return p.metaTable.Put(item.MetaItem{
+		Kind:   webhookKind,
+		Name:   secret.Email,
+		Time:   &secret.CreatedAt,
+		Secret: secret.Secret,
+	}).Run()
+}
+
+// GetWebhookSecret returns the webhook secret of the user, nil if there is none
+func (p *Persistor) GetWebhookSecret(email string) (*domain.WebhookSecret, error) {
+	var metaItem item.MetaItem
+	err := p.metaTable.Get("kind", webhookKind).Range("name", dynamo.Equal, email).One(&metaItem)
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	secretItem := item.WebhookSecretItem{Email: metaItem.Name, Secret: metaItem.Secret}
+	if metaItem.Time != nil {
+		secretItem.CreatedAt = *metaItem.Time
+	}
+
+	secret := secretItem.ToWebhookSecret()
+	return &secret, nil
+}
+
+func (p *Persistor) DeleteWebhookSecret(email string) error {
+	return p.metaTable.Delete("kind", webhookKind).Range("name", email).Run()
+}
+
+func (p *Persistor) SaveAuditEntry(entry domain.AuditEntry) error {
+	return p.auditTable.Put(item.NewDynamoAuditItem(item.CreateItemFromAuditEntry(entry))).Run()
+}
+
+// putAuditEntries adds the puts of the given audit entries to the transaction
+func (p *Persistor) putAuditEntries(transaction *dynamo.WriteTx, auditEntries []domain.AuditEntry) {
+	for _, auditItem := range auditItemsOf(auditEntries) {
+		transaction.Put(p.auditTable.Put(item.NewDynamoAuditItem(auditItem)))
+	}
+}
+
+// PurgeAuditEntries deletes the audit entries saved before the given instant. They are searched in the index of the
+// entries by save date
+func (p *Persistor) PurgeAuditEntries(savedBefore time.Time) (int, error) {
+	var auditItems []item.DynamoAuditItem
+	err := p.auditTable.Get("sweep", item.SweepValue).
+		Index(savedIndex).
+		Range("timestamp_unix", dynamo.Less, savedBefore.Unix()).
+		All(&auditItems)
+	if err != nil {
+		return 0, err
+	}
+
+	var keys []dynamo.Keyed
+	for idx := range auditItems {
+		keys = append(keys, dynamo.Keys{auditItems[idx].NotificationID, auditItems[idx].ID})
+	}
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	return p.auditTable.Batch("notification_id", "id").Write().Delete(keys...).Run()
+}
+
+// GetAuditEntries returns the audit entries of the given notification, sorted by ID
+func (p *Persistor) GetAuditEntries(notificationID string) ([]domain.AuditEntry, error) {
+	var auditItems []item.AuditItem
+	err := p.auditTable.Get("notification_id", notificationID).All(&auditItems)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []domain.AuditEntry
+	for idx := range auditItems {
+		entries = append(entries, auditItems[idx].ToAuditEntry())
+	}
+
+	return entries, nil
+}
+
+// EnqueueDeliveries saves the given deliveries, skipping the ones that already exist. It returns the amount saved
+func (p *Persistor) EnqueueDeliveries(deliveries []domain.Delivery) (int, error) {
+	enqueued := 0
+	for idx := range deliveries {
+		deliveryItem := item.NewDynamoDeliveryItem(item.CreateItemFromDelivery(deliveries[idx]))
+		err := p.deliveriesTable.Put(deliveryItem).If("attribute_not_exists('id')").Run()
+		if dynamo.IsCondCheckFailed(err) {
+			continue
+		}
+		if err != nil {
+			return enqueued, err
+		}
+		enqueued++
+	}
+
+	return enqueued, nil
+}
+
+// ClaimDeliveries claims up to limit pending deliveries whose next attempt is not after now, the ones that have
+// waited longer first. Claimed deliveries get one more attempt and are not claimed again until leaseUntil. Each
+// delivery is claimed with a conditional update, so only one instance claims it
+func (p *Persistor) ClaimDeliveries(now time.Time, leaseUntil time.Time, limit int) ([]domain.Delivery, error) {
+	var pendingItems []item.DynamoDeliveryItem
+	err := p.deliveriesTable.Get("pending", item.PendingValue).
+		Index(pendingIndex).
+		Range("next_attempt_at", dynamo.LessOrEqual, now.Unix()).
+		Limit(int64(limit)).
+		All(&pendingItems)
+	if err != nil {
+		return nil, err
+	}
+
+	var claimed []domain.Delivery
+	for idx := range pendingItems {
+		deliveryItem := pendingItems[idx].DeliveryItem
+		err = p.deliveriesTable.Update("id", deliveryItem.ID).
+			Add("attempts", 1).
+			Set("next_attempt_at", leaseUntil.Unix()).
+			If("'pending' = ? AND 'attempts' = ?", item.PendingValue, deliveryItem.Attempts).
+			Run()
+		// Another instance claimed or finished the delivery
+		if dynamo.IsCondCheckFailed(err) {
+			continue
+		}
+		if err != nil {
+			return claimed, err
+		}
+
+		deliveryItem.Attempts++
+		deliveryItem.NextAttemptAt = leaseUntil
+		claimed = append(claimed, deliveryItem.ToDelivery())
+	}
+
+	return claimed, nil
+}
+
+// UpdateDelivery replaces the delivery if it has the same attempts as the saved one, so a worker whose delivery was
+// claimed again by another one does not overwrite it. It returns false if the delivery was not replaced
+func (p *Persistor) UpdateDelivery(delivery domain.Delivery) (bool, error) {
+	deliveryItem := item.NewDynamoDeliveryItem(item.CreateItemFromDelivery(delivery))
+	err := p.deliveriesTable.Put(deliveryItem).If("'attempts' = ?", delivery.Attempts).Run()
+	if dynamo.IsCondCheckFailed(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// PurgeDeliveries deletes the deliveries finished before the given instant. They are searched in the sparse index of
+// the finished deliveries
+func (p *Persistor) PurgeDeliveries(finishedBefore time.Time) (int, error) {
+	var deliveryItems []item.DynamoDeliveryItem
+	err := p.deliveriesTable.Get("sweep", item.SweepValue).
+		Index(finishedIndex).
+		Range("finished_at", dynamo.Less, finishedBefore.Unix()).
+		All(&deliveryItems)
+	if err != nil {
+		return 0, err
+	}
+
+	var keys []dynamo.Keyed
+	for idx := range deliveryItems {
+		keys = append(keys, dynamo.Keys{deliveryItems[idx].ID})
+	}
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	return p.deliveriesTable.Batch("id").Write().Delete(keys...).Run()
+}
+
+func (p *Persistor) completeNotification(notificationID string, completedAt time.Time) error {
+	return p.notificationsTable.Update("id", notificationID).
+		Set("completed_at", completedAt).
+		Set("completed_at_unix", completedAt.Unix()).
+		If("attribute_exists('id')").
+		Run()
+}
+
+func toNotification(notifItem item.DynamoNotificationItem) domain.Notification {
+	notification := notifItem.ToNotification()
+	notification.Hours = []string{notifItem.Hour}
+	return notification
+}
+
+func toNotifications(notifItems []item.DynamoNotificationItem) []domain.Notification {
+	var notifications []domain.Notification
+	for idx := range notifItems {
+		notifications = append(notifications, toNotification(notifItems[idx]))
+	}
+
+	return notifications
 }
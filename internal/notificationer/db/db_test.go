@@ -0,0 +1,118 @@
+package db
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"notification-scheduler/internal/domain"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestPersistor returns a Persistor connected to the DynamoDB Local set in DYNAMO_ENDPOINT, with new tables. The
+// test is skipped if DYNAMO_ENDPOINT is not set
+func newTestPersistor(t *testing.T) *Persistor {
+	endpoint := os.Getenv("DYNAMO_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMO_ENDPOINT not set")
+	}
+
+	suffix := uuid.NewString()
+	persistor, err := NewPersistor(&DynamoConfig{
+		Region:             "us-east-1",
+		Endpoint:           endpoint,
+		AccessKey:          "local",
+		SecretKey:          "local",
+		NotificationsTable: "notifications-" + suffix,
+		MetaTable:          "meta-" + suffix,
+		AuditTable:         "audit-" + suffix,
+		DeliveriesTable:    "deliveries-" + suffix,
+	})
+	require.NoError(t, err)
+	require.NoError(t, persistor.CreateTables())
+	require.NoError(t, persistor.Migrate())
+
+	t.Cleanup(func() {
+		_ = persistor.notificationsTable.DeleteTable().Run()
+		_ = persistor.metaTable.DeleteTable().Run()
+		_ = persistor.auditTable.DeleteTable().Run()
+		_ = persistor.deliveriesTable.DeleteTable().Run()
+	})
+
+	return persistor
+}
+
+func TestPersistor(t *testing.T) {
+	persistor := newTestPersistor(t)
+
+	startDate := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	created, err := persistor.CreateNotifications(domain.Notification{
+		Email:     "larrycapija@testmail.com",
+		Via:       domain.Mail,
+		Message:   "give the pills to Firulais",
+		StartDate: startDate,
+		Hours:     []string{"8:30", "20:00"},
+		TimeZone:  "America/Argentina/Buenos_Aires",
+	}, "user")
+	require.NoError(t, err)
+	require.Len(t, created, 2)
+
+	// Get by ID
+	notification, err := persistor.GetNotification(created[0].ID)
+	require.NoError(t, err)
+	require.NotNil(t, notification)
+	assert.Equal(t, []string{"08:30"}, notification.Hours)
+	assert.True(t, notification.StartDate.Equal(startDate))
+
+	// Get by email
+	userNotifications, err := persistor.GetNotificationsByEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	assert.Len(t, userNotifications, 2)
+
+	// Get by slot
+	slotNotifications, err := persistor.GetAll("20:00")
+	require.NoError(t, err)
+	require.Len(t, slotNotifications, 1)
+	assert.Equal(t, created[1].ID, slotNotifications[0].ID)
+
+	timeZones, err := persistor.GetTimeZones()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"America/Argentina/Buenos_Aires"}, timeZones)
+
+	// Update
+	notification.Message = "give the pills to Firulais and Pepita"
+	updated, err := persistor.UpdateNotification(*notification)
+	require.NoError(t, err)
+	assert.True(t, updated)
+	// The version was incremented, so the same update conflicts
+	updated, err = persistor.UpdateNotification(*notification)
+	require.NoError(t, err)
+	assert.False(t, updated)
+	notification, err = persistor.GetNotification(created[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "give the pills to Firulais and Pepita", notification.Message)
+	assert.Equal(t, int64(2), notification.Version)
+
+	// Delete
+	deleted, err := persistor.DeleteNotification(created[0].ID, 1)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	deleted, err = persistor.DeleteNotification(created[0].ID, notification.Version)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = persistor.DeleteNotification(created[0].ID, 0)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	// Leases
+	acquired, err := persistor.AcquireLease("slot", "replica-1", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+	acquired, err = persistor.AcquireLease("slot", "replica-2", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+	acquired, err = persistor.AcquireLease("slot", "replica-1", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
@@ -0,0 +1,1024 @@
+// Package dbtest contains the conformance suite that every store of notifications must pass, so all of them behave
+// the same way for the service
+package dbtest
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"notification-scheduler/internal/domain"
+	"testing"
+	"time"
+)
+
+// Store methods of a store of notifications. It's the database interface of the service, plus DeleteNotification,
+// that removes a notification right away, and SaveAuditEntry, that saves an entry without a change
+type Store interface {
+	CreateNotifications(notification domain.Notification, actor string) ([]domain.Notification, error)
+	GetNotificationsByEmail(email string) ([]domain.Notification, error)
+	GetNotificationsBySchedule(scheduleID string) ([]domain.Notification, error)
+	SearchNotifications(query domain.NotificationQuery) (domain.NotificationPage, error)
+	GetNotification(notificationID string) (*domain.Notification, error)
+	UpdateNotification(notification domain.Notification, auditEntries ...domain.AuditEntry) (bool, error)
+	UpdateNotifications(notifications []domain.Notification, auditEntries ...domain.AuditEntry) (bool, error)
+	DeleteNotification(notificationID string, version int64) (bool, error)
+	GetAll(currentHour string) ([]domain.Notification, error)
+	GetTimeZones() ([]string, error)
+	SetLastSent(notificationID string, lastSent time.Time) error
+	CompleteNotification(notificationID string, completedAt time.Time) error
+	GetLastDispatchedSlot() (*time.Time, error)
+	SaveLastDispatchedSlot(slot time.Time) error
+	CompleteExpired(now time.Time) (int, error)
+	PurgeCompleted(completedBefore time.Time) (int, error)
+	PurgeDeleted(deletedBefore time.Time) (int, error)
+	SaveAuditEntry(entry domain.AuditEntry) error
+	GetAuditEntries(notificationID string) ([]domain.AuditEntry, error)
+	PurgeAuditEntries(savedBefore time.Time) (int, error)
+	EnqueueDeliveries(deliveries []domain.Delivery) (int, error)
+	ClaimDeliveries(now time.Time, leaseUntil time.Time, limit int) ([]domain.Delivery, error)
+	UpdateDelivery(delivery domain.Delivery) (bool, error)
+	PurgeDeliveries(finishedBefore time.Time) (int, error)
+	SaveWebhookSecret(secret domain.WebhookSecret) error
+	GetWebhookSecret(email string) (*domain.WebhookSecret, error)
+	DeleteWebhookSecret(email string) error
+}
+
+// Backend store under test. New returns an empty store. NewFailing returns a store whose every operation fails. It's
+// optional, for the stores that support error injection. NewPersistent is optional too, for the stores that keep their
+// data on disk: it returns an empty store and a function that closes it and opens a new store over the same data
+type Backend struct {
+	New           func(t *testing.T) Store
+	NewFailing    func(t *testing.T) Store
+	NewPersistent func(t *testing.T) (Store, func() Store)
+}
+
+var startDate = time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+// Run runs the conformance suite against the given backend. Each test case uses a new store
+func Run(t *testing.T, backend Backend) {
+	testCases := []struct {
+		name string
+		test func(t *testing.T, store Store)
+	}{
+		{"creates one notification per hour", testCreatePerHour},
+		{"creates nothing if an hour is invalid", testCreateInvalidHour},
+		{"gets notifications by email", testGetByEmail},
+		{"gets notifications by schedule", testGetBySchedule},
+		{"updates notifications of the same version", testUpdate},
+		{"updates all the notifications or none", testUpdateAll},
+		{"deletes notifications of the same version", testDelete},
+		{"gets the notifications of a slot", testGetAll},
+		{"marks notifications as sent and completed", testSentAndCompleted},
+		{"completes and purges expired notifications", testCompleteAndPurge},
+		{"purges deleted notifications", testPurgeDeleted},
+		{"searches notifications by pages", testSearch},
+		{"saves the last dispatched slot", testLastDispatchedSlot},
+		{"saves audit entries", testAuditEntries},
+		{"saves audit entries with the changes", testAuditedChanges},
+		{"purges old audit entries", testPurgeAuditEntries},
+		{"enqueues each delivery once", testEnqueueDeliveries},
+		{"claims and updates deliveries", testClaimDeliveries},
+		{"narrows the channel of deliveries", testNarrowDeliveryChannel},
+		{"purges finished deliveries", testPurgeDeliveries},
+		{"saves one webhook secret per user", testWebhookSecrets},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.test(t, backend.New(t))
+		})
+	}
+
+	t.Run("returns the injected errors", func(t *testing.T) {
+		if backend.NewFailing == nil {
+			t.Skip("error injection not supported")
+		}
+
+		testFailing(t, backend.NewFailing(t))
+	})
+
+	t.Run("keeps the data when reopened", func(t *testing.T) {
+		if backend.NewPersistent == nil {
+			t.Skip("persistence not supported")
+		}
+
+		store, reopen := backend.NewPersistent(t)
+		testReopen(t, store, reopen)
+	})
+}
+
+// newNotification returns a notification of the given user, to be created at the given hours
+func newNotification(email string, hours ...string) domain.Notification {
+	return domain.Notification{
+		ScheduleID: uuid.NewString(),
+		Email:      email,
+		Via:        domain.Mail,
+		Message:    "give the pills to Firulais",
+		StartDate:  startDate,
+		Hours:      hours,
+		TimeZone:   "America/Argentina/Buenos_Aires",
+	}
+}
+
+func create(t *testing.T, store Store, notification domain.Notification) []domain.Notification {
+	created, err := store.CreateNotifications(notification, "user")
+	require.NoError(t, err)
+	return created
+}
+
+func get(t *testing.T, store Store, notificationID string) *domain.Notification {
+	notification, err := store.GetNotification(notificationID)
+	require.NoError(t, err)
+	return notification
+}
+
+func idsOf(notifications []domain.Notification) []string {
+	var notificationIDs []string
+	for idx := range notifications {
+		notificationIDs = append(notificationIDs, notifications[idx].ID)
+	}
+
+	return notificationIDs
+}
+
+func testCreatePerHour(t *testing.T, store Store) {
+	notification := newNotification("larrycapija@testmail.com", "8:30", "20:00")
+	created := create(t, store, notification)
+	require.Len(t, created, 2)
+	assert.NotEqual(t, created[0].ID, created[1].ID)
+
+	// Hours are normalized
+	for idx, hour := range []string{"08:30", "20:00"} {
+		assert.Equal(t, []string{hour}, created[idx].Hours)
+		assert.Equal(t, notification.ScheduleID, created[idx].ScheduleID)
+		assert.Equal(t, int64(1), created[idx].Version)
+
+		stored := get(t, store, created[idx].ID)
+		require.NotNil(t, stored)
+		assert.Equal(t, []string{hour}, stored.Hours)
+		assert.Equal(t, notification.Email, stored.Email)
+		assert.Equal(t, notification.Message, stored.Message)
+		assert.Equal(t, notification.Via, stored.Via)
+		assert.Equal(t, notification.TimeZone, stored.TimeZone)
+		assert.True(t, stored.StartDate.Equal(startDate))
+		assert.Nil(t, stored.EndDate)
+		assert.Nil(t, stored.DeletedAt)
+		assert.Equal(t, int64(1), stored.Version)
+	}
+
+	timeZones, err := store.GetTimeZones()
+	require.NoError(t, err)
+	assert.Contains(t, timeZones, notification.TimeZone)
+
+	assert.Nil(t, get(t, store, uuid.NewString()))
+}
+
+func testCreateInvalidHour(t *testing.T, store Store) {
+	_, err := store.CreateNotifications(newNotification("larrycapija@testmail.com", "08:30", "08:15"), "user")
+	require.Error(t, err)
+
+	notifications, err := store.GetNotificationsByEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+}
+
+func testGetByEmail(t *testing.T, store Store) {
+	created := create(t, store, newNotification("larrycapija@testmail.com", "08:30", "20:00"))
+	create(t, store, newNotification("pepitapistolera@testmail.com", "08:30"))
+
+	notifications, err := store.GetNotificationsByEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, idsOf(created), idsOf(notifications))
+
+	notifications, err = store.GetNotificationsByEmail("nobody@testmail.com")
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+}
+
+func testGetBySchedule(t *testing.T, store Store) {
+	notification := newNotification("larrycapija@testmail.com", "08:30", "20:00")
+	created := create(t, store, notification)
+	create(t, store, newNotification("larrycapija@testmail.com", "08:30"))
+
+	notifications, err := store.GetNotificationsBySchedule(notification.ScheduleID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, idsOf(created), idsOf(notifications))
+
+	// Notifications created before schedules existed are a schedule by themselves
+	withoutSchedule := newNotification("larrycapija@testmail.com", "08:30")
+	withoutSchedule.ScheduleID = ""
+	created = create(t, store, withoutSchedule)
+	assert.Equal(t, created[0].ID, get(t, store, created[0].ID).ScheduleID)
+
+	notifications, err = store.GetNotificationsBySchedule(created[0].ID)
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, created[0].ID, notifications[0].ID)
+	assert.Equal(t, created[0].ID, notifications[0].ScheduleID)
+}
+
+func testUpdate(t *testing.T, store Store) {
+	created := create(t, store, newNotification("larrycapija@testmail.com", "08:30"))
+
+	notification := *get(t, store, created[0].ID)
+	endDate := startDate.Add(30 * 24 * time.Hour)
+	notification.Message = "give the pills to Firulais and Pepita"
+	notification.EndDate = &endDate
+	notification.Paused = true
+	notification.Via = domain.Webhook
+	notification.WebhookURL = "https://example.com/hooks/pills"
+	updated, err := store.UpdateNotification(notification)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	stored := get(t, store, created[0].ID)
+	require.NotNil(t, stored)
+	assert.Equal(t, notification.Message, stored.Message)
+	require.NotNil(t, stored.EndDate)
+	assert.True(t, stored.EndDate.Equal(endDate))
+	assert.True(t, stored.Paused)
+	assert.Equal(t, notification.WebhookURL, stored.WebhookURL)
+	assert.Equal(t, []string{"08:30"}, stored.Hours)
+	assert.Equal(t, int64(2), stored.Version)
+
+	// The version read first is not the stored one anymore
+	updated, err = store.UpdateNotification(notification)
+	require.NoError(t, err)
+	assert.False(t, updated)
+
+	// Each notification is sent at a single hour, that can't be changed
+	notification.Version = stored.Version
+	notification.Hours = []string{"20:00"}
+	updated, err = store.UpdateNotification(notification)
+	require.NoError(t, err)
+	assert.False(t, updated)
+
+	notification.Hours = []string{"08:30"}
+	notification.ID = uuid.NewString()
+	updated, err = store.UpdateNotification(notification)
+	require.NoError(t, err)
+	assert.False(t, updated)
+}
+
+func testUpdateAll(t *testing.T, store Store) {
+	created := create(t, store, newNotification("larrycapija@testmail.com", "08:30", "20:00"))
+	for idx := range created {
+		created[idx].Message = "give the pills to Firulais and Pepita"
+	}
+
+	updated, err := store.UpdateNotifications(created)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	for idx := range created {
+		stored := get(t, store, created[idx].ID)
+		require.NotNil(t, stored)
+		assert.Equal(t, "give the pills to Firulais and Pepita", stored.Message)
+		assert.Equal(t, int64(2), stored.Version)
+	}
+
+	// The first notification has the stored version but the second does not: none is updated
+	created[0].Version = 2
+	created[0].Message = "walk Firulais"
+	created[1].Message = "walk Firulais"
+	updated, err = store.UpdateNotifications(created)
+	require.NoError(t, err)
+	assert.False(t, updated)
+
+	for idx := range created {
+		stored := get(t, store, created[idx].ID)
+		require.NotNil(t, stored)
+		assert.Equal(t, "give the pills to Firulais and Pepita", stored.Message)
+		assert.Equal(t, int64(2), stored.Version)
+	}
+}
+
+func testDelete(t *testing.T, store Store) {
+	created := create(t, store, newNotification("larrycapija@testmail.com", "08:30", "20:00"))
+
+	deleted, err := store.DeleteNotification(created[0].ID, 2)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	require.NotNil(t, get(t, store, created[0].ID))
+
+	deleted, err = store.DeleteNotification(created[0].ID, 1)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Nil(t, get(t, store, created[0].ID))
+
+	deleted, err = store.DeleteNotification(created[0].ID, 0)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	// Version 0 deletes any version
+	deleted, err = store.DeleteNotification(created[1].ID, 0)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	notifications, err := store.GetNotificationsByEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+}
+
+func testGetAll(t *testing.T, store Store) {
+	first := create(t, store, newNotification("larrycapija@testmail.com", "08:30", "20:00"))
+	second := create(t, store, newNotification("pepitapistolera@testmail.com", "08:30"))
+
+	for _, key := range []string{"08:30", "8:30"} {
+		notifications, err := store.GetAll(key)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{first[0].ID, second[0].ID}, idsOf(notifications))
+		for idx := range notifications {
+			assert.Equal(t, []string{"08:30"}, notifications[idx].Hours)
+		}
+	}
+
+	notifications, err := store.GetAll("21:00")
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+
+	notifications, err = store.GetAll("not an hour")
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+}
+
+func testSentAndCompleted(t *testing.T, store Store) {
+	created := create(t, store, newNotification("larrycapija@testmail.com", "08:30"))
+	read := *get(t, store, created[0].ID)
+
+	// The scheduler does not change the version
+	slot := time.Date(2024, 7, 2, 11, 30, 0, 0, time.UTC)
+	require.NoError(t, store.SetLastSent(created[0].ID, slot))
+	stored := get(t, store, created[0].ID)
+	require.NotNil(t, stored.LastSent)
+	assert.True(t, stored.LastSent.Equal(slot))
+	assert.Equal(t, int64(1), stored.Version)
+
+	completedAt := slot.Add(time.Hour)
+	require.NoError(t, store.CompleteNotification(created[0].ID, completedAt))
+	stored = get(t, store, created[0].ID)
+	require.NotNil(t, stored.CompletedAt)
+	assert.True(t, stored.CompletedAt.Equal(completedAt))
+	assert.Equal(t, int64(1), stored.Version)
+
+	// An update of the notification read before keeps them
+	read.Message = "give the pills to Firulais and Pepita"
+	updated, err := store.UpdateNotification(read)
+	require.NoError(t, err)
+	require.True(t, updated)
+	stored = get(t, store, created[0].ID)
+	assert.Equal(t, read.Message, stored.Message)
+	require.NotNil(t, stored.LastSent)
+	assert.True(t, stored.LastSent.Equal(slot))
+	require.NotNil(t, stored.CompletedAt)
+	assert.True(t, stored.CompletedAt.Equal(completedAt))
+
+	assert.Error(t, store.SetLastSent(uuid.NewString(), slot))
+	assert.Error(t, store.CompleteNotification(uuid.NewString(), completedAt))
+}
+
+func testCompleteAndPurge(t *testing.T, store Store) {
+	now := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+	expiredEndDate := now.Add(-time.Hour)
+	activeEndDate := now.Add(time.Hour)
+
+	expiredNotification := newNotification("larrycapija@testmail.com", "08:30", "20:00")
+	expiredNotification.EndDate = &expiredEndDate
+	expired := create(t, store, expiredNotification)
+	activeNotification := newNotification("larrycapija@testmail.com", "08:30")
+	activeNotification.EndDate = &activeEndDate
+	active := create(t, store, activeNotification)
+	endless := create(t, store, newNotification("larrycapija@testmail.com", "08:30"))
+
+	completed, err := store.CompleteExpired(now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, completed)
+
+	for _, notification := range expired {
+		stored := get(t, store, notification.ID)
+		require.NotNil(t, stored.CompletedAt)
+		assert.True(t, stored.CompletedAt.Equal(now))
+		assert.Equal(t, int64(1), stored.Version)
+	}
+	assert.Nil(t, get(t, store, active[0].ID).CompletedAt)
+	assert.Nil(t, get(t, store, endless[0].ID).CompletedAt)
+
+	// Already completed notifications are not completed again
+	completed, err = store.CompleteExpired(now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 0, completed)
+
+	purged, err := store.PurgeCompleted(now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, purged)
+
+	purged, err = store.PurgeCompleted(now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 2, purged)
+
+	notifications, err := store.GetNotificationsByEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{active[0].ID, endless[0].ID}, idsOf(notifications))
+}
+
+func testPurgeDeleted(t *testing.T, store Store) {
+	created := create(t, store, newNotification("larrycapija@testmail.com", "08:30", "20:00"))
+
+	deletedAt := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+	notification := *get(t, store, created[0].ID)
+	notification.DeletedAt = &deletedAt
+	updated, err := store.UpdateNotification(notification)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	stored := get(t, store, created[0].ID)
+	require.NotNil(t, stored.DeletedAt)
+	assert.True(t, stored.DeletedAt.Equal(deletedAt))
+
+	purged, err := store.PurgeDeleted(deletedAt)
+	require.NoError(t, err)
+	assert.Equal(t, 0, purged)
+
+	purged, err = store.PurgeDeleted(deletedAt.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.Nil(t, get(t, store, created[0].ID))
+	assert.NotNil(t, get(t, store, created[1].ID))
+}
+
+func testSearch(t *testing.T, store Store) {
+	email := "larrycapija@testmail.com"
+	created := create(t, store, newNotification(email, "10:00", "08:00", "09:00", "11:00"))
+	create(t, store, newNotification("pepitapistolera@testmail.com", "08:00"))
+
+	// Deleted notifications are never found
+	deletedAt := startDate
+	deleted := *get(t, store, created[3].ID)
+	deleted.DeletedAt = &deletedAt
+	updated, err := store.UpdateNotification(deleted)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	query := domain.NotificationQuery{
+		Email: email,
+		Now:   startDate,
+		Limit: 2,
+	}
+	page, err := store.SearchNotifications(query)
+	require.NoError(t, err)
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, []string{created[1].ID, created[2].ID}, idsOf(page.Notifications))
+	require.NotNil(t, page.Next)
+
+	query.After = page.Next
+	page, err = store.SearchNotifications(query)
+	require.NoError(t, err)
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, []string{created[0].ID}, idsOf(page.Notifications))
+	assert.Nil(t, page.Next)
+
+	query = domain.NotificationQuery{
+		Email:    email,
+		Now:      startDate,
+		HourFrom: "09:00",
+		Text:     "FIRULAIS",
+		Limit:    10,
+	}
+	page, err = store.SearchNotifications(query)
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, []string{created[2].ID, created[0].ID}, idsOf(page.Notifications))
+
+	// Case is ignored beyond ASCII letters
+	notification := newNotification(email, "12:00")
+	notification.Message = "Dar de comer al ÑANDÚ"
+	accented := create(t, store, notification)
+	query = domain.NotificationQuery{Email: email, Now: startDate, Text: "ñandú", Limit: 10}
+	page, err = store.SearchNotifications(query)
+	require.NoError(t, err)
+	assert.Equal(t, idsOf(accented), idsOf(page.Notifications))
+}
+
+func testLastDispatchedSlot(t *testing.T, store Store) {
+	slot, err := store.GetLastDispatchedSlot()
+	require.NoError(t, err)
+	assert.Nil(t, slot)
+
+	for _, lastSlot := range []time.Time{startDate, startDate.Add(30 * time.Minute)} {
+		require.NoError(t, store.SaveLastDispatchedSlot(lastSlot))
+		slot, err = store.GetLastDispatchedSlot()
+		require.NoError(t, err)
+		require.NotNil(t, slot)
+		assert.True(t, slot.Equal(lastSlot))
+	}
+}
+
+func testAuditEntries(t *testing.T, store Store) {
+	before := newNotification("larrycapija@testmail.com", "08:30")
+	before.ID = uuid.NewString()
+	before.Version = 1
+	after := before
+	after.Message = "give the pills to Firulais and Pepita"
+	after.Version++
+
+	// Saved out of order, returned sorted by ID
+	var entries []domain.AuditEntry
+	for idx := 0; idx < 3; idx++ {
+		entryID, err := uuid.NewV7()
+		require.NoError(t, err)
+		entries = append(entries, domain.NewAuditEntry(entryID.String(), domain.AuditUpdated, "user", &before, &after, startDate))
+	}
+	entries[0].Action = domain.AuditCreated
+	entries[0].Before = nil
+	for _, idx := range []int{2, 0, 1} {
+		require.NoError(t, store.SaveAuditEntry(entries[idx]))
+	}
+
+	stored, err := store.GetAuditEntries(before.ID)
+	require.NoError(t, err)
+	require.Len(t, stored, 3)
+	for idx := range entries {
+		assert.Equal(t, entries[idx].ID, stored[idx].ID)
+		assert.Equal(t, entries[idx].Action, stored[idx].Action)
+		assert.Equal(t, "user", stored[idx].Actor)
+		assert.Equal(t, before.Email, stored[idx].Email)
+		assert.True(t, stored[idx].Timestamp.Equal(startDate))
+		require.NotNil(t, stored[idx].After)
+		assert.Equal(t, after.Message, stored[idx].After.Message)
+		assert.Equal(t, []string{"08:30"}, stored[idx].After.Hours)
+		assert.Equal(t, after.Version, stored[idx].After.Version)
+	}
+	assert.Nil(t, stored[0].Before)
+	require.NotNil(t, stored[1].Before)
+	assert.Equal(t, before.Message, stored[1].Before.Message)
+
+	stored, err = store.GetAuditEntries(uuid.NewString())
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+}
+
+// newUpdateEntry returns the audit entry of the given update, saved at the given instant
+func newUpdateEntry(
+	t *testing.T, before domain.Notification, after domain.Notification, timestamp time.Time,
+) domain.AuditEntry {
+	entryID, err := uuid.NewV7()
+	require.NoError(t, err)
+	after.Version++
+	return domain.NewAuditEntry(entryID.String(), domain.AuditUpdated, "user", &before, &after, timestamp)
+}
+
+func testAuditedChanges(t *testing.T, store Store) {
+	created := create(t, store, newNotification("larrycapija@testmail.com", "08:30", "20:00"))
+	for idx := range created {
+		entries, err := store.GetAuditEntries(created[idx].ID)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, domain.AuditCreated, entries[0].Action)
+		assert.Equal(t, "user", entries[0].Actor)
+		assert.Nil(t, entries[0].Before)
+		require.NotNil(t, entries[0].After)
+		assert.Equal(t, created[idx].Hours, entries[0].After.Hours)
+		assert.Equal(t, int64(1), entries[0].After.Version)
+	}
+
+	updated := created[0]
+	updated.Message = "give the pills to Firulais and Pepita"
+	entry := newUpdateEntry(t, created[0], updated, startDate)
+	ok, err := store.UpdateNotification(updated, entry)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	entries, err := store.GetAuditEntries(created[0].ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, entry.ID, entries[1].ID)
+	assert.Equal(t, int64(2), entries[1].After.Version)
+
+	// A stale version writes neither the notification nor the entry
+	ok, err = store.UpdateNotification(updated, newUpdateEntry(t, created[0], updated, startDate))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	entries, err = store.GetAuditEntries(created[0].ID)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	// Neither do the other notifications written with it
+	second := created[1]
+	second.Message = updated.Message
+	ok, err = store.UpdateNotifications(
+		[]domain.Notification{second, updated},
+		newUpdateEntry(t, created[1], second, startDate), newUpdateEntry(t, created[0], updated, startDate),
+	)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	entries, err = store.GetAuditEntries(created[1].ID)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	ok, err = store.UpdateNotifications([]domain.Notification{second}, newUpdateEntry(t, created[1], second, startDate))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	entries, err = store.GetAuditEntries(created[1].ID)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func testPurgeAuditEntries(t *testing.T, store Store) {
+	created := create(t, store, newNotification("larrycapija@testmail.com", "08:30"))
+	updated := created[0]
+	updated.Message = "give the pills to Firulais and Pepita"
+	old := newUpdateEntry(t, created[0], updated, startDate)
+	recent := newUpdateEntry(t, created[0], updated, startDate.Add(48*time.Hour))
+	require.NoError(t, store.SaveAuditEntry(old))
+	require.NoError(t, store.SaveAuditEntry(recent))
+
+	purged, err := store.PurgeAuditEntries(startDate.Add(24 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	// The creation entry was saved now, so it's kept with the recent one
+	entries, err := store.GetAuditEntries(created[0].ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, domain.AuditCreated, entries[0].Action)
+	assert.Equal(t, recent.ID, entries[1].ID)
+
+	purged, err = store.PurgeAuditEntries(startDate.Add(24 * time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+}
+
+func testEnqueueDeliveries(t *testing.T, store Store) {
+	deliveries := []domain.Delivery{
+		domain.NewDelivery("1", domain.Mail, startDate, startDate),
+		domain.NewDelivery("2", domain.Mail, startDate, startDate),
+	}
+	enqueued, err := store.EnqueueDeliveries(deliveries)
+	require.NoError(t, err)
+	assert.Equal(t, 2, enqueued)
+
+	// The same occurrence is skipped, unless it's sent through another channel
+	deliveries = append(deliveries, domain.NewDelivery("1", domain.Telegram, startDate, startDate))
+	enqueued, err = store.EnqueueDeliveries(deliveries)
+	require.NoError(t, err)
+	assert.Equal(t, 1, enqueued)
+
+	claimed, err := store.ClaimDeliveries(startDate, startDate.Add(time.Minute), 10)
+	require.NoError(t, err)
+	assert.Len(t, claimed, 3)
+}
+
+func testClaimDeliveries(t *testing.T, store Store) {
+	leaseUntil := startDate.Add(time.Hour)
+	deliveries := []domain.Delivery{
+		domain.NewDelivery("1", domain.Mail, startDate.Add(30*time.Minute), startDate),
+		domain.NewDelivery("2", domain.Mail, startDate, startDate),
+		domain.NewDelivery("3", domain.Mail, startDate, startDate.Add(time.Hour)),
+	}
+	_, err := store.EnqueueDeliveries(deliveries)
+	require.NoError(t, err)
+
+	// Deliveries are claimed by next attempt and slot, the ones not due yet are left
+	claimed, err := store.ClaimDeliveries(startDate, leaseUntil, 1)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, deliveries[1].ID, claimed[0].ID)
+	assert.Equal(t, domain.Mail, claimed[0].Channel)
+	assert.Equal(t, 1, claimed[0].Attempts)
+	assert.True(t, claimed[0].NextAttemptAt.Equal(leaseUntil))
+
+	claimed, err = store.ClaimDeliveries(startDate, leaseUntil, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, deliveries[0].ID, claimed[0].ID)
+
+	// A failed attempt is retried later
+	retried := claimed[0]
+	retried.Retry("provider unavailable", startDate.Add(5*time.Minute))
+	updated, err := store.UpdateDelivery(retried)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	claimed, err = store.ClaimDeliveries(startDate.Add(4*time.Minute), startDate.Add(6*time.Minute), 10)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	// Once the lease expires the delivery is claimed again, and the result of the previous attempt is discarded
+	claimed, err = store.ClaimDeliveries(startDate.Add(5*time.Minute), startDate.Add(7*time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, 2, claimed[0].Attempts)
+	assert.Equal(t, "provider unavailable", claimed[0].LastError)
+	assert.True(t, claimed[0].Slot.Equal(deliveries[0].Slot))
+
+	updated, err = store.UpdateDelivery(retried)
+	require.NoError(t, err)
+	assert.False(t, updated)
+
+	sent := claimed[0]
+	sent.Finish(domain.DeliverySent, "", startDate.Add(6*time.Minute))
+	updated, err = store.UpdateDelivery(sent)
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	// Finished deliveries are never claimed
+	claimed, err = store.ClaimDeliveries(startDate.Add(24*time.Hour), startDate.Add(25*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	assert.NotContains(t, []string{claimed[0].ID, claimed[1].ID}, sent.ID)
+}
+
+func testNarrowDeliveryChannel(t *testing.T, store Store) {
+	_, err := store.EnqueueDeliveries([]domain.Delivery{domain.NewDelivery("1", "", startDate, startDate)})
+	require.NoError(t, err)
+
+	// Deliveries without channel that failed through one of them are retried only through that one
+	claimed, err := store.ClaimDeliveries(startDate, startDate.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	claimed[0].Channel = domain.Telegram
+	claimed[0].Retry("chat not found", startDate.Add(time.Hour))
+	updated, err := store.UpdateDelivery(claimed[0])
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	claimed, err = store.ClaimDeliveries(startDate.Add(time.Hour), startDate.Add(2*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, domain.DeliveryID("1", "", startDate), claimed[0].ID)
+	assert.Equal(t, domain.Telegram, claimed[0].Channel)
+}
+
+func testPurgeDeliveries(t *testing.T, store Store) {
+	deliveries := []domain.Delivery{
+		domain.NewDelivery("1", domain.Mail, startDate, startDate),
+		domain.NewDelivery("2", domain.Mail, startDate, startDate),
+		domain.NewDelivery("3", domain.Mail, startDate, startDate),
+	}
+	_, err := store.EnqueueDeliveries(deliveries)
+	require.NoError(t, err)
+
+	claimed, err := store.ClaimDeliveries(startDate, startDate.Add(time.Minute), 2)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	claimed[0].Finish(domain.DeliverySent, "", startDate)
+	claimed[1].Finish(domain.DeliveryFailed, "invalid recipient", startDate.Add(time.Hour))
+	for idx := range claimed {
+		_, err = store.UpdateDelivery(claimed[idx])
+		require.NoError(t, err)
+	}
+
+	purged, err := store.PurgeDeliveries(startDate.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	// The purged delivery can be enqueued again, the rest are skipped
+	enqueued, err := store.EnqueueDeliveries(deliveries)
+	require.NoError(t, err)
+	assert.Equal(t, 1, enqueued)
+}
+
+func testWebhookSecrets(t *testing.T, store Store) {
+	email := "larrycapija@testmail.com"
+	secret, err := store.GetWebhookSecret(email)
+	require.NoError(t, err)
+	assert.Nil(t, secret)
+
+	// Saving a secret replaces the previous one
+	for idx, value := range []string{"first-secret", "second-secret"} {
+		createdAt := startDate.Add(time.Duration(idx) * time.Hour)
+		require.NoError(t, store.SaveWebhookSecret(domain.WebhookSecret{Email: email, Secret: value, CreatedAt: createdAt}))
+
+		secret, err = store.GetWebhookSecret(email)
+		require.NoError(t, err)
+		require.NotNil(t, secret)
+		assert.Equal(t, value, secret.Secret)
+		assert.True(t, secret.CreatedAt.Equal(createdAt))
+	}
+
+	secret, err = store.GetWebhookSecret("sarasa@testmail.com")
+	require.NoError(t, err)
+	assert.Nil(t, secret)
+
+	require.NoError(t, store.DeleteWebhookSecret(email))
+	secret, err = store.GetWebhookSecret(email)
+	require.NoError(t, err)
+	assert.Nil(t, secret)
+}
+
+// testReopen changes every kind of data of the store and checks that the reopened store has the same data
+func testReopen(t *testing.T, store Store, reopen func() Store) {
+	created := create(t, store, newNotification("larrycapija@testmail.com", "08:30", "20:00"))
+	other := create(t, store, newNotification("larrycapija@testmail.com", "09:00"))
+	deleted := create(t, store, newNotification("larrycapija@testmail.com", "10:00"))
+
+	updated := created[0]
+	updated.Message = "give the pills to Firulais and Pepita"
+	ok, err := store.UpdateNotification(updated, newUpdateEntry(t, created[0], updated, startDate))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, store.SetLastSent(created[1].ID, startDate.Add(20*time.Hour)))
+	require.NoError(t, store.CompleteNotification(other[0].ID, startDate.Add(time.Hour)))
+	ok, err = store.DeleteNotification(deleted[0].ID, 0)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, store.SaveLastDispatchedSlot(startDate.Add(30*time.Minute)))
+	entry := newUpdateEntry(t, created[0], updated, startDate.Add(48*time.Hour))
+	require.NoError(t, store.SaveAuditEntry(entry))
+	// Only the entry of the update is old enough
+	purged, err := store.PurgeAuditEntries(startDate.Add(24 * time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, purged)
+	deliveries := []domain.Delivery{
+		domain.NewDelivery(created[0].ID, domain.Mail, startDate, startDate),
+		domain.NewDelivery(created[1].ID, domain.Mail, startDate, startDate),
+	}
+	_, err = store.EnqueueDeliveries(deliveries)
+	require.NoError(t, err)
+	claimed, err := store.ClaimDeliveries(startDate, startDate.Add(time.Minute), 1)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	sent := claimed[0]
+	sent.Finish(domain.DeliverySent, "", startDate)
+	_, err = store.UpdateDelivery(sent)
+	require.NoError(t, err)
+	secret := domain.WebhookSecret{Email: "larrycapija@testmail.com", Secret: "secret", CreatedAt: startDate}
+	require.NoError(t, store.SaveWebhookSecret(secret))
+
+	var expected []domain.Notification
+	for _, notificationID := range []string{created[0].ID, created[1].ID, other[0].ID} {
+		expected = append(expected, *get(t, store, notificationID))
+	}
+
+	reopened := reopen()
+	for idx := range expected {
+		stored := get(t, reopened, expected[idx].ID)
+		require.NotNil(t, stored)
+		assert.Equal(t, inUTC(expected[idx]), inUTC(*stored))
+	}
+	assert.Nil(t, get(t, reopened, deleted[0].ID))
+
+	notifications, err := reopened.GetNotificationsByEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	assert.Len(t, notifications, 3)
+
+	slot, err := reopened.GetLastDispatchedSlot()
+	require.NoError(t, err)
+	require.NotNil(t, slot)
+	assert.True(t, slot.Equal(startDate.Add(30*time.Minute)))
+
+	entries, err := reopened.GetAuditEntries(created[0].ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, domain.AuditCreated, entries[0].Action)
+	assert.Equal(t, entry.ID, entries[1].ID)
+
+	// Only the delivery that was not sent is claimed again
+	claimed, err = reopened.ClaimDeliveries(startDate.Add(time.Hour), startDate.Add(2*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Contains(t, []string{deliveries[0].ID, deliveries[1].ID}, claimed[0].ID)
+	assert.NotEqual(t, sent.ID, claimed[0].ID)
+
+	storedSecret, err := reopened.GetWebhookSecret(secret.Email)
+	require.NoError(t, err)
+	require.NotNil(t, storedSecret)
+	assert.Equal(t, secret.Secret, storedSecret.Secret)
+}
+
+// inUTC returns the notification with its dates in UTC, so notifications read from different sources can be compared
+func inUTC(notification domain.Notification) domain.Notification {
+	notification.StartDate = notification.StartDate.Round(0).UTC()
+	for _, date := range []**time.Time{
+		&notification.EndDate, &notification.LastSent, &notification.FireAt, &notification.CompletedAt,
+		&notification.DeletedAt,
+	} {
+		if *date != nil {
+			utcDate := (*date).Round(0).UTC()
+			*date = &utcDate
+		}
+	}
+
+	return notification
+}
+
+// testFailing checks that every operation of the store fails
+func testFailing(t *testing.T, store Store) {
+	notification := newNotification("larrycapija@testmail.com", "08:30")
+	notification.ID = uuid.NewString()
+	entry := domain.NewAuditEntry(uuid.NewString(), domain.AuditCreated, "user", nil, &notification, startDate)
+	delivery := domain.NewDelivery(notification.ID, domain.Mail, startDate, startDate)
+
+	operations := map[string]func() error{
+		"CreateNotifications": func() error {
+			_, err := store.CreateNotifications(notification, "user")
+			return err
+		},
+		"GetNotificationsByEmail": func() error {
+			_, err := store.GetNotificationsByEmail(notification.Email)
+			return err
+		},
+		"GetNotificationsBySchedule": func() error {
+			_, err := store.GetNotificationsBySchedule(notification.ScheduleID)
+			return err
+		},
+		"SearchNotifications": func() error {
+			_, err := store.SearchNotifications(domain.NotificationQuery{Email: notification.Email, Limit: 1})
+			return err
+		},
+		"GetNotification": func() error {
+			_, err := store.GetNotification(notification.ID)
+			return err
+		},
+		"UpdateNotification": func() error {
+			_, err := store.UpdateNotification(notification)
+			return err
+		},
+		"UpdateNotifications": func() error {
+			_, err := store.UpdateNotifications([]domain.Notification{notification})
+			return err
+		},
+		"DeleteNotification": func() error {
+			_, err := store.DeleteNotification(notification.ID, 0)
+			return err
+		},
+		"GetAll": func() error {
+			_, err := store.GetAll("08:30")
+			return err
+		},
+		"GetTimeZones": func() error {
+			_, err := store.GetTimeZones()
+			return err
+		},
+		"SetLastSent": func() error {
+			return store.SetLastSent(notification.ID, startDate)
+		},
+		"CompleteNotification": func() error {
+			return store.CompleteNotification(notification.ID, startDate)
+		},
+		"GetLastDispatchedSlot": func() error {
+			_, err := store.GetLastDispatchedSlot()
+			return err
+		},
+		"SaveLastDispatchedSlot": func() error {
+			return store.SaveLastDispatchedSlot(startDate)
+		},
+		"CompleteExpired": func() error {
+			_, err := store.CompleteExpired(startDate)
+			return err
+		},
+		"PurgeCompleted": func() error {
+			_, err := store.PurgeCompleted(startDate)
+			return err
+		},
+		"PurgeDeleted": func() error {
+			_, err := store.PurgeDeleted(startDate)
+			return err
+		},
+		"SaveAuditEntry": func() error {
+			return store.SaveAuditEntry(entry)
+		},
+		"GetAuditEntries": func() error {
+			_, err := store.GetAuditEntries(notification.ID)
+			return err
+		},
+		"PurgeAuditEntries": func() error {
+			_, err := store.PurgeAuditEntries(startDate)
+			return err
+		},
+		"EnqueueDeliveries": func() error {
+			_, err := store.EnqueueDeliveries([]domain.Delivery{delivery})
+			return err
+		},
+		"ClaimDeliveries": func() error {
+			_, err := store.ClaimDeliveries(startDate, startDate.Add(time.Minute), 1)
+			return err
+		},
+		"UpdateDelivery": func() error {
+			_, err := store.UpdateDelivery(delivery)
+			return err
+		},
+		"PurgeDeliveries": func() error {
+			_, err := store.PurgeDeliveries(startDate)
+			return err
+		},
+		"SaveWebhookSecret": func() error {
+			return store.SaveWebhookSecret(domain.WebhookSecret{Email: notification.Email, Secret: "secret"})
+		},
+		"GetWebhookSecret": func() error {
+			_, err := store.GetWebhookSecret(notification.Email)
+			return err
+		},
+		"DeleteWebhookSecret": func() error {
+			return store.DeleteWebhookSecret(notification.Email)
+		},
+	}
+
+	for name, operation := range operations {
+		assert.Error(t, operation(), "%s did not fail", name)
+	}
+}
@@ -0,0 +1,15 @@
+package db
+
+import "errors"
+
+var (
+	errCreatingDynamoSession = errors.New("error creating dynamo session")
+	errCreatingTables        = errors.New("error creating dynamo tables")
+	errMigrating             = errors.New("error migrating dynamo tables")
+	errOpeningSQLite         = errors.New("error opening sqlite database")
+	errRunningMigrations     = errors.New("error running sqlite migrations")
+	errOpeningJournal        = errors.New("error opening journal")
+	errReplayingJournal      = errors.New("error replaying journal")
+	errWritingJournal        = errors.New("error writing journal")
+	errWritingSnapshot       = errors.New("error writing snapshot")
+)
@@ -5,31 +5,252 @@ import (
 	"notification-scheduler/internal/domain"
 	"notification-scheduler/internal/notificationer/db/internal/item"
 	"notification-scheduler/internal/utils"
+	"sort"
+	"sync"
+	"time"
 )
 
+// itemLocation position of a notification in the store: its hour bucket and its index in that bucket
+type itemLocation struct {
+	hour     string
+	position int
+}
+
+// FakeDB keeps the notifications in memory. It's safe for concurrent use. If it's created with a journal, every
+// change is also written to disk, so the notifications survive restarts.
+// Besides the hour buckets, it keeps indexes so searching by ID, email or schedule does not scan the whole store:
+// + locations: ID -> location of the notification
+// + emails: email -> IDs of the notifications of that user
+// + schedules: schedule ID -> IDs of the notifications of that schedule
+// The audit entries are kept by notification ID, in the order they were saved, and the deliveries of the outbox by ID
 type FakeDB struct {
-	db  map[string][]item.NotificationItem
-	err error
+	mutex              sync.RWMutex
+	db                 map[string][]item.NotificationItem
+	locations          map[string]itemLocation
+	emails             idIndex
+	schedules          idIndex
+	lastDispatchedSlot *time.Time
+	auditEntries       map[string][]item.AuditItem
+	deliveries         map[string]item.DeliveryItem
+	webhookSecrets     map[string]item.WebhookSecretItem
+	journal            *journal
+	leaseMutex         sync.Mutex
+	leases             map[string]item.LeaseItem
+	err                error
 }
 
 func NewFakeDB(err error) *FakeDB {
 	db := make(map[string][]item.NotificationItem)
 	return &FakeDB{
-		db:  db,
-		err: err,
+		db:             db,
+		locations:      make(map[string]itemLocation),
+		emails:         make(idIndex),
+		schedules:      make(idIndex),
+		auditEntries:   make(map[string][]item.AuditItem),
+		deliveries:     make(map[string]item.DeliveryItem),
+		webhookSecrets: make(map[string]item.WebhookSecretItem),
+		leases:         make(map[string]item.LeaseItem),
+		err:            err,
+	}
+}
+
+// NewJournaledFakeDB returns a FakeDB persisted in the given directory. The state saved there is loaded, and a new
+// snapshot is taken right away. Use a Snapshotter to take snapshots periodically, otherwise the log grows forever
+func NewJournaledFakeDB(directory string) (*FakeDB, error) {
+	storeJournal, err := openJournal(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := storeJournal.load()
+	if err != nil {
+		_ = storeJournal.log.Close()
+		return nil, err
+	}
+
+	fake := NewFakeDB(nil)
+	for hour, notificationsPerHour := range snapshot.Notifications {
+		for idx := range notificationsPerHour {
+			fake.insert(hour, notificationsPerHour[idx])
+		}
+	}
+	for notificationID, entries := range snapshot.AuditEntries {
+		fake.auditEntries[notificationID] = entries
+	}
+	for deliveryID, deliveryItem := range snapshot.Deliveries {
+		fake.deliveries[deliveryID] = deliveryItem
+	}
+	for email, secretItem := range snapshot.WebhookSecrets {
+		fake.webhookSecrets[email] = secretItem
+	}
+	fake.lastDispatchedSlot = snapshot.LastDispatchedSlot
+	fake.journal = storeJournal
+
+	err = fake.Snapshot()
+	if err != nil {
+		_ = storeJournal.log.Close()
+		return nil, err
+	}
+
+	return fake, nil
+}
+
+// Snapshot writes the whole state to disk and empties the log. It does nothing if the FakeDB has no journal
+func (fake *FakeDB) Snapshot() error {
+	if fake.journal == nil {
+		return nil
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	return fake.journal.snapshot(item.Snapshot{
+		Notifications:      fake.db,
+		LastDispatchedSlot: fake.lastDispatchedSlot,
+		AuditEntries:       fake.auditEntries,
+		Deliveries:         fake.deliveries,
+		WebhookSecrets:     fake.webhookSecrets,
+	})
+}
+
+// record writes the given changes to the journal, if there is one, before they are applied in memory. If they
+// can't be written, the changes must not be applied. It must be called holding the write lock, so the entries are
+// written in the same order the changes are applied
+func (fake *FakeDB) record(entries ...item.JournalEntry) error {
+	if fake.journal == nil || len(entries) == 0 {
+		return nil
+	}
+
+	return fake.journal.append(entries...)
+}
+
+// putEntry returns the journal entry of the new value of the given notification
+func putEntry(hour string, notifItem item.NotificationItem) item.JournalEntry {
+	return item.JournalEntry{
+		Operation:    item.PutOperation,
+		Hour:         hour,
+		Notification: &notifItem,
+	}
+}
+
+func deleteEntry(notificationID string) item.JournalEntry {
+	return item.JournalEntry{
+		Operation: item.DeleteOperation,
+		ID:        notificationID,
+	}
+}
+
+// auditJournalEntries returns the journal entries of the given audit entries
+func auditJournalEntries(auditItems []item.AuditItem) []item.JournalEntry {
+	entries := make([]item.JournalEntry, 0, len(auditItems))
+	for idx := range auditItems {
+		entries = append(entries, item.JournalEntry{
+			Operation:  item.AuditOperation,
+			AuditEntry: &auditItems[idx],
+		})
+	}
+
+	return entries
+}
+
+// deliveryEntry returns the journal entry of the new value of the given delivery
+func deliveryEntry(deliveryItem item.DeliveryItem) item.JournalEntry {
+	return item.JournalEntry{
+		Operation: item.DeliveryOperation,
+		Delivery:  &deliveryItem,
+	}
+}
+
+// idIndex set of notification IDs by key
+type idIndex map[string]map[string]struct{}
+
+func (index idIndex) add(key string, notificationID string) {
+	if key == "" {
+		return
+	}
+
+	if index[key] == nil {
+		index[key] = make(map[string]struct{})
 	}
+	index[key][notificationID] = struct{}{}
 }
 
-func (fake *FakeDB) CreateNotifications(notification domain.Notification) ([]domain.Notification, error) {
+func (index idIndex) remove(key string, notificationID string) {
+	notificationIDs := index[key]
+	delete(notificationIDs, notificationID)
+	if len(notificationIDs) == 0 {
+		delete(index, key)
+	}
+}
+
+// insert adds the notification to the given hour bucket and to the indexes. It must be called holding the write lock
+func (fake *FakeDB) insert(hour string, notifItem item.NotificationItem) {
+	fake.db[hour] = append(fake.db[hour], notifItem)
+	fake.locations[notifItem.ID] = itemLocation{hour: hour, position: len(fake.db[hour]) - 1}
+
+	fake.emails.add(notifItem.Email, notifItem.ID)
+	fake.schedules.add(notifItem.Schedule(), notifItem.ID)
+}
+
+// find returns the notification with the given ID, that can be modified in place, and its hour. It must be called
+// holding the lock
+func (fake *FakeDB) find(notificationID string) (*item.NotificationItem, string, bool) {
+	location, found := fake.locations[notificationID]
+	if !found {
+		return nil, "", false
+	}
+
+	return &fake.db[location.hour][location.position], location.hour, true
+}
+
+// remove deletes the notification with the given ID from its bucket and from the indexes. The last notification of
+// the bucket takes its place, so nothing else is moved. It must be called holding the write lock
+func (fake *FakeDB) remove(notificationID string) bool {
+	location, found := fake.locations[notificationID]
+	if !found {
+		return false
+	}
+
+	bucket := fake.db[location.hour]
+	removedItem := bucket[location.position]
+	last := len(bucket) - 1
+	if location.position != last {
+		bucket[location.position] = bucket[last]
+		fake.locations[bucket[location.position].ID] = location
+	}
+	bucket[last] = item.NotificationItem{}
+	fake.db[location.hour] = bucket[:last]
+	delete(fake.locations, notificationID)
+
+	fake.emails.remove(removedItem.Email, notificationID)
+	fake.schedules.remove(removedItem.Schedule(), notificationID)
+
+	return true
+}
+
+// CreateNotifications creates one item per hour of the notification, and records their creation by the given actor in
+// the audit trail. If an hour is invalid, none is created
+func (fake *FakeDB) CreateNotifications(notification domain.Notification, actor string) ([]domain.Notification, error) {
+	if fake.err != nil {
+		return nil, fake.err
+	}
+
+	var hours []string
+	var notifItems []item.NotificationItem
 	var createdNotifications []domain.Notification
+	var entries []item.JournalEntry
 	for _, hour := range notification.Hours {
 		if !utils.ValidHour(hour) {
 			return nil, fmt.Errorf("error creating notifications: invalid key")
 		}
 
+		// Keys are always saved as HH:MM
+		hour, _ = utils.NormalizeHour(hour)
 		notificationItem := item.CreateItemFromNotification(notification)
-		// Save notification
-		fake.db[hour] = append(fake.db[hour], notificationItem)
+		notificationItem.Version = 1
+		hours = append(hours, hour)
+		notifItems = append(notifItems, notificationItem)
+		entries = append(entries, putEntry(hour, notificationItem))
 
 		// Collect notifications. These notifications have a defined ID and hour
 		createdNotification := notificationItem.ToNotification()
@@ -37,6 +258,27 @@ func (fake *FakeDB) CreateNotifications(notification domain.Notification) ([]dom
 		createdNotifications = append(createdNotifications, createdNotification)
 	}
 
+	auditEntries, err := creationEntries(createdNotifications, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	auditItems := auditItemsOf(auditEntries)
+	entries = append(entries, auditJournalEntries(auditItems)...)
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	err = fake.record(entries...)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, hour := range hours {
+		fake.insert(hour, notifItems[idx])
+	}
+	fake.saveAuditItems(auditItems)
+
 	return createdNotifications, nil
 }
 
@@ -45,18 +287,64 @@ func (fake *FakeDB) GetNotificationsByEmail(email string) ([]domain.Notification
 		return nil, fake.err
 	}
 
-	var userNotifications []domain.Notification
-	for hour, notificationsPerHour := range fake.db {
-		for _, notifItem := range notificationsPerHour {
-			if notifItem.Email == email {
-				notification := notifItem.ToNotification()
-				notification.Hours = []string{hour}
-				userNotifications = append(userNotifications, notification)
-			}
+	fake.mutex.RLock()
+	defer fake.mutex.RUnlock()
+
+	return fake.notificationsOf(fake.emails[email]), nil
+}
+
+// SearchNotifications returns a page of the notifications of the user that match the query. Only the notifications
+// of the user are checked, using the email index
+func (fake *FakeDB) SearchNotifications(query domain.NotificationQuery) (domain.NotificationPage, error) {
+	if fake.err != nil {
+		return domain.NotificationPage{}, fake.err
+	}
+
+	fake.mutex.RLock()
+	defer fake.mutex.RUnlock()
+
+	var matches []domain.Notification
+	for _, notification := range fake.notificationsOf(fake.emails[query.Email]) {
+		if query.Matches(notification) {
+			matches = append(matches, notification)
 		}
 	}
 
-	return userNotifications, nil
+	domain.SortNotifications(matches)
+	start := 0
+	if query.After != nil {
+		start = sort.Search(len(matches), func(idx int) bool {
+			return query.After.Precedes(matches[idx])
+		})
+	}
+
+	end := min(start+query.Limit+1, len(matches))
+	return domain.NewNotificationPage(matches[start:end], len(matches), query.Limit), nil
+}
+
+// GetNotificationsBySchedule returns all the notifications of the given schedule
+func (fake *FakeDB) GetNotificationsBySchedule(scheduleID string) ([]domain.Notification, error) {
+	if fake.err != nil {
+		return nil, fake.err
+	}
+
+	fake.mutex.RLock()
+	defer fake.mutex.RUnlock()
+
+	return fake.notificationsOf(fake.schedules[scheduleID]), nil
+}
+
+// notificationsOf returns the notifications with the given IDs. It must be called holding the lock
+func (fake *FakeDB) notificationsOf(notificationIDs map[string]struct{}) []domain.Notification {
+	var notifications []domain.Notification
+	for notificationID := range notificationIDs {
+		notifItem, hour, _ := fake.find(notificationID)
+		notification := notifItem.ToNotification()
+		notification.Hours = []string{hour}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications
 }
 
 func (fake *FakeDB) GetNotification(notificationID string) (*domain.Notification, error) {
@@ -64,66 +352,263 @@ func (fake *FakeDB) GetNotification(notificationID string) (*domain.Notification
 		return nil, fake.err
 	}
 
-	for hour, notificationsPerHour := range fake.db {
-		for _, notifItem := range notificationsPerHour {
-			if notifItem.ID == notificationID {
-				notification := notifItem.ToNotification()
-				notification.Hours = []string{hour}
-				return &notification, nil
-			}
+	fake.mutex.RLock()
+	defer fake.mutex.RUnlock()
+
+	notifItem, hour, found := fake.find(notificationID)
+	if !found {
+		return nil, nil
+	}
+
+	notification := notifItem.ToNotification()
+	notification.Hours = []string{hour}
+	return &notification, nil
+}
+
+// UpdateNotification replaces the notification if its version is the given one, and increments the version. The
+// given audit entries are saved with the change. It returns false if there is no notification with that ID and version
+func (fake *FakeDB) UpdateNotification(
+	updatedNotification domain.Notification, auditEntries ...domain.AuditEntry,
+) (bool, error) {
+	return fake.UpdateNotifications([]domain.Notification{updatedNotification}, auditEntries...)
+}
+
+// UpdateNotifications replaces all the given notifications or none, along with the given audit entries: if any of
+// them is not stored with the given version, nothing is changed and false is returned
+func (fake *FakeDB) UpdateNotifications(
+	updatedNotifications []domain.Notification, auditEntries ...domain.AuditEntry,
+) (bool, error) {
+	if fake.err != nil {
+		return false, fake.err
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	updatedItems := make([]item.NotificationItem, 0, len(updatedNotifications))
+	entries := make([]item.JournalEntry, 0, len(updatedNotifications)+len(auditEntries))
+	for _, updatedNotification := range updatedNotifications {
+		notifItem, hour, found := fake.find(updatedNotification.ID)
+		if !found || hour != updatedNotification.Hours[0] || notifItem.Version != updatedNotification.Version {
+			return false, nil
 		}
+
+		updatedItem := item.CreateItemFromNotification(updatedNotification)
+		updatedItem.Version++
+		// Set by the scheduler, that does not change the version
+		updatedItem.LastSent = notifItem.LastSent
+		updatedItem.CompletedAt = notifItem.CompletedAt
+		updatedItems = append(updatedItems, updatedItem)
+		entries = append(entries, putEntry(hour, updatedItem))
+	}
+
+	auditItems := auditItemsOf(auditEntries)
+	err := fake.record(append(entries, auditJournalEntries(auditItems)...)...)
+	if err != nil {
+		return false, err
+	}
+
+	for idx := range updatedItems {
+		// Removed and inserted again, so the indexes follow a change of email
+		fake.remove(updatedItems[idx].ID)
+		fake.insert(entries[idx].Hour, updatedItems[idx])
+	}
+	fake.saveAuditItems(auditItems)
+
+	return true, nil
+}
+
+// SetLastSent records the last slot sent of the notification. Its version is not changed, since the notification
+// was not changed by its user
+func (fake *FakeDB) SetLastSent(notificationID string, lastSent time.Time) error {
+	if fake.err != nil {
+		return fake.err
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	notifItem, hour, found := fake.find(notificationID)
+	if !found {
+		return fmt.Errorf("error notification not found")
+	}
+
+	sentItem := *notifItem
+	sentItem.LastSent = &lastSent
+	err := fake.record(putEntry(hour, sentItem))
+	if err != nil {
+		return err
 	}
 
-	return nil, nil
+	*notifItem = sentItem
+	return nil
 }
 
-func (fake *FakeDB) UpdateNotification(updatedNotification domain.Notification) error {
+func (fake *FakeDB) CompleteNotification(notificationID string, completedAt time.Time) error {
 	if fake.err != nil {
 		return fake.err
 	}
 
-	allNotifications, found := fake.db[updatedNotification.Hours[0]]
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	notifItem, hour, found := fake.find(notificationID)
 	if !found {
-		return fmt.Errorf("key not found")
+		return fmt.Errorf("error notification not found")
+	}
+
+	completedItem := *notifItem
+	completedItem.CompletedAt = &completedAt
+	err := fake.record(putEntry(hour, completedItem))
+	if err != nil {
+		return err
+	}
+
+	*notifItem = completedItem
+	return nil
+}
+
+// CompleteExpired completes all the notifications whose end date is before the given instant
+func (fake *FakeDB) CompleteExpired(now time.Time) (int, error) {
+	if fake.err != nil {
+		return 0, fake.err
 	}
 
-	for idx := range allNotifications {
-		if allNotifications[idx].ID == updatedNotification.ID {
-			allNotifications[idx] = item.CreateItemFromNotification(updatedNotification)
-			return nil
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	var expiredItems []*item.NotificationItem
+	var completedItems []item.NotificationItem
+	var entries []item.JournalEntry
+	for hour, notificationsPerHour := range fake.db {
+		for idx := range notificationsPerHour {
+			notifItem := &notificationsPerHour[idx]
+			if notifItem.CompletedAt == nil && notifItem.EndDate != nil && notifItem.EndDate.Before(now) {
+				completedItem := *notifItem
+				completedItem.CompletedAt = &now
+				expiredItems = append(expiredItems, notifItem)
+				completedItems = append(completedItems, completedItem)
+				entries = append(entries, putEntry(hour, completedItem))
+			}
 		}
 	}
 
-	return fmt.Errorf("error notification not found")
+	err := fake.record(entries...)
+	if err != nil {
+		return 0, err
+	}
+
+	for idx := range expiredItems {
+		*expiredItems[idx] = completedItems[idx]
+	}
+
+	return len(completedItems), nil
 }
 
-func (fake *FakeDB) DeleteNotification(notificationID string) (bool, error) {
+// PurgeCompleted deletes all the notifications completed before the given instant
+func (fake *FakeDB) PurgeCompleted(completedBefore time.Time) (int, error) {
 	if fake.err != nil {
-		return false, fake.err
+		return 0, fake.err
 	}
 
-	deleted := false
-	for key, notificationsPerHour := range fake.db {
-		var notifItemsCopy []item.NotificationItem
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	var purgedIDs []string
+	for _, notificationsPerHour := range fake.db {
 		for idx := range notificationsPerHour {
-			if notificationsPerHour[idx].ID == notificationID {
-				deleted = true
-				continue
+			completedAt := notificationsPerHour[idx].CompletedAt
+			if completedAt != nil && completedAt.Before(completedBefore) {
+				purgedIDs = append(purgedIDs, notificationsPerHour[idx].ID)
 			}
-			notifItemsCopy = append(notifItemsCopy, notificationsPerHour[idx])
 		}
+	}
 
-		fake.db[key] = notifItemsCopy
+	return len(purgedIDs), fake.removeAll(purgedIDs)
+}
+
+// PurgeDeleted deletes all the notifications deleted before the given instant. Their audit entries are kept
+func (fake *FakeDB) PurgeDeleted(deletedBefore time.Time) (int, error) {
+	if fake.err != nil {
+		return 0, fake.err
 	}
 
-	return deleted, nil
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	var purgedIDs []string
+	for _, notificationsPerHour := range fake.db {
+		for idx := range notificationsPerHour {
+			deletedAt := notificationsPerHour[idx].DeletedAt
+			if deletedAt != nil && deletedAt.Before(deletedBefore) {
+				purgedIDs = append(purgedIDs, notificationsPerHour[idx].ID)
+			}
+		}
+	}
+
+	return len(purgedIDs), fake.removeAll(purgedIDs)
+}
+
+// removeAll deletes the notifications with the given IDs. It must be called holding the write lock
+func (fake *FakeDB) removeAll(notificationIDs []string) error {
+	var entries []item.JournalEntry
+	for _, notificationID := range notificationIDs {
+		entries = append(entries, deleteEntry(notificationID))
+	}
+
+	err := fake.record(entries...)
+	if err != nil {
+		return err
+	}
+
+	for _, notificationID := range notificationIDs {
+		fake.remove(notificationID)
+	}
+
+	return nil
 }
 
-func (fake *FakeDB) GetAll(key string) []domain.Notification {
+// DeleteNotification deletes the notification if its version is the given one. Version 0 deletes any version. It
+// returns false if there is no notification with that ID and version
+func (fake *FakeDB) DeleteNotification(notificationID string, version int64) (bool, error) {
+	if fake.err != nil {
+		return false, fake.err
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	notifItem, _, found := fake.find(notificationID)
+	if !found || (version != 0 && notifItem.Version != version) {
+		return false, nil
+	}
+
+	err := fake.record(deleteEntry(notificationID))
+	if err != nil {
+		return false, err
+	}
+
+	fake.remove(notificationID)
+	return true, nil
+}
+
+func (fake *FakeDB) GetAll(key string) ([]domain.Notification, error) {
+	if fake.err != nil {
+		return nil, fake.err
+	}
+
+	fake.mutex.RLock()
+	defer fake.mutex.RUnlock()
+
 	var notifications []domain.Notification
-	notifItems, found := fake.db[key+":00"]
+	key, err := utils.NormalizeHour(key)
+	if err != nil {
+		return notifications, nil
+	}
+
+	notifItems, found := fake.db[key]
 	if !found {
-		return notifications
+		return notifications, nil
 	}
 
 	for idx := range notifItems {
@@ -132,5 +617,381 @@ func (fake *FakeDB) GetAll(key string) []domain.Notification {
 		notifications = append(notifications, notification)
 	}
 
-	return notifications
+	return notifications, nil
+}
+
+// GetTimeZones returns all the different time zones used by the saved notifications
+func (fake *FakeDB) GetTimeZones() ([]string, error) {
+	if fake.err != nil {
+		return nil, fake.err
+	}
+
+	fake.mutex.RLock()
+	defer fake.mutex.RUnlock()
+
+	var timeZones []string
+	for _, notificationsPerHour := range fake.db {
+		for _, notifItem := range notificationsPerHour {
+			if notifItem.TimeZone != "" && !utils.Contains(timeZones, notifItem.TimeZone) {
+				timeZones = append(timeZones, notifItem.TimeZone)
+			}
+		}
+	}
+
+	return timeZones, nil
+}
+
+func (fake *FakeDB) GetLastDispatchedSlot() (*time.Time, error) {
+	if fake.err != nil {
+		return nil, fake.err
+	}
+
+	fake.mutex.RLock()
+	defer fake.mutex.RUnlock()
+
+	return fake.lastDispatchedSlot, nil
+}
+
+func (fake *FakeDB) SaveLastDispatchedSlot(slot time.Time) error {
+	if fake.err != nil {
+		return fake.err
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	err := fake.record(item.JournalEntry{
+		Operation: item.CheckpointOperation,
+		Time:      &slot,
+	})
+	if err != nil {
+		return err
+	}
+
+	fake.lastDispatchedSlot = &slot
+	return nil
+}
+
+func (fake *FakeDB) SaveAuditEntry(entry domain.AuditEntry) error {
+	if fake.err != nil {
+		return fake.err
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	auditItems := auditItemsOf([]domain.AuditEntry{entry})
+	err := fake.record(auditJournalEntries(auditItems)...)
+	if err != nil {
+		return err
+	}
+
+	fake.saveAuditItems(auditItems)
+	return nil
+}
+
+// saveAuditItems adds the given entries to the audit trail. It must be called holding the write lock
+func (fake *FakeDB) saveAuditItems(auditItems []item.AuditItem) {
+	for _, auditItem := range auditItems {
+		fake.auditEntries[auditItem.NotificationID] = append(fake.auditEntries[auditItem.NotificationID], auditItem)
+	}
+}
+
+// PurgeAuditEntries deletes the audit entries saved before the given instant
+func (fake *FakeDB) PurgeAuditEntries(savedBefore time.Time) (int, error) {
+	if fake.err != nil {
+		return 0, fake.err
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	var entries []item.JournalEntry
+	for _, auditItems := range fake.auditEntries {
+		for idx := range auditItems {
+			if !auditItems[idx].Timestamp.Before(savedBefore) {
+				continue
+			}
+
+			entries = append(entries, item.JournalEntry{
+				Operation:  item.DeleteAuditOperation,
+				AuditEntry: &auditItems[idx],
+			})
+		}
+	}
+
+	err := fake.record(entries...)
+	if err != nil {
+		return 0, err
+	}
+
+	for notificationID, auditItems := range fake.auditEntries {
+		var kept []item.AuditItem
+		for idx := range auditItems {
+			if !auditItems[idx].Timestamp.Before(savedBefore) {
+				kept = append(kept, auditItems[idx])
+			}
+		}
+
+		if len(kept) == 0 {
+			delete(fake.auditEntries, notificationID)
+		} else {
+			fake.auditEntries[notificationID] = kept
+		}
+	}
+
+	return len(entries), nil
+}
+
+// GetAuditEntries returns the audit entries of the given notification, sorted by ID
+func (fake *FakeDB) GetAuditEntries(notificationID string) ([]domain.AuditEntry, error) {
+	if fake.err != nil {
+		return nil, fake.err
+	}
+
+	fake.mutex.RLock()
+	defer fake.mutex.RUnlock()
+
+	var entries []domain.AuditEntry
+	for _, auditItem := range fake.auditEntries[notificationID] {
+		entries = append(entries, auditItem.ToAuditEntry())
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+
+	return entries, nil
+}
+
+// EnqueueDeliveries saves the given deliveries, skipping the ones that already exist. It returns the amount saved
+func (fake *FakeDB) EnqueueDeliveries(deliveries []domain.Delivery) (int, error) {
+	if fake.err != nil {
+		return 0, fake.err
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	deliveryItems := make(map[string]item.DeliveryItem)
+	var entries []item.JournalEntry
+	for idx := range deliveries {
+		_, saved := fake.deliveries[deliveries[idx].ID]
+		_, repeated := deliveryItems[deliveries[idx].ID]
+		if saved || repeated {
+			continue
+		}
+
+		deliveryItem := item.CreateItemFromDelivery(deliveries[idx])
+		deliveryItems[deliveryItem.ID] = deliveryItem
+		entries = append(entries, deliveryEntry(deliveryItem))
+	}
+
+	err := fake.record(entries...)
+	if err != nil {
+		return 0, err
+	}
+
+	for deliveryID, deliveryItem := range deliveryItems {
+		fake.deliveries[deliveryID] = deliveryItem
+	}
+
+	return len(deliveryItems), nil
+}
+
+// ClaimDeliveries claims up to limit pending deliveries whose next attempt is not after now, the ones that have
+// waited longer first. Claimed deliveries get one more attempt and are not claimed again until leaseUntil
+func (fake *FakeDB) ClaimDeliveries(now time.Time, leaseUntil time.Time, limit int) ([]domain.Delivery, error) {
+	if fake.err != nil {
+		return nil, fake.err
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	var claimable []item.DeliveryItem
+	for _, deliveryItem := range fake.deliveries {
+		if deliveryItem.Claimable(now) {
+			claimable = append(claimable, deliveryItem)
+		}
+	}
+
+	sort.Slice(claimable, func(i, j int) bool {
+		if !claimable[i].NextAttemptAt.Equal(claimable[j].NextAttemptAt) {
+			return claimable[i].NextAttemptAt.Before(claimable[j].NextAttemptAt)
+		}
+		if !claimable[i].Slot.Equal(claimable[j].Slot) {
+			return claimable[i].Slot.Before(claimable[j].Slot)
+		}
+		return claimable[i].ID < claimable[j].ID
+	})
+
+	if len(claimable) > limit {
+		claimable = claimable[:limit]
+	}
+
+	var entries []item.JournalEntry
+	for idx := range claimable {
+		claimable[idx].Attempts++
+		claimable[idx].NextAttemptAt = leaseUntil
+		entries = append(entries, deliveryEntry(claimable[idx]))
+	}
+
+	err := fake.record(entries...)
+	if err != nil {
+		return nil, err
+	}
+
+	var claimed []domain.Delivery
+	for _, deliveryItem := range claimable {
+		fake.deliveries[deliveryItem.ID] = deliveryItem
+		claimed = append(claimed, deliveryItem.ToDelivery())
+	}
+
+	return claimed, nil
+}
+
+// UpdateDelivery replaces the delivery if it has the same attempts as the saved one, so a worker whose delivery was
+// claimed again by another one does not overwrite it. It returns false if the delivery was not replaced
+func (fake *FakeDB) UpdateDelivery(delivery domain.Delivery) (bool, error) {
+	if fake.err != nil {
+		return false, fake.err
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	savedItem, found := fake.deliveries[delivery.ID]
+	if !found || savedItem.Attempts != delivery.Attempts {
+		return false, nil
+	}
+
+	deliveryItem := item.CreateItemFromDelivery(delivery)
+	err := fake.record(deliveryEntry(deliveryItem))
+	if err != nil {
+		return false, err
+	}
+
+	fake.deliveries[delivery.ID] = deliveryItem
+	return true, nil
+}
+
+// PurgeDeliveries deletes the deliveries finished before the given instant
+func (fake *FakeDB) PurgeDeliveries(finishedBefore time.Time) (int, error) {
+	if fake.err != nil {
+		return 0, fake.err
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	var purgedIDs []string
+	var entries []item.JournalEntry
+	for deliveryID, deliveryItem := range fake.deliveries {
+		if deliveryItem.FinishedAt == nil || !deliveryItem.FinishedAt.Before(finishedBefore) {
+			continue
+		}
+
+		purgedIDs = append(purgedIDs, deliveryID)
+		entries = append(entries, item.JournalEntry{
+			Operation: item.DeleteDeliveryOperation,
+			ID:        deliveryID,
+		})
+	}
+
+	err := fake.record(entries...)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, deliveryID := range purgedIDs {
+		delete(fake.deliveries, deliveryID)
+	}
+
+	return len(purgedIDs), nil
+}
+
+// SaveWebhookSecret saves the webhook secret of the user, replacing the previous one
+func (fake *FakeDB) SaveWebhookSecret(secret domain.WebhookSecret) error {
+	if fake.err != nil {
+		return fake.err
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	secretItem := item.CreateItemFromWebhookSecret(secret)
+	err := fake.record(item.JournalEntry{
+		Operation:     item.WebhookSecretOperation,
+		WebhookSecret: &secretItem,
+	})
+	if err != nil {
+		return err
+	}
+
+	fake.webhookSecrets[secret.Email] = secretItem
+	return nil
+}
+
+// GetWebhookSecret returns the webhook secret of the user, nil if there is none
+func (fake *FakeDB) GetWebhookSecret(email string) (*domain.WebhookSecret, error) {
+	if fake.err != nil {
+		return nil, fake.err
+	}
+
+	fake.mutex.RLock()
+	defer fake.mutex.RUnlock()
+
+	secretItem, found := fake.webhookSecrets[email]
+	if !found {
+		return nil, nil
+	}
+
+	secret := secretItem.ToWebhookSecret()
+	return &secret, nil
+}
+
+func (fake *FakeDB) DeleteWebhookSecret(email string) error {
+	if fake.err != nil {
+		return fake.err
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	err := fake.record(item.JournalEntry{
+		Operation: item.DeleteWebhookSecretOperation,
+		ID:        email,
+	})
+	if err != nil {
+		return err
+	}
+
+	delete(fake.webhookSecrets, email)
+	return nil
+}
+
+// AcquireLease takes the lease with the given name for the given owner. The lease is written only if it does not
+// exist, is expired or already belongs to the owner
+func (fake *FakeDB) AcquireLease(name string, owner string, ttl time.Duration) (bool, error) {
+	if fake.err != nil {
+		return false, fake.err
+	}
+
+	fake.leaseMutex.Lock()
+	defer fake.leaseMutex.Unlock()
+
+	now := time.Now()
+	removeExpiredLeases(fake.leases, now)
+	lease, found := fake.leases[name]
+	if found && !lease.AvailableFor(owner, now) {
+		return false, nil
+	}
+
+	fake.leases[name] = item.LeaseItem{
+		Name:      name,
+		Owner:     owner,
+		ExpiresAt: now.Add(ttl),
+	}
+	return true, nil
 }
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"notification-scheduler/internal/domain"
+	"testing"
+	"time"
+)
+
+var benchmarkSizes = []int{1_000, 10_000, 100_000}
+
+func newTestNotification(email string, hours ...string) domain.Notification {
+	return domain.Notification{
+		Email:     email,
+		Via:       domain.Mail,
+		Message:   "give the pills to Firulais",
+		StartDate: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		Hours:     hours,
+	}
+}
+
+// newBenchmarkFakeDB returns a FakeDB with the given number of notifications, spread over all the hours and over
+// one hundred users
+func newBenchmarkFakeDB(b *testing.B, size int) (*FakeDB, []domain.Notification) {
+	fake := NewFakeDB(nil)
+	var created []domain.Notification
+	for idx := 0; idx < size; idx++ {
+		hour := fmt.Sprintf("%02d:%02d", idx%24, (idx%2)*30)
+		notifications, err := fake.CreateNotifications(newTestNotification(fmt.Sprintf("user%d@testmail.com", idx%100), hour), "user")
+		require.NoError(b, err)
+		created = append(created, notifications...)
+	}
+
+	return fake, created
+}
+
+func TestFakeDBIndexesFollowChanges(t *testing.T) {
+	fake := NewFakeDB(nil)
+	created, err := fake.CreateNotifications(newTestNotification("larrycapija@testmail.com", "08:30", "20:00"), "user")
+	require.NoError(t, err)
+	other, err := fake.CreateNotifications(newTestNotification("larrycapija@testmail.com", "08:30"), "user")
+	require.NoError(t, err)
+
+	// Deleting the first notification of a bucket moves the last one to its place
+	deleted, err := fake.DeleteNotification(created[0].ID, 0)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	notification, err := fake.GetNotification(other[0].ID)
+	require.NoError(t, err)
+	require.NotNil(t, notification)
+	assert.Equal(t, []string{"08:30"}, notification.Hours)
+
+	notification, err = fake.GetNotification(created[0].ID)
+	require.NoError(t, err)
+	assert.Nil(t, notification)
+
+	// Changing the email moves the notification to the other user
+	updated := created[1]
+	updated.Email = "pepitapistolera@testmail.com"
+	ok, err := fake.UpdateNotification(updated)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	userNotifications, err := fake.GetNotificationsByEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	require.Len(t, userNotifications, 1)
+	assert.Equal(t, other[0].ID, userNotifications[0].ID)
+
+	userNotifications, err = fake.GetNotificationsByEmail("pepitapistolera@testmail.com")
+	require.NoError(t, err)
+	require.Len(t, userNotifications, 1)
+	assert.Equal(t, created[1].ID, userNotifications[0].ID)
+
+	slotNotifications, err := fake.GetAll("08:30")
+	require.NoError(t, err)
+	assert.Len(t, slotNotifications, 1)
+}
+
+func BenchmarkFakeDBGetNotification(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("notifications=%d", size), func(b *testing.B) {
+			fake, created := newBenchmarkFakeDB(b, size)
+			b.ResetTimer()
+			for idx := 0; idx < b.N; idx++ {
+				_, _ = fake.GetNotification(created[idx%size].ID)
+			}
+		})
+	}
+}
+
+func BenchmarkFakeDBGetNotificationsByEmail(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("notifications=%d", size), func(b *testing.B) {
+			// A single notification belongs to this user, whatever the size of the store
+			fake, _ := newBenchmarkFakeDB(b, size)
+			_, err := fake.CreateNotifications(newTestNotification("larrycapija@testmail.com", "08:30"), "user")
+			require.NoError(b, err)
+			b.ResetTimer()
+			for idx := 0; idx < b.N; idx++ {
+				_, _ = fake.GetNotificationsByEmail("larrycapija@testmail.com")
+			}
+		})
+	}
+}
+
+func BenchmarkFakeDBDeleteNotification(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("notifications=%d", size), func(b *testing.B) {
+			fake, created := newBenchmarkFakeDB(b, size)
+			b.ResetTimer()
+			for idx := 0; idx < b.N; idx++ {
+				// Each deleted notification is created again, so the store keeps its size
+				notification := created[idx%size]
+				_, _ = fake.DeleteNotification(notification.ID, 0)
+				created[idx%size] = recreate(b, fake, notification)
+			}
+		})
+	}
+}
+
+func recreate(b *testing.B, fake *FakeDB, notification domain.Notification) domain.Notification {
+	b.StopTimer()
+	defer b.StartTimer()
+
+	notification.ID = ""
+	created, err := fake.CreateNotifications(notification, "user")
+	require.NoError(b, err)
+	return created[0]
+}
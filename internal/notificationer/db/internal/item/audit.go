@@ -0,0 +1,90 @@
+package item
+
+import (
+	"notification-scheduler/internal/domain"
+	"time"
+)
+
+// AuditItem audit entry saved into the DB. In DynamoDB, the entries of a notification share the hash key and are
+// sorted by ID
+type AuditItem struct {
+	NotificationID string                 `json:"notification_id" dynamo:"notification_id,hash"`
+	ID             string                 `json:"id" dynamo:"id,range"`
+	Email          string                 `json:"email" dynamo:"email"`
+	Action         domain.AuditAction     `json:"action" dynamo:"action"`
+	Actor          string                 `json:"actor" dynamo:"actor"`
+	Timestamp      time.Time              `json:"timestamp" dynamo:"timestamp"`
+	Before         *AuditNotificationItem `json:"before,omitempty" dynamo:"before,omitempty"`
+	After          *AuditNotificationItem `json:"after,omitempty" dynamo:"after,omitempty"`
+}
+
+// DynamoAuditItem AuditItem saved into DynamoDB. The timestamp is repeated as a unix timestamp, the range key of the
+// index used by the sweeper to find the entries saved before a date. Like the notifications, all the entries have
+// the same sweep attribute, the hash key of that index
+type DynamoAuditItem struct {
+	AuditItem
+	Sweep         string    `dynamo:"sweep" index:"saved-index,hash"`
+	TimestampUnix time.Time `dynamo:"timestamp_unix,unixtime" index:"saved-index,range"`
+}
+
+func NewDynamoAuditItem(auditItem AuditItem) DynamoAuditItem {
+	return DynamoAuditItem{
+		AuditItem:     auditItem,
+		Sweep:         SweepValue,
+		TimestampUnix: auditItem.Timestamp,
+	}
+}
+
+// AuditNotificationItem notification saved in an audit entry, with the hour on which it's sent
+type AuditNotificationItem struct {
+	NotificationItem
+	Hour string `json:"hour" dynamo:"hour"`
+}
+
+func CreateItemFromAuditEntry(entry domain.AuditEntry) AuditItem {
+	return AuditItem{
+		NotificationID: entry.NotificationID,
+		ID:             entry.ID,
+		Email:          entry.Email,
+		Action:         entry.Action,
+		Actor:          entry.Actor,
+		Timestamp:      entry.Timestamp,
+		Before:         newAuditNotificationItem(entry.Before),
+		After:          newAuditNotificationItem(entry.After),
+	}
+}
+
+// ToAuditEntry returns the AuditItem as a domain.AuditEntry
+func (ai AuditItem) ToAuditEntry() domain.AuditEntry {
+	return domain.AuditEntry{
+		ID:             ai.ID,
+		NotificationID: ai.NotificationID,
+		Email:          ai.Email,
+		Action:         ai.Action,
+		Actor:          ai.Actor,
+		Timestamp:      ai.Timestamp,
+		Before:         ai.Before.toNotification(),
+		After:          ai.After.toNotification(),
+	}
+}
+
+func newAuditNotificationItem(notification *domain.Notification) *AuditNotificationItem {
+	if notification == nil {
+		return nil
+	}
+
+	return &AuditNotificationItem{
+		NotificationItem: CreateItemFromNotification(*notification),
+		Hour:             notification.Hours[0],
+	}
+}
+
+func (ani *AuditNotificationItem) toNotification() *domain.Notification {
+	if ani == nil {
+		return nil
+	}
+
+	notification := ani.ToNotification()
+	notification.Hours = []string{ani.Hour}
+	return &notification
+}
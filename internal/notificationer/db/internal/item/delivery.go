@@ -0,0 +1,81 @@
+package item
+
+import (
+	"notification-scheduler/internal/domain"
+	"time"
+)
+
+// DeliveryItem delivery of the outbox saved into the DB. Next attempt and finish dates are saved as unix timestamps
+// in DynamoDB, so they can be compared in conditions and filters
+type DeliveryItem struct {
+	ID             string                `json:"id" dynamo:"id,hash"`
+	NotificationID string                `json:"notification_id" dynamo:"notification_id"`
+	Channel        domain.Via            `json:"channel,omitempty" dynamo:"channel,omitempty"`
+	Slot           time.Time             `json:"slot" dynamo:"slot"`
+	Status         domain.DeliveryStatus `json:"status" dynamo:"status"`
+	Attempts       int                   `json:"attempts" dynamo:"attempts"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at" dynamo:"next_attempt_at,unixtime" index:"pending-index,range"`
+	LastError      string                `json:"last_error,omitempty" dynamo:"last_error,omitempty"`
+	CreatedAt      time.Time             `json:"created_at" dynamo:"created_at"`
+	FinishedAt     *time.Time            `json:"finished_at,omitempty" dynamo:"finished_at,unixtime" index:"finished-index,range"`
+}
+
+func CreateItemFromDelivery(delivery domain.Delivery) DeliveryItem {
+	return DeliveryItem{
+		ID:             delivery.ID,
+		NotificationID: delivery.NotificationID,
+		Channel:        delivery.Channel,
+		Slot:           delivery.Slot,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+		FinishedAt:     delivery.FinishedAt,
+	}
+}
+
+// ToDelivery returns the DeliveryItem as a domain.Delivery
+func (di DeliveryItem) ToDelivery() domain.Delivery {
+	return domain.Delivery{
+		ID:             di.ID,
+		NotificationID: di.NotificationID,
+		Channel:        di.Channel,
+		Slot:           di.Slot,
+		Status:         di.Status,
+		Attempts:       di.Attempts,
+		NextAttemptAt:  di.NextAttemptAt,
+		LastError:      di.LastError,
+		CreatedAt:      di.CreatedAt,
+		FinishedAt:     di.FinishedAt,
+	}
+}
+
+// Claimable returns true if the delivery is pending and its next attempt is not after the given instant
+func (di DeliveryItem) Claimable(now time.Time) bool {
+	return di.Status == domain.DeliveryPending && !di.NextAttemptAt.After(now)
+}
+
+// DynamoDeliveryItem DeliveryItem saved into DynamoDB. Only pending deliveries have the pending attribute, so the
+// pending index is sparse: it only has the deliveries that can still be claimed, sorted by next attempt. All of them
+// share its hash key, so the index is a single partition, that takes up to 1,000 writes per second. Enqueuing,
+// claiming and finishing a delivery write to it, so the outbox handles a few hundred deliveries per second at most;
+// past that, the hash key must be split in shards that are claimed in turn. Like the notifications, all the
+// deliveries have the same sweep attribute, the hash key of the sparse index of the finished ones used by the sweeper
+type DynamoDeliveryItem struct {
+	DeliveryItem
+	Pending string `dynamo:"pending,omitempty" index:"pending-index,hash"`
+	Sweep   string `dynamo:"sweep" index:"finished-index,hash"`
+}
+
+// PendingValue value of the pending attribute of the pending deliveries
+const PendingValue = "pending"
+
+func NewDynamoDeliveryItem(deliveryItem DeliveryItem) DynamoDeliveryItem {
+	dynamoItem := DynamoDeliveryItem{DeliveryItem: deliveryItem, Sweep: SweepValue}
+	if deliveryItem.Status == domain.DeliveryPending {
+		dynamoItem.Pending = PendingValue
+	}
+
+	return dynamoItem
+}
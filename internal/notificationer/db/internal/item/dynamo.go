@@ -0,0 +1,58 @@
+package item
+
+import (
+	"strings"
+	"time"
+)
+
+// DynamoNotificationItem NotificationItem saved into DynamoDB. Besides the notification, it contains the hour on
+// which it's sent, that is the hash key of the index used to search the notifications of a slot. Start, end,
+// completion and deletion dates are repeated as unix timestamps, so they can be compared in filters regardless of
+// their time zone. The sort key, hour#ID, is the range key of the sorted email index, so the searches of a user are
+// sorted by hour and ID. The message is repeated in lower case, to search text ignoring case. All the items have the
+// same sweep attribute, the hash key of the sparse indexes used by the sweeper: their range keys are the end,
+// completion and deletion dates, so each index only has the notifications with that date
+type DynamoNotificationItem struct {
+	NotificationItem
+	Hour            string     `dynamo:"hour" index:"hour-index,hash"`
+	SortKey         string     `dynamo:"sort_key" index:"email-sort-index,range"`
+	SearchMessage   string     `dynamo:"search_message"`
+	Sweep           string     `dynamo:"sweep" index:"expiring-index,hash" index:"completed-index,hash" index:"deleted-index,hash"`
+	StartDateUnix   time.Time  `dynamo:"start_date_unix,unixtime"`
+	EndDateUnix     *time.Time `dynamo:"end_date_unix,unixtime" index:"expiring-index,range"`
+	CompletedAtUnix *time.Time `dynamo:"completed_at_unix,unixtime" index:"completed-index,range"`
+	DeletedAtUnix   *time.Time `dynamo:"deleted_at_unix,unixtime" index:"deleted-index,range"`
+}
+
+// SweepValue value of the sweep attribute of the notifications
+const SweepValue = "sweep"
+
+func NewDynamoNotificationItem(notificationItem NotificationItem, hour string) DynamoNotificationItem {
+	return DynamoNotificationItem{
+		NotificationItem: notificationItem,
+		Hour:             hour,
+		SortKey:          SortKey(hour, notificationItem.ID),
+		SearchMessage:    strings.ToLower(notificationItem.Message),
+		Sweep:            SweepValue,
+		StartDateUnix:    notificationItem.StartDate,
+		EndDateUnix:      notificationItem.EndDate,
+		CompletedAtUnix:  notificationItem.CompletedAt,
+		DeletedAtUnix:    notificationItem.DeletedAt,
+	}
+}
+
+// SortKey returns the sort key of the notification with the given hour and ID
+func SortKey(hour string, notificationID string) string {
+	return hour + "#" + notificationID
+}
+
+// MetaItem struct saved into DynamoDB with data of the scheduler itself: leases, time zones in use and the last
+// dispatched slot. The webhook secrets of the users are saved here too, named by email. Items are grouped by kind
+type MetaItem struct {
+	Kind      string     `dynamo:"kind,hash"`
+	Name      string     `dynamo:"name,range"`
+	Owner     string     `dynamo:"owner"`
+	Time      *time.Time `dynamo:"time"`
+	ExpiresAt *time.Time `dynamo:"expires_at,unixtime"`
+	Secret    string     `dynamo:"secret,omitempty"`
+}
@@ -8,14 +8,24 @@ import (
 
 // NotificationItem struct that is saved into the DB
 type NotificationItem struct {
-	ID         string     `json:"id"`
-	TelegramID string     `json:"telegram_id,omitempty"`
-	Email      string     `json:"email,omitempty"`
-	Message    string     `json:"message"`
-	Via        domain.Via `json:"via"`
-	StartDate  time.Time  `json:"start_date"`
-	EndDate    *time.Time `json:"end_date,omitempty"`
-	LastSent   *time.Time `json:"last_sent,omitempty"`
+	ID          string               `json:"id" dynamo:"id,hash"`
+	ScheduleID  string               `json:"schedule_id,omitempty" dynamo:"schedule_id" index:"schedule-index,hash"`
+	TelegramID  string               `json:"telegram_id,omitempty" dynamo:"telegram_id"`
+	Email       string               `json:"email,omitempty" dynamo:"email" index:"email-index,hash" index:"email-sort-index,hash"`
+	Message     string               `json:"message" dynamo:"message"`
+	Via         domain.Via           `json:"via" dynamo:"via"`
+	StartDate   time.Time            `json:"start_date" dynamo:"start_date"`
+	EndDate     *time.Time           `json:"end_date,omitempty" dynamo:"end_date"`
+	LastSent    *time.Time           `json:"last_sent,omitempty" dynamo:"last_sent"`
+	TimeZone    string               `json:"time_zone,omitempty" dynamo:"time_zone"`
+	Recurrence  string               `json:"recurrence,omitempty" dynamo:"recurrence"`
+	FireAt      *time.Time           `json:"fire_at,omitempty" dynamo:"fire_at"`
+	CompletedAt *time.Time           `json:"completed_at,omitempty" dynamo:"completed_at"`
+	CatchUp     domain.CatchUpPolicy `json:"catch_up,omitempty" dynamo:"catch_up"`
+	Paused      bool                 `json:"paused,omitempty" dynamo:"paused"`
+	Version     int64                `json:"version" dynamo:"version"`
+	DeletedAt   *time.Time           `json:"deleted_at,omitempty" dynamo:"deleted_at"`
+	WebhookURL  string               `json:"webhook_url,omitempty" dynamo:"webhook_url,omitempty"`
 }
 
 // CreateItemFromNotification creates a NotificationItem from a domain.Notification. It receives the transactionTi
@@ -26,25 +36,57 @@ func CreateItemFromNotification(notification domain.Notification) NotificationIt
 	}
 
 	return NotificationItem{
-		ID:         notificationID,
-		TelegramID: notification.TelegramID,
-		Email:      notification.Email,
-		Message:    notification.Message,
-		Via:        notification.Via,
-		StartDate:  notification.StartDate,
-		EndDate:    notification.EndDate,
+		ID:          notificationID,
+		ScheduleID:  notification.ScheduleID,
+		TelegramID:  notification.TelegramID,
+		Email:       notification.Email,
+		Message:     notification.Message,
+		Via:         notification.Via,
+		StartDate:   notification.StartDate,
+		EndDate:     notification.EndDate,
+		TimeZone:    notification.TimeZone,
+		LastSent:    notification.LastSent,
+		Recurrence:  notification.Recurrence,
+		FireAt:      notification.FireAt,
+		CompletedAt: notification.CompletedAt,
+		CatchUp:     notification.CatchUp,
+		Paused:      notification.Paused,
+		Version:     notification.Version,
+		DeletedAt:   notification.DeletedAt,
+		WebhookURL:  notification.WebhookURL,
 	}
 }
 
 // ToNotification returns the NotificationItem as a domain.Notification
 func (ni NotificationItem) ToNotification() domain.Notification {
 	return domain.Notification{
-		ID:         ni.ID,
-		TelegramID: ni.TelegramID,
-		Email:      ni.Email,
-		Message:    ni.Message,
-		Via:        ni.Via,
-		StartDate:  ni.StartDate,
-		EndDate:    ni.EndDate,
+		ID:          ni.ID,
+		ScheduleID:  ni.Schedule(),
+		TelegramID:  ni.TelegramID,
+		Email:       ni.Email,
+		Message:     ni.Message,
+		Via:         ni.Via,
+		StartDate:   ni.StartDate,
+		EndDate:     ni.EndDate,
+		TimeZone:    ni.TimeZone,
+		LastSent:    ni.LastSent,
+		Recurrence:  ni.Recurrence,
+		FireAt:      ni.FireAt,
+		CompletedAt: ni.CompletedAt,
+		CatchUp:     ni.CatchUp,
+		Paused:      ni.Paused,
+		Version:     ni.Version,
+		DeletedAt:   ni.DeletedAt,
+		WebhookURL:  ni.WebhookURL,
 	}
 }
+
+// Schedule returns the ID of the schedule of the notification. Notifications created before schedules existed are a
+// schedule by themselves
+func (ni NotificationItem) Schedule() string {
+	if ni.ScheduleID == "" {
+		return ni.ID
+	}
+
+	return ni.ScheduleID
+}
@@ -0,0 +1,120 @@
+package item
+
+import "time"
+
+// JournalOperation change saved in the journal of the in-memory store
+type JournalOperation string
+
+const (
+	// PutOperation creates or replaces a notification
+	PutOperation JournalOperation = "put"
+	// DeleteOperation deletes a notification
+	DeleteOperation JournalOperation = "delete"
+	// CheckpointOperation saves the last dispatched slot
+	CheckpointOperation JournalOperation = "checkpoint"
+	// AuditOperation saves an audit entry
+	AuditOperation JournalOperation = "audit"
+	// DeleteAuditOperation deletes an audit entry
+	DeleteAuditOperation JournalOperation = "delete_audit"
+	// DeliveryOperation creates or replaces a delivery of the outbox
+	DeliveryOperation JournalOperation = "delivery"
+	// DeleteDeliveryOperation deletes a delivery of the outbox
+	DeleteDeliveryOperation JournalOperation = "delete_delivery"
+	// WebhookSecretOperation creates or replaces the webhook secret of a user
+	WebhookSecretOperation JournalOperation = "webhook_secret"
+	// DeleteWebhookSecretOperation deletes the webhook secret of a user, identified by email
+	DeleteWebhookSecretOperation JournalOperation = "delete_webhook_secret"
+)
+
+// JournalEntry line of the append-only log of the in-memory store. Applying the same entry twice has no effect, so
+// entries already included in a snapshot can be replayed again
+type JournalEntry struct {
+	Operation     JournalOperation   `json:"operation"`
+	Hour          string             `json:"hour,omitempty"`
+	Notification  *NotificationItem  `json:"notification,omitempty"`
+	ID            string             `json:"id,omitempty"`
+	Time          *time.Time         `json:"time,omitempty"`
+	AuditEntry    *AuditItem         `json:"audit_entry,omitempty"`
+	Delivery      *DeliveryItem      `json:"delivery,omitempty"`
+	WebhookSecret *WebhookSecretItem `json:"webhook_secret,omitempty"`
+}
+
+// Snapshot whole state of the in-memory store
+type Snapshot struct {
+	Notifications      map[string][]NotificationItem `json:"notifications"`
+	LastDispatchedSlot *time.Time                    `json:"last_dispatched_slot,omitempty"`
+	AuditEntries       map[string][]AuditItem        `json:"audit_entries,omitempty"`
+	Deliveries         map[string]DeliveryItem       `json:"deliveries,omitempty"`
+	WebhookSecrets     map[string]WebhookSecretItem  `json:"webhook_secrets,omitempty"`
+}
+
+// Apply applies the given entry to the snapshot
+func (s *Snapshot) Apply(entry JournalEntry) {
+	switch entry.Operation {
+	case PutOperation:
+		s.delete(entry.Notification.ID)
+		s.Notifications[entry.Hour] = append(s.Notifications[entry.Hour], *entry.Notification)
+	case DeleteOperation:
+		s.delete(entry.ID)
+	case CheckpointOperation:
+		s.LastDispatchedSlot = entry.Time
+	case AuditOperation:
+		s.saveAuditEntry(*entry.AuditEntry)
+	case DeleteAuditOperation:
+		s.deleteAuditEntry(*entry.AuditEntry)
+	case DeliveryOperation:
+		if s.Deliveries == nil {
+			s.Deliveries = make(map[string]DeliveryItem)
+		}
+		s.Deliveries[entry.Delivery.ID] = *entry.Delivery
+	case DeleteDeliveryOperation:
+		delete(s.Deliveries, entry.ID)
+	case WebhookSecretOperation:
+		if s.WebhookSecrets == nil {
+			s.WebhookSecrets = make(map[string]WebhookSecretItem)
+		}
+		s.WebhookSecrets[entry.WebhookSecret.Email] = *entry.WebhookSecret
+	case DeleteWebhookSecretOperation:
+		delete(s.WebhookSecrets, entry.ID)
+	}
+}
+
+func (s *Snapshot) saveAuditEntry(auditItem AuditItem) {
+	if s.AuditEntries == nil {
+		s.AuditEntries = make(map[string][]AuditItem)
+	}
+
+	entries := s.AuditEntries[auditItem.NotificationID]
+	for idx := range entries {
+		if entries[idx].ID == auditItem.ID {
+			return
+		}
+	}
+
+	s.AuditEntries[auditItem.NotificationID] = append(entries, auditItem)
+}
+
+func (s *Snapshot) deleteAuditEntry(auditItem AuditItem) {
+	entries := s.AuditEntries[auditItem.NotificationID]
+	for idx := range entries {
+		if entries[idx].ID == auditItem.ID {
+			s.AuditEntries[auditItem.NotificationID] = append(entries[:idx:idx], entries[idx+1:]...)
+			break
+		}
+	}
+
+	if len(s.AuditEntries[auditItem.NotificationID]) == 0 {
+		delete(s.AuditEntries, auditItem.NotificationID)
+	}
+}
+
+func (s *Snapshot) delete(notificationID string) {
+	for hour, notificationsPerHour := range s.Notifications {
+		for idx := range notificationsPerHour {
+			if notificationsPerHour[idx].ID == notificationID {
+				s.Notifications[hour] = append(notificationsPerHour[:idx:idx], notificationsPerHour[idx+1:]...)
+				return
+			}
+		}
+	}
+}
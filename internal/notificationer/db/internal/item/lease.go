@@ -0,0 +1,15 @@
+package item
+
+import "time"
+
+// LeaseItem struct that is saved into the DB to know which instance holds a lease
+type LeaseItem struct {
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AvailableFor returns true if the lease can be taken by the given owner: it's expired or the owner already holds it
+func (li LeaseItem) AvailableFor(owner string, now time.Time) bool {
+	return li.Owner == owner || !now.Before(li.ExpiresAt)
+}
@@ -0,0 +1,30 @@
+package item
+
+import (
+	"notification-scheduler/internal/domain"
+	"time"
+)
+
+// WebhookSecretItem webhook secret of a user saved into the DB
+type WebhookSecretItem struct {
+	Email     string    `json:"email"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func CreateItemFromWebhookSecret(secret domain.WebhookSecret) WebhookSecretItem {
+	return WebhookSecretItem{
+		Email:     secret.Email,
+		Secret:    secret.Secret,
+		CreatedAt: secret.CreatedAt,
+	}
+}
+
+// ToWebhookSecret returns the WebhookSecretItem as a domain.WebhookSecret
+func (wsi WebhookSecretItem) ToWebhookSecret() domain.WebhookSecret {
+	return domain.WebhookSecret{
+		Email:     wsi.Email,
+		Secret:    wsi.Secret,
+		CreatedAt: wsi.CreatedAt,
+	}
+}
@@ -0,0 +1,224 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io/fs"
+	"notification-scheduler/internal/notificationer/db/internal/item"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	snapshotFileName = "snapshot.json"
+	logFileName      = "journal.log"
+
+	// maxJournalEntrySize size of the longest line that can be read from the log
+	maxJournalEntrySize = 1024 * 1024
+)
+
+// journal persists the in-memory store in a directory. Every change is appended to a log, and from time to time the
+// whole state is written to a snapshot, which empties the log. On boot, the snapshot is loaded and the log replayed
+type journal struct {
+	directory string
+	log       *os.File
+	// size of the log up to its last complete entry
+	size int64
+	// err is set if the log could not be restored after a failed write. No more entries are written until the next
+	// snapshot, as they would follow a partial one
+	err error
+}
+
+func openJournal(directory string) (*journal, error) {
+	err := os.MkdirAll(directory, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errOpeningJournal, err)
+	}
+
+	log, err := os.OpenFile(filepath.Join(directory, logFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errOpeningJournal, err)
+	}
+
+	return &journal{
+		directory: directory,
+		log:       log,
+	}, nil
+}
+
+// load returns the state saved in the snapshot with the changes of the log applied. Only the last entry of the log
+// can be invalid, if the process died while writing it, and it's ignored. An invalid entry followed by other ones
+// means the log is corrupted, so an error is returned. A snapshot must be taken after loading, to drop the ignored
+// entry before new ones are appended
+func (j *journal) load() (*item.Snapshot, error) {
+	snapshot := &item.Snapshot{}
+	rawSnapshot, err := os.ReadFile(filepath.Join(j.directory, snapshotFileName))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %v", errReplayingJournal, err)
+	}
+
+	if err == nil {
+		err = json.Unmarshal(rawSnapshot, snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid snapshot: %v", errReplayingJournal, err)
+		}
+	}
+
+	if snapshot.Notifications == nil {
+		snapshot.Notifications = make(map[string][]item.NotificationItem)
+	}
+
+	_, err = j.log.Seek(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errReplayingJournal, err)
+	}
+
+	scanner := bufio.NewScanner(j.log)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJournalEntrySize)
+	replayed := 0
+	var invalidEntryErr error
+	for scanner.Scan() {
+		if invalidEntryErr != nil {
+			return nil, fmt.Errorf("%w: corrupted entry %d: %v", errReplayingJournal, replayed+1, invalidEntryErr)
+		}
+
+		var entry item.JournalEntry
+		invalidEntryErr = json.Unmarshal(scanner.Bytes(), &entry)
+		if invalidEntryErr != nil {
+			continue
+		}
+
+		snapshot.Apply(entry)
+		replayed++
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errReplayingJournal, err)
+	}
+
+	if invalidEntryErr != nil {
+		logrus.Warnf("Ignoring the last journal entry, it was not completely written: %v", invalidEntryErr)
+	}
+
+	info, err := j.log.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errReplayingJournal, err)
+	}
+
+	j.size = info.Size()
+	logrus.Infof("Journal replayed: %d entries", replayed)
+	return snapshot, nil
+}
+
+// append writes the given entries at the end of the log and flushes them to disk. If they can't be written, the log
+// is truncated back to its previous size, so a partial entry is never followed by other ones
+func (j *journal) append(entries ...item.JournalEntry) error {
+	if j.err != nil {
+		return fmt.Errorf("%w: %v", errWritingJournal, j.err)
+	}
+
+	var rawEntries []byte
+	for _, entry := range entries {
+		rawEntry, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errWritingJournal, err)
+		}
+		rawEntries = append(append(rawEntries, rawEntry...), '\n')
+	}
+
+	_, err := j.log.Write(rawEntries)
+	if err == nil {
+		err = j.log.Sync()
+	}
+	if err != nil {
+		if truncateErr := j.log.Truncate(j.size); truncateErr != nil {
+			j.err = truncateErr
+		}
+		return fmt.Errorf("%w: %v", errWritingJournal, err)
+	}
+
+	j.size += int64(len(rawEntries))
+	return nil
+}
+
+// snapshot replaces the snapshot with the given one and empties the log. The new snapshot is written to a temporary
+// file that is renamed, so a failure never leaves a partial snapshot. If the log can't be emptied after the rename,
+// its entries are replayed again on boot, which has no effect
+func (j *journal) snapshot(snapshot item.Snapshot) error {
+	rawSnapshot, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errWritingSnapshot, err)
+	}
+
+	temporaryFile, err := os.CreateTemp(j.directory, snapshotFileName+".*")
+	if err != nil {
+		return fmt.Errorf("%w: %v", errWritingSnapshot, err)
+	}
+	defer os.Remove(temporaryFile.Name())
+
+	_, err = temporaryFile.Write(rawSnapshot)
+	if err == nil {
+		err = temporaryFile.Sync()
+	}
+	if closeErr := temporaryFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errWritingSnapshot, err)
+	}
+
+	err = os.Rename(temporaryFile.Name(), filepath.Join(j.directory, snapshotFileName))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errWritingSnapshot, err)
+	}
+
+	err = j.log.Truncate(0)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errWritingSnapshot, err)
+	}
+
+	j.size = 0
+	j.err = nil
+	return nil
+}
+
+// Snapshotter takes snapshots of a journaled FakeDB periodically, and one more when it's stopped
+type Snapshotter struct {
+	store    *FakeDB
+	interval time.Duration
+}
+
+func NewSnapshotter(store *FakeDB, interval time.Duration) *Snapshotter {
+	return &Snapshotter{
+		store:    store,
+		interval: interval,
+	}
+}
+
+// Run blocks until the given context is done
+func (s *Snapshotter) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.snapshot()
+			return
+		case <-ticker.C:
+			s.snapshot()
+		}
+	}
+}
+
+func (s *Snapshotter) snapshot() {
+	err := s.store.Snapshot()
+	if err != nil {
+		logrus.Errorf("error taking snapshot: %v", err)
+	}
+}
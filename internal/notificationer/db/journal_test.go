@@ -0,0 +1,121 @@
+package db
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournaledFakeDBReplaysLog(t *testing.T) {
+	directory := t.TempDir()
+	fake, err := NewJournaledFakeDB(directory)
+	require.NoError(t, err)
+
+	// The changes are only in the log, no snapshot is taken after them
+	created, err := fake.CreateNotifications(newTestNotification("larrycapija@testmail.com", "08:30", "20:00"), "user")
+	require.NoError(t, err)
+	updated := created[0]
+	updated.Message = "give the pills to Firulais and Pepita"
+	ok, err := fake.UpdateNotification(updated)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = fake.DeleteNotification(created[1].ID, 0)
+	require.NoError(t, err)
+	require.True(t, ok)
+	slot := time.Date(2024, 7, 1, 8, 30, 0, 0, time.UTC)
+	require.NoError(t, fake.SaveLastDispatchedSlot(slot))
+
+	reopened, err := NewJournaledFakeDB(directory)
+	require.NoError(t, err)
+
+	notifications, err := reopened.GetNotificationsByEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, created[0].ID, notifications[0].ID)
+	assert.Equal(t, "give the pills to Firulais and Pepita", notifications[0].Message)
+	assert.Equal(t, []string{"08:30"}, notifications[0].Hours)
+	assert.Equal(t, int64(2), notifications[0].Version)
+
+	lastSlot, err := reopened.GetLastDispatchedSlot()
+	require.NoError(t, err)
+	require.NotNil(t, lastSlot)
+	assert.True(t, lastSlot.Equal(slot))
+}
+
+func TestJournaledFakeDBIgnoresTornLastEntry(t *testing.T) {
+	directory := t.TempDir()
+	fake, err := NewJournaledFakeDB(directory)
+	require.NoError(t, err)
+	_, err = fake.CreateNotifications(newTestNotification("larrycapija@testmail.com", "08:30"), "user")
+	require.NoError(t, err)
+
+	appendToLog(t, directory, `{"operation":"put","hour":"20:00","notif`)
+
+	reopened, err := NewJournaledFakeDB(directory)
+	require.NoError(t, err)
+	notifications, err := reopened.GetNotificationsByEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	assert.Len(t, notifications, 1)
+
+	// The torn entry is dropped, so new entries are replayed
+	_, err = reopened.CreateNotifications(newTestNotification("larrycapija@testmail.com", "20:00"), "user")
+	require.NoError(t, err)
+	reopened, err = NewJournaledFakeDB(directory)
+	require.NoError(t, err)
+	notifications, err = reopened.GetNotificationsByEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	assert.Len(t, notifications, 2)
+}
+
+func TestJournaledFakeDBFailsOnCorruptedLog(t *testing.T) {
+	directory := t.TempDir()
+	fake, err := NewJournaledFakeDB(directory)
+	require.NoError(t, err)
+	_, err = fake.CreateNotifications(newTestNotification("larrycapija@testmail.com", "08:30"), "user")
+	require.NoError(t, err)
+
+	appendToLog(t, directory, "garbage\n")
+	_, err = fake.CreateNotifications(newTestNotification("larrycapija@testmail.com", "20:00"), "user")
+	require.NoError(t, err)
+	corruptedLog, err := os.ReadFile(filepath.Join(directory, logFileName))
+	require.NoError(t, err)
+
+	_, err = NewJournaledFakeDB(directory)
+	assert.ErrorIs(t, err, errReplayingJournal)
+
+	// The log is left untouched
+	log, err := os.ReadFile(filepath.Join(directory, logFileName))
+	require.NoError(t, err)
+	assert.Equal(t, corruptedLog, log)
+}
+
+func TestJournaledFakeDBDoesNotApplyChangesNotWritten(t *testing.T) {
+	fake, err := NewJournaledFakeDB(t.TempDir())
+	require.NoError(t, err)
+	created, err := fake.CreateNotifications(newTestNotification("larrycapija@testmail.com", "08:30"), "user")
+	require.NoError(t, err)
+
+	require.NoError(t, fake.journal.log.Close())
+	_, err = fake.CreateNotifications(newTestNotification("larrycapija@testmail.com", "20:00"), "user")
+	assert.ErrorIs(t, err, errWritingJournal)
+	ok, err := fake.DeleteNotification(created[0].ID, 0)
+	assert.ErrorIs(t, err, errWritingJournal)
+	assert.False(t, ok)
+
+	notifications, err := fake.GetNotificationsByEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, created[0].ID, notifications[0].ID)
+}
+
+// appendToLog writes the given data at the end of the log of the journal in the given directory
+func appendToLog(t *testing.T, directory string, data string) {
+	log, err := os.OpenFile(filepath.Join(directory, logFileName), os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = log.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, log.Close())
+}
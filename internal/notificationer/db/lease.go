@@ -0,0 +1,50 @@
+package db
+
+import (
+	"notification-scheduler/internal/notificationer/db/internal/item"
+	"sync"
+	"time"
+)
+
+// MemoryLeaser grants leases that are kept in memory. Leases are only shared by the instances that use the same
+// MemoryLeaser, so it's meant for a single process. Use the store to share leases between processes
+type MemoryLeaser struct {
+	mutex  sync.Mutex
+	leases map[string]item.LeaseItem
+}
+
+func NewMemoryLeaser() *MemoryLeaser {
+	return &MemoryLeaser{
+		leases: make(map[string]item.LeaseItem),
+	}
+}
+
+// AcquireLease takes the lease with the given name for the given owner. It returns false if another owner holds it
+func (ml *MemoryLeaser) AcquireLease(name string, owner string, ttl time.Duration) (bool, error) {
+	ml.mutex.Lock()
+	defer ml.mutex.Unlock()
+
+	now := time.Now()
+	removeExpiredLeases(ml.leases, now)
+	lease, found := ml.leases[name]
+	if found && !lease.AvailableFor(owner, now) {
+		return false, nil
+	}
+
+	ml.leases[name] = item.LeaseItem{
+		Name:      name,
+		Owner:     owner,
+		ExpiresAt: now.Add(ttl),
+	}
+	return true, nil
+}
+
+// removeExpiredLeases deletes the expired leases. Leases like the ones of the slots are never taken again once they
+// expire, so they would pile up otherwise
+func removeExpiredLeases(leases map[string]item.LeaseItem, now time.Time) {
+	for name, lease := range leases {
+		if !now.Before(lease.ExpiresAt) {
+			delete(leases, name)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"notification-scheduler/internal/notificationer/db/internal/item"
+	"testing"
+	"time"
+)
+
+func TestAcquireLeaseRemovesExpiredLeases(t *testing.T) {
+	fake := NewFakeDB(nil)
+	memoryLeaser := NewMemoryLeaser()
+
+	testCases := []struct {
+		name   string
+		leaser interface {
+			AcquireLease(name string, owner string, ttl time.Duration) (bool, error)
+		}
+		leases func() map[string]item.LeaseItem
+	}{
+		{name: "fake db", leaser: fake, leases: func() map[string]item.LeaseItem { return fake.leases }},
+		{name: "memory", leaser: memoryLeaser, leases: func() map[string]item.LeaseItem { return memoryLeaser.leases }},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			acquired, err := testCase.leaser.AcquireLease("slot:2024-07-01T08:00:00Z", "instance-1", time.Millisecond)
+			require.NoError(t, err)
+			require.True(t, acquired)
+			acquired, err = testCase.leaser.AcquireLease("sweep", "instance-1", time.Hour)
+			require.NoError(t, err)
+			require.True(t, acquired)
+
+			time.Sleep(5 * time.Millisecond)
+			acquired, err = testCase.leaser.AcquireLease("slot:2024-07-01T08:30:00Z", "instance-2", time.Hour)
+			require.NoError(t, err)
+			require.True(t, acquired)
+
+			assert.Len(t, testCase.leases(), 2)
+			assert.NotContains(t, testCase.leases(), "slot:2024-07-01T08:00:00Z")
+
+			// Leases that are not expired are kept
+			acquired, err = testCase.leaser.AcquireLease("sweep", "instance-2", time.Hour)
+			require.NoError(t, err)
+			assert.False(t, acquired)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migration schema change. Its version is the numeric prefix of the file name, for example 0001 in
+// 0001_create_notifications.sql
+type migration struct {
+	version    int
+	name       string
+	statements string
+}
+
+// backfills data changes that can't be written in SQL, by the version of the migration they complete. They run after
+// its statements, in the same transaction
+var backfills = map[int]func(transaction *sql.Tx) error{
+	10: backfillSearchMessages,
+}
+
+// loadMigrations returns the embedded migrations sorted by version
+func loadMigrations() ([]migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		prefix, _, found := strings.Cut(entry.Name(), "_")
+		version, err := strconv.Atoi(prefix)
+		if !found || err != nil {
+			return nil, fmt.Errorf("invalid migration name: %s", entry.Name())
+		}
+
+		statements, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, migration{
+			version:    version,
+			name:       entry.Name(),
+			statements: string(statements),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
+
+// migrate applies the migrations that were not applied yet. Each one runs in its own transaction, together with the
+// record of its version, so a failed migration can be retried on the next start
+func migrate(sqlDB *sql.DB) error {
+	_, err := sqlDB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var currentVersion int
+	err = sqlDB.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&currentVersion)
+	if err != nil {
+		return err
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= currentVersion {
+			continue
+		}
+
+		err = applyMigration(sqlDB, m)
+		if err != nil {
+			return fmt.Errorf("%s: %v", m.name, err)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(sqlDB *sql.DB, m migration) error {
+	transaction, err := sqlDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	_, err = transaction.Exec(m.statements)
+	if err != nil {
+		return err
+	}
+
+	backfill, found := backfills[m.version]
+	if found {
+		err = backfill(transaction)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = transaction.Exec(
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		m.version, m.name, time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return err
+	}
+
+	return transaction.Commit()
+}
+
+// backfillSearchMessages saves the message of every notification in lower case
+func backfillSearchMessages(transaction *sql.Tx) error {
+	rows, err := transaction.Query("SELECT id, message FROM notifications")
+	if err != nil {
+		return err
+	}
+
+	messages := make(map[string]string)
+	for rows.Next() {
+		var notificationID, message string
+		err = rows.Scan(&notificationID, &message)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		messages[notificationID] = message
+	}
+
+	err = errors.Join(rows.Err(), rows.Close())
+	if err != nil {
+		return err
+	}
+
+	for notificationID, message := range messages {
+		_, err = transaction.Exec(
+			"UPDATE notifications SET search_message = ? WHERE id = ?",
+			strings.ToLower(message), notificationID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
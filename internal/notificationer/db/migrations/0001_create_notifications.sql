@@ -0,0 +1,26 @@
+-- One row per notification and hour, like the other storages
+CREATE TABLE notifications (
+    id                TEXT PRIMARY KEY,
+    telegram_id       TEXT NOT NULL DEFAULT '',
+    email             TEXT NOT NULL DEFAULT '',
+    message           TEXT NOT NULL,
+    via               TEXT NOT NULL,
+    start_date        TEXT NOT NULL,
+    end_date          TEXT,
+    hour              TEXT NOT NULL,
+    time_zone         TEXT NOT NULL DEFAULT '',
+    last_sent         TEXT,
+    recurrence        TEXT NOT NULL DEFAULT '',
+    fire_at           TEXT,
+    completed_at      TEXT,
+    catch_up          TEXT NOT NULL DEFAULT '',
+    -- Dates are saved with their offset, so they are repeated as unix timestamps to be compared
+    end_date_unix     INTEGER,
+    completed_at_unix INTEGER
+);
+
+-- The scheduler searches the notifications of each slot by hour
+CREATE INDEX notifications_hour_idx ON notifications (hour);
+CREATE INDEX notifications_email_idx ON notifications (email);
+CREATE INDEX notifications_end_date_unix_idx ON notifications (end_date_unix);
+CREATE INDEX notifications_completed_at_unix_idx ON notifications (completed_at_unix);
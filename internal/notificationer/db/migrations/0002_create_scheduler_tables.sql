@@ -0,0 +1,10 @@
+CREATE TABLE leases (
+    name       TEXT PRIMARY KEY,
+    owner      TEXT NOT NULL,
+    expires_at INTEGER NOT NULL
+);
+
+CREATE TABLE checkpoints (
+    name TEXT PRIMARY KEY,
+    time TEXT NOT NULL
+);
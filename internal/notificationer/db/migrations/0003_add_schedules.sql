@@ -0,0 +1,7 @@
+ALTER TABLE notifications ADD COLUMN schedule_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE notifications ADD COLUMN paused INTEGER NOT NULL DEFAULT 0;
+
+-- Notifications created before schedules existed are a schedule by themselves
+UPDATE notifications SET schedule_id = id WHERE schedule_id = '';
+
+CREATE INDEX notifications_schedule_id_idx ON notifications (schedule_id);
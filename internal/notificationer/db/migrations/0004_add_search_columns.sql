@@ -0,0 +1,6 @@
+-- Start dates are saved with their offset, so they are repeated as unix timestamps to be compared
+ALTER TABLE notifications ADD COLUMN start_date_unix INTEGER NOT NULL DEFAULT 0;
+UPDATE notifications SET start_date_unix = CAST(strftime('%s', start_date) AS INTEGER);
+
+-- Searches are filtered by user and sorted by hour and ID
+CREATE INDEX notifications_email_hour_id_idx ON notifications (email, hour, id);
@@ -0,0 +1,2 @@
+-- Incremented on every change, so concurrent updates can be detected
+ALTER TABLE notifications ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,17 @@
+-- Deleted notifications are kept until the grace period to restore them is over
+ALTER TABLE notifications ADD COLUMN deleted_at TEXT;
+ALTER TABLE notifications ADD COLUMN deleted_at_unix INTEGER;
+
+-- The notifications before and after each change are saved as JSON
+CREATE TABLE audit_entries (
+    id              TEXT PRIMARY KEY,
+    notification_id TEXT NOT NULL,
+    email           TEXT NOT NULL,
+    action          TEXT NOT NULL,
+    actor           TEXT NOT NULL,
+    timestamp       TEXT NOT NULL,
+    before          TEXT,
+    after           TEXT
+);
+
+CREATE INDEX audit_entries_notification_id_idx ON audit_entries (notification_id, id);
@@ -0,0 +1,15 @@
+-- Outbox of the deliveries. The next attempt is saved in unix nanoseconds, like the expiration of the leases
+CREATE TABLE deliveries (
+    id               TEXT PRIMARY KEY,
+    notification_id  TEXT NOT NULL,
+    slot             TEXT NOT NULL,
+    status           TEXT NOT NULL,
+    attempts         INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at  INTEGER NOT NULL,
+    last_error       TEXT NOT NULL DEFAULT '',
+    created_at       TEXT NOT NULL,
+    finished_at      TEXT,
+    finished_at_unix INTEGER
+);
+
+CREATE INDEX deliveries_pending_idx ON deliveries (status, next_attempt_at);
@@ -0,0 +1,2 @@
+-- Each delivery is sent through a single channel. Deliveries enqueued before have none
+ALTER TABLE deliveries ADD COLUMN channel TEXT NOT NULL DEFAULT '';
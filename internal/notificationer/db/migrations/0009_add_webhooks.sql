@@ -0,0 +1,8 @@
+-- Notifications sent through a webhook carry its URL. Each user has a single secret to sign the webhooks
+ALTER TABLE notifications ADD COLUMN webhook_url TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE webhook_secrets (
+    email      TEXT PRIMARY KEY,
+    secret     TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
@@ -0,0 +1,3 @@
+-- SQLite only lowers ASCII letters, so the message is saved in lower case by the scheduler to search text. The rows
+-- saved before are filled in when the migration is applied
+ALTER TABLE notifications ADD COLUMN search_message TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- Audit entries are purged after the retention period, so their timestamps are repeated as unix timestamps to be
+-- compared
+ALTER TABLE audit_entries ADD COLUMN timestamp_unix INTEGER NOT NULL DEFAULT 0;
+UPDATE audit_entries SET timestamp_unix = CAST(strftime('%s', timestamp) AS INTEGER);
+
+CREATE INDEX audit_entries_timestamp_unix_idx ON audit_entries (timestamp_unix);
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"notification-scheduler/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestBackfillSearchMessages(t *testing.T) {
+	sqliteDB := newTestSQLiteDB(t)
+	created, err := sqliteDB.CreateNotifications(domain.Notification{
+		ScheduleID: "schedule",
+		Email:      "larrycapija@testmail.com",
+		Via:        domain.Mail,
+		Message:    "Dar de comer al ÑANDÚ",
+		StartDate:  time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		Hours:      []string{"08:30"},
+	}, "user")
+	require.NoError(t, err)
+
+	// Rows saved before the column existed
+	_, err = sqliteDB.db.Exec("UPDATE notifications SET search_message = ''")
+	require.NoError(t, err)
+
+	transaction, err := sqliteDB.db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, backfillSearchMessages(transaction))
+	require.NoError(t, transaction.Commit())
+
+	var searchMessage string
+	err = sqliteDB.db.QueryRow("SELECT search_message FROM notifications WHERE id = ?", created[0].ID).Scan(&searchMessage)
+	require.NoError(t, err)
+	assert.Equal(t, "dar de comer al ñandú", searchMessage)
+}
@@ -0,0 +1,945 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	_ "modernc.org/sqlite"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/notificationer/db/internal/item"
+	"notification-scheduler/internal/utils"
+	"strings"
+	"time"
+)
+
+const (
+	// writeColumns columns of a notification that are written as they are. The version is handled by each statement
+	writeColumns = "id, telegram_id, email, message, via, start_date, end_date, hour, time_zone, last_sent, " +
+		"recurrence, fire_at, completed_at, catch_up, schedule_id, paused, deleted_at, webhook_url"
+	notificationColumns = writeColumns + ", version"
+	deliveryColumns     = "id, notification_id, channel, slot, status, attempts, next_attempt_at, last_error, " +
+		"created_at, finished_at"
+
+	// Dates are saved as text, keeping their offset
+	sqliteTimeLayout = time.RFC3339Nano
+)
+
+// executor runs statements, either on the database or in a transaction
+type executor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// SQLiteDB stores the notifications in a SQLite database file, so the scheduler can run on a single box without any
+// other dependency. The schema is created and upgraded with the embedded migrations when the database is opened
+type SQLiteDB struct {
+	db *sql.DB
+}
+
+// NewSQLiteDB opens the SQLite database in the given path, creating it if needed, and applies the pending migrations
+func NewSQLiteDB(dbPath string) (*SQLiteDB, error) {
+	sqlDB, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errOpeningSQLite, err)
+	}
+
+	// SQLite allows a single writer, so a single connection avoids busy errors between the scheduler and the API
+	sqlDB.SetMaxOpenConns(1)
+
+	err = migrate(sqlDB)
+	if err != nil {
+		_ = sqlDB.Close()
+		return nil, fmt.Errorf("%w: %v", errRunningMigrations, err)
+	}
+
+	return &SQLiteDB{db: sqlDB}, nil
+}
+
+func (s *SQLiteDB) Close() error {
+	return s.db.Close()
+}
+
+// CreateNotifications creates one row per hour of the notification, all of them in the same transaction, along with
+// the audit entries of their creation by the given actor
+func (s *SQLiteDB) CreateNotifications(notification domain.Notification, actor string) ([]domain.Notification, error) {
+	transaction, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer transaction.Rollback()
+
+	var createdNotifications []domain.Notification
+	for _, hour := range notification.Hours {
+		if !utils.ValidHour(hour) {
+			return nil, fmt.Errorf("error creating notifications: invalid key")
+		}
+
+		// Keys are always saved as HH:MM
+		hour, _ = utils.NormalizeHour(hour)
+
+		notificationItem := item.CreateItemFromNotification(notification)
+		notificationItem.Version = 1
+		_, err = transaction.Exec(
+			"INSERT INTO notifications ("+writeColumns+", end_date_unix, completed_at_unix, start_date_unix, "+
+				"deleted_at_unix, search_message, version) "+
+				"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)",
+			notificationArgs(notificationItem, hour)...,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		createdNotification := notificationItem.ToNotification()
+		createdNotification.Hours = []string{hour}
+		createdNotifications = append(createdNotifications, createdNotification)
+	}
+
+	auditEntries, err := creationEntries(createdNotifications, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := range auditEntries {
+		err = saveAuditEntry(transaction, auditEntries[idx])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = transaction.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return createdNotifications, nil
+}
+
+func (s *SQLiteDB) GetNotificationsByEmail(email string) ([]domain.Notification, error) {
+	return s.queryNotifications("SELECT "+notificationColumns+" FROM notifications WHERE email = ?", email)
+}
+
+// SearchNotifications returns a page of the notifications of the user that match the query. The filters, the sort
+// and the limit are applied by SQLite
+func (s *SQLiteDB) SearchNotifications(query domain.NotificationQuery) (domain.NotificationPage, error) {
+	conditions := []string{"email = ?"}
+	args := []any{query.Email}
+
+	// Deleted notifications are never returned
+	conditions = append(conditions, "deleted_at_unix IS NULL")
+
+	if query.Via != "" {
+		conditions = append(conditions, "via = ?")
+		args = append(args, query.Via)
+	}
+
+	switch query.Status {
+	case domain.StatusActive:
+		conditions = append(conditions, "completed_at_unix IS NULL AND (end_date_unix IS NULL OR end_date_unix >= ?)")
+		args = append(args, query.Now.Unix())
+	case domain.StatusExpired:
+		conditions = append(conditions, "(completed_at_unix IS NOT NULL OR end_date_unix < ?)")
+		args = append(args, query.Now.Unix())
+	}
+
+	if query.HourFrom != "" {
+		conditions = append(conditions, "hour >= ?")
+		args = append(args, query.HourFrom)
+	}
+
+	if query.HourTo != "" {
+		conditions = append(conditions, "hour <= ?")
+		args = append(args, query.HourTo)
+	}
+
+	if query.Text != "" {
+		conditions = append(conditions, "instr(search_message, ?) > 0")
+		args = append(args, strings.ToLower(query.Text))
+	}
+
+	if query.To != nil {
+		conditions = append(conditions, "start_date_unix <= ?")
+		args = append(args, query.To.Unix())
+	}
+
+	if query.From != nil {
+		conditions = append(conditions, "(end_date_unix IS NULL OR end_date_unix >= ?)")
+		args = append(args, query.From.Unix())
+	}
+
+	where := " WHERE " + strings.Join(conditions, " AND ")
+	var total int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM notifications"+where, args...).Scan(&total)
+	if err != nil {
+		return domain.NotificationPage{}, err
+	}
+
+	if query.After != nil {
+		where += " AND (hour, id) > (?, ?)"
+		args = append(args, query.After.Hour, query.After.ID)
+	}
+
+	notifications, err := s.queryNotifications(
+		"SELECT "+notificationColumns+" FROM notifications"+where+" ORDER BY hour, id LIMIT ?",
+		append(args, query.Limit+1)...,
+	)
+	if err != nil {
+		return domain.NotificationPage{}, err
+	}
+
+	return domain.NewNotificationPage(notifications, total, query.Limit), nil
+}
+
+// GetNotificationsBySchedule returns all the notifications of the given schedule. A notification without schedule is
+// a schedule by itself
+func (s *SQLiteDB) GetNotificationsBySchedule(scheduleID string) ([]domain.Notification, error) {
+	return s.queryNotifications(
+		"SELECT "+notificationColumns+" FROM notifications WHERE schedule_id = ? OR (schedule_id = '' AND id = ?)",
+		scheduleID, scheduleID,
+	)
+}
+
+func (s *SQLiteDB) GetNotification(notificationID string) (*domain.Notification, error) {
+	notifications, err := s.queryNotifications(
+		"SELECT "+notificationColumns+" FROM notifications WHERE id = ?",
+		notificationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(notifications) == 0 {
+		return nil, nil
+	}
+
+	return &notifications[0], nil
+}
+
+// UpdateNotification replaces the notification if its version is the given one, and increments the version. The
+// given audit entries are saved with the change. It returns false if there is no notification with that ID and version
+func (s *SQLiteDB) UpdateNotification(
+	updatedNotification domain.Notification, auditEntries ...domain.AuditEntry,
+) (bool, error) {
+	return s.UpdateNotifications([]domain.Notification{updatedNotification}, auditEntries...)
+}
+
+// UpdateNotifications replaces all the given notifications in the same transaction, along with the given audit
+// entries. If any of them is not stored with the given version, the transaction is rolled back and false is returned
+func (s *SQLiteDB) UpdateNotifications(
+	updatedNotifications []domain.Notification, auditEntries ...domain.AuditEntry,
+) (bool, error) {
+	transaction, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer transaction.Rollback()
+
+	for _, updatedNotification := range updatedNotifications {
+		notificationItem := item.CreateItemFromNotification(updatedNotification)
+		// The last sent and completion dates are kept: they are set by the scheduler, that does not change the version
+		result, err := transaction.Exec(
+			"UPDATE notifications SET telegram_id = ?, email = ?, message = ?, via = ?, start_date = ?, end_date = ?, "+
+				"time_zone = ?, recurrence = ?, fire_at = ?, catch_up = ?, schedule_id = ?, paused = ?, deleted_at = ?, "+
+				"webhook_url = ?, end_date_unix = ?, start_date_unix = ?, deleted_at_unix = ?, search_message = ?, "+
+				"version = version + 1 WHERE id = ? AND hour = ? AND version = ?",
+			notificationItem.TelegramID,
+			notificationItem.Email,
+			notificationItem.Message,
+			notificationItem.Via,
+			notificationItem.StartDate.Format(sqliteTimeLayout),
+			formatTime(notificationItem.EndDate),
+			notificationItem.TimeZone,
+			notificationItem.Recurrence,
+			formatTime(notificationItem.FireAt),
+			notificationItem.CatchUp,
+			notificationItem.ScheduleID,
+			notificationItem.Paused,
+			formatTime(notificationItem.DeletedAt),
+			notificationItem.WebhookURL,
+			unixTime(notificationItem.EndDate),
+			notificationItem.StartDate.Unix(),
+			unixTime(notificationItem.DeletedAt),
+			strings.ToLower(notificationItem.Message),
+			notificationItem.ID,
+			updatedNotification.Hours[0],
+			notificationItem.Version,
+		)
+		if err != nil {
+			return false, err
+		}
+
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return false, err
+		}
+
+		if updated == 0 {
+			return false, nil
+		}
+	}
+
+	for idx := range auditEntries {
+		err = saveAuditEntry(transaction, auditEntries[idx])
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = transaction.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SetLastSent records the last slot sent of the notification. Its version is not changed, since the notification
+// was not changed by its user
+func (s *SQLiteDB) SetLastSent(notificationID string, lastSent time.Time) error {
+	result, err := s.db.Exec(
+		"UPDATE notifications SET last_sent = ? WHERE id = ?",
+		formatTime(&lastSent), notificationID,
+	)
+
+	return checkUpdated(result, err)
+}
+
+func (s *SQLiteDB) CompleteNotification(notificationID string, completedAt time.Time) error {
+	result, err := s.db.Exec(
+		"UPDATE notifications SET completed_at = ?, completed_at_unix = ? WHERE id = ?",
+		formatTime(&completedAt), completedAt.Unix(), notificationID,
+	)
+
+	return checkUpdated(result, err)
+}
+
+// CompleteExpired completes all the notifications whose end date is before the given instant
+func (s *SQLiteDB) CompleteExpired(now time.Time) (int, error) {
+	result, err := s.db.Exec(
+		"UPDATE notifications SET completed_at = ?, completed_at_unix = ? "+
+			"WHERE completed_at_unix IS NULL AND end_date_unix < ?",
+		formatTime(&now), now.Unix(), now.Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	completed, err := result.RowsAffected()
+	return int(completed), err
+}
+
+// PurgeCompleted deletes all the notifications completed before the given instant
+func (s *SQLiteDB) PurgeCompleted(completedBefore time.Time) (int, error) {
+	result, err := s.db.Exec(
+		"DELETE FROM notifications WHERE completed_at_unix < ?",
+		completedBefore.Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+// PurgeDeleted deletes all the notifications deleted before the given instant. Their audit entries are kept
+func (s *SQLiteDB) PurgeDeleted(deletedBefore time.Time) (int, error) {
+	result, err := s.db.Exec(
+		"DELETE FROM notifications WHERE deleted_at_unix < ?",
+		deletedBefore.Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+// DeleteNotification deletes the notification if its version is the given one. Version 0 deletes any version. It
+// returns false if there is no notification with that ID and version
+func (s *SQLiteDB) DeleteNotification(notificationID string, version int64) (bool, error) {
+	result, err := s.db.Exec(
+		"DELETE FROM notifications WHERE id = ? AND (? = 0 OR version = ?)",
+		notificationID, version, version,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
+
+// GetAll returns the notifications scheduled at the given hour, using the hour index
+func (s *SQLiteDB) GetAll(key string) ([]domain.Notification, error) {
+	key, err := utils.NormalizeHour(key)
+	if err != nil {
+		return nil, nil
+	}
+
+	return s.queryNotifications("SELECT "+notificationColumns+" FROM notifications WHERE hour = ?", key)
+}
+
+// GetTimeZones returns all the different time zones used by the saved notifications
+func (s *SQLiteDB) GetTimeZones() ([]string, error) {
+	rows, err := s.db.Query("SELECT DISTINCT time_zone FROM notifications WHERE time_zone <> ''")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var timeZones []string
+	for rows.Next() {
+		var timeZone string
+		err = rows.Scan(&timeZone)
+		if err != nil {
+			return nil, err
+		}
+		timeZones = append(timeZones, timeZone)
+	}
+
+	return timeZones, rows.Err()
+}
+
+func (s *SQLiteDB) GetLastDispatchedSlot() (*time.Time, error) {
+	var slot sql.NullString
+	err := s.db.QueryRow("SELECT time FROM checkpoints WHERE name = ?", lastDispatchedSlotName).Scan(&slot)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTime(slot)
+}
+
+func (s *SQLiteDB) SaveLastDispatchedSlot(slot time.Time) error {
+	_, err := s.db.Exec(
+		"INSERT INTO checkpoints (name, time) VALUES (?, ?) ON CONFLICT (name) DO UPDATE SET time = excluded.time",
+		lastDispatchedSlotName, formatTime(&slot),
+	)
+
+	return err
+}
+
+// AcquireLease takes the lease with the given name for the given owner. The upsert only changes the lease if it's
+// expired or already belongs to the owner, so the lease is acquired if a row was written. Expired leases of other
+// names are deleted, as leases like the ones of the slots are never taken again
+func (s *SQLiteDB) AcquireLease(name string, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	_, err := s.db.Exec("DELETE FROM leases WHERE expires_at <= ? AND name <> ?", now.UnixNano(), name)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := s.db.Exec(
+		"INSERT INTO leases (name, owner, expires_at) VALUES (?, ?, ?) "+
+			"ON CONFLICT (name) DO UPDATE SET owner = excluded.owner, expires_at = excluded.expires_at "+
+			"WHERE leases.expires_at <= ? OR leases.owner = ?",
+		name, owner, now.Add(ttl).UnixNano(), now.UnixNano(), owner,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	written, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return written > 0, nil
+}
+
+// SaveAuditEntry saves the given entry. The notifications before and after the change are saved as JSON
+func (s *SQLiteDB) SaveAuditEntry(entry domain.AuditEntry) error {
+	return saveAuditEntry(s.db, entry)
+}
+
+// saveAuditEntry saves the given entry with the given executor, the database itself or a transaction
+func saveAuditEntry(executor executor, entry domain.AuditEntry) error {
+	auditItem := item.CreateItemFromAuditEntry(entry)
+	before, err := marshalAuditNotification(auditItem.Before)
+	if err != nil {
+		return err
+	}
+
+	after, err := marshalAuditNotification(auditItem.After)
+	if err != nil {
+		return err
+	}
+
+	_, err = executor.Exec(
+		"INSERT INTO audit_entries (id, notification_id, email, action, actor, timestamp, timestamp_unix, before, after) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		auditItem.ID, auditItem.NotificationID, auditItem.Email, auditItem.Action, auditItem.Actor,
+		formatTime(&auditItem.Timestamp), auditItem.Timestamp.Unix(), before, after,
+	)
+
+	return err
+}
+
+// PurgeAuditEntries deletes the audit entries saved before the given instant
+func (s *SQLiteDB) PurgeAuditEntries(savedBefore time.Time) (int, error) {
+	result, err := s.db.Exec(
+		"DELETE FROM audit_entries WHERE timestamp_unix < ?",
+		savedBefore.Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+// GetAuditEntries returns the audit entries of the given notification, sorted by ID
+func (s *SQLiteDB) GetAuditEntries(notificationID string) ([]domain.AuditEntry, error) {
+	rows, err := s.db.Query(
+		"SELECT id, notification_id, email, action, actor, timestamp, before, after FROM audit_entries "+
+			"WHERE notification_id = ? ORDER BY id",
+		notificationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []domain.AuditEntry
+	for rows.Next() {
+		var auditItem item.AuditItem
+		var timestamp, before, after sql.NullString
+		err = rows.Scan(
+			&auditItem.ID,
+			&auditItem.NotificationID,
+			&auditItem.Email,
+			&auditItem.Action,
+			&auditItem.Actor,
+			&timestamp,
+			&before,
+			&after,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		parsedTimestamp, err := parseTime(timestamp)
+		if err != nil {
+			return nil, err
+		}
+		auditItem.Timestamp = *parsedTimestamp
+
+		auditItem.Before, err = unmarshalAuditNotification(before)
+		if err != nil {
+			return nil, err
+		}
+
+		auditItem.After, err = unmarshalAuditNotification(after)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, auditItem.ToAuditEntry())
+	}
+
+	return entries, rows.Err()
+}
+
+// EnqueueDeliveries saves the given deliveries in the same transaction, skipping the ones that already exist. It
+// returns the amount saved
+func (s *SQLiteDB) EnqueueDeliveries(deliveries []domain.Delivery) (int, error) {
+	transaction, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer transaction.Rollback()
+
+	enqueued := 0
+	for idx := range deliveries {
+		deliveryItem := item.CreateItemFromDelivery(deliveries[idx])
+		result, err := transaction.Exec(
+			"INSERT INTO deliveries ("+deliveryColumns+", finished_at_unix) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) "+
+				"ON CONFLICT (id) DO NOTHING",
+			deliveryArgs(deliveryItem)...,
+		)
+		if err != nil {
+			return 0, err
+		}
+
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		enqueued += int(inserted)
+	}
+
+	return enqueued, transaction.Commit()
+}
+
+// ClaimDeliveries claims up to limit pending deliveries whose next attempt is not after now, the ones that have
+// waited longer first. Claimed deliveries get one more attempt and are not claimed again until leaseUntil. The
+// search and the claim run in the same transaction
+func (s *SQLiteDB) ClaimDeliveries(now time.Time, leaseUntil time.Time, limit int) ([]domain.Delivery, error) {
+	transaction, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer transaction.Rollback()
+
+	rows, err := transaction.Query(
+		"SELECT "+deliveryColumns+" FROM deliveries WHERE status = ? AND next_attempt_at <= ? "+
+			"ORDER BY next_attempt_at, slot, id LIMIT ?",
+		domain.DeliveryPending, now.UnixNano(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var claimed []domain.Delivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		claimed = append(claimed, delivery)
+	}
+	if err = rows.Close(); err != nil {
+		return nil, err
+	}
+
+	for idx := range claimed {
+		claimed[idx].Attempts++
+		claimed[idx].NextAttemptAt = leaseUntil
+		_, err = transaction.Exec(
+			"UPDATE deliveries SET attempts = ?, next_attempt_at = ? WHERE id = ?",
+			claimed[idx].Attempts, leaseUntil.UnixNano(), claimed[idx].ID,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return claimed, transaction.Commit()
+}
+
+// UpdateDelivery replaces the delivery if it has the same attempts as the saved one, so a worker whose delivery was
+// claimed again by another one does not overwrite it. It returns false if the delivery was not replaced
+func (s *SQLiteDB) UpdateDelivery(delivery domain.Delivery) (bool, error) {
+	deliveryItem := item.CreateItemFromDelivery(delivery)
+	result, err := s.db.Exec(
+		"UPDATE deliveries SET channel = ?, status = ?, next_attempt_at = ?, last_error = ?, finished_at = ?, "+
+			"finished_at_unix = ? WHERE id = ? AND attempts = ?",
+		deliveryItem.Channel, deliveryItem.Status, deliveryItem.NextAttemptAt.UnixNano(), deliveryItem.LastError,
+		formatTime(deliveryItem.FinishedAt), unixTime(deliveryItem.FinishedAt), deliveryItem.ID, deliveryItem.Attempts,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return updated > 0, nil
+}
+
+// PurgeDeliveries deletes the deliveries finished before the given instant
+func (s *SQLiteDB) PurgeDeliveries(finishedBefore time.Time) (int, error) {
+	result, err := s.db.Exec(
+		"DELETE FROM deliveries WHERE finished_at_unix < ?",
+		finishedBefore.Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+// SaveWebhookSecret saves the webhook secret of the user, replacing the previous one
+func (s *SQLiteDB) SaveWebhookSecret(secret domain.WebhookSecret) error {
+	_, err := s.db.Exec(
+		"INSERT INTO webhook_secrets (email, secret, created_at) VALUES (?, ?, ?) "+
+			"ON CONFLICT (email) DO UPDATE SET secret = excluded.secret, created_at = excluded.created_at",
+		secret.Email, secret.Secret, formatTime(&secret.CreatedAt),
+	)
+
+	return err
+}
+
+// GetWebhookSecret returns the webhook secret of the user, nil if there is none
+func (s *SQLiteDB) GetWebhookSecret(email string) (*domain.WebhookSecret, error) {
+	secretItem := item.WebhookSecretItem{Email: email}
+	var createdAt sql.NullString
+	err := s.db.QueryRow("SELECT secret, created_at FROM webhook_secrets WHERE email = ?", email).
+		Scan(&secretItem.Secret, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	parsedCreatedAt, err := parseTime(createdAt)
+	if err != nil {
+		return nil, err
+	}
+	secretItem.CreatedAt = *parsedCreatedAt
+
+	secret := secretItem.ToWebhookSecret()
+	return &secret, nil
+}
+
+func (s *SQLiteDB) DeleteWebhookSecret(email string) error {
+	_, err := s.db.Exec("DELETE FROM webhook_secrets WHERE email = ?", email)
+	return err
+}
+
+func (s *SQLiteDB) queryNotifications(query string, args ...any) ([]domain.Notification, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []domain.Notification
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, rows.Err()
+}
+
+// scanNotification reads a row with the notificationColumns
+func scanNotification(rows *sql.Rows) (domain.Notification, error) {
+	var notificationItem item.NotificationItem
+	var hour, startDate string
+	var endDate, lastSent, fireAt, completedAt, deletedAt sql.NullString
+	err := rows.Scan(
+		&notificationItem.ID,
+		&notificationItem.TelegramID,
+		&notificationItem.Email,
+		&notificationItem.Message,
+		&notificationItem.Via,
+		&startDate,
+		&endDate,
+		&hour,
+		&notificationItem.TimeZone,
+		&lastSent,
+		&notificationItem.Recurrence,
+		&fireAt,
+		&completedAt,
+		&notificationItem.CatchUp,
+		&notificationItem.ScheduleID,
+		&notificationItem.Paused,
+		&deletedAt,
+		&notificationItem.WebhookURL,
+		&notificationItem.Version,
+	)
+	if err != nil {
+		return domain.Notification{}, err
+	}
+
+	notificationItem.StartDate, err = time.Parse(sqliteTimeLayout, startDate)
+	if err != nil {
+		return domain.Notification{}, err
+	}
+
+	for _, date := range []struct {
+		value  sql.NullString
+		target **time.Time
+	}{
+		{endDate, &notificationItem.EndDate},
+		{lastSent, &notificationItem.LastSent},
+		{fireAt, &notificationItem.FireAt},
+		{completedAt, &notificationItem.CompletedAt},
+		{deletedAt, &notificationItem.DeletedAt},
+	} {
+		*date.target, err = parseTime(date.value)
+		if err != nil {
+			return domain.Notification{}, err
+		}
+	}
+
+	notification := notificationItem.ToNotification()
+	notification.Hours = []string{hour}
+	return notification, nil
+}
+
+// notificationArgs returns the values of the writeColumns, followed by the unix timestamps of the end,
+// completion, start and deletion dates and the message in lower case
+func notificationArgs(notificationItem item.NotificationItem, hour string) []any {
+	return []any{
+		notificationItem.ID,
+		notificationItem.TelegramID,
+		notificationItem.Email,
+		notificationItem.Message,
+		notificationItem.Via,
+		notificationItem.StartDate.Format(sqliteTimeLayout),
+		formatTime(notificationItem.EndDate),
+		hour,
+		notificationItem.TimeZone,
+		formatTime(notificationItem.LastSent),
+		notificationItem.Recurrence,
+		formatTime(notificationItem.FireAt),
+		formatTime(notificationItem.CompletedAt),
+		notificationItem.CatchUp,
+		notificationItem.ScheduleID,
+		notificationItem.Paused,
+		formatTime(notificationItem.DeletedAt),
+		notificationItem.WebhookURL,
+		unixTime(notificationItem.EndDate),
+		unixTime(notificationItem.CompletedAt),
+		notificationItem.StartDate.Unix(),
+		unixTime(notificationItem.DeletedAt),
+		strings.ToLower(notificationItem.Message),
+	}
+}
+
+func scanDelivery(rows *sql.Rows) (domain.Delivery, error) {
+	var deliveryItem item.DeliveryItem
+	var slot, createdAt, finishedAt sql.NullString
+	var nextAttemptAt int64
+	err := rows.Scan(
+		&deliveryItem.ID,
+		&deliveryItem.NotificationID,
+		&deliveryItem.Channel,
+		&slot,
+		&deliveryItem.Status,
+		&deliveryItem.Attempts,
+		&nextAttemptAt,
+		&deliveryItem.LastError,
+		&createdAt,
+		&finishedAt,
+	)
+	if err != nil {
+		return domain.Delivery{}, err
+	}
+
+	deliveryItem.NextAttemptAt = time.Unix(0, nextAttemptAt)
+	for _, date := range []struct {
+		value  sql.NullString
+		target *time.Time
+	}{
+		{slot, &deliveryItem.Slot},
+		{createdAt, &deliveryItem.CreatedAt},
+	} {
+		parsed, err := parseTime(date.value)
+		if err != nil {
+			return domain.Delivery{}, err
+		}
+		*date.target = *parsed
+	}
+
+	deliveryItem.FinishedAt, err = parseTime(finishedAt)
+	if err != nil {
+		return domain.Delivery{}, err
+	}
+
+	return deliveryItem.ToDelivery(), nil
+}
+
+// deliveryArgs returns the values of the deliveryColumns, followed by the unix timestamp of the finish date
+func deliveryArgs(deliveryItem item.DeliveryItem) []any {
+	return []any{
+		deliveryItem.ID,
+		deliveryItem.NotificationID,
+		deliveryItem.Channel,
+		formatTime(&deliveryItem.Slot),
+		deliveryItem.Status,
+		deliveryItem.Attempts,
+		deliveryItem.NextAttemptAt.UnixNano(),
+		deliveryItem.LastError,
+		formatTime(&deliveryItem.CreatedAt),
+		formatTime(deliveryItem.FinishedAt),
+		unixTime(deliveryItem.FinishedAt),
+	}
+}
+
+// marshalAuditNotification returns the given notification of an audit entry as JSON, or nil if there is none
+func marshalAuditNotification(notification *item.AuditNotificationItem) (any, error) {
+	if notification == nil {
+		return nil, nil
+	}
+
+	rawNotification, err := json.Marshal(notification)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(rawNotification), nil
+}
+
+func unmarshalAuditNotification(value sql.NullString) (*item.AuditNotificationItem, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+
+	var notification item.AuditNotificationItem
+	err := json.Unmarshal([]byte(value.String), &notification)
+	if err != nil {
+		return nil, err
+	}
+
+	return &notification, nil
+}
+
+// checkUpdated returns an error if the update failed or did not find the notification
+func checkUpdated(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if updated == 0 {
+		return fmt.Errorf("error notification not found")
+	}
+
+	return nil
+}
+
+func formatTime(date *time.Time) any {
+	if date == nil {
+		return nil
+	}
+
+	return date.Format(sqliteTimeLayout)
+}
+
+func unixTime(date *time.Time) any {
+	if date == nil {
+		return nil
+	}
+
+	return date.Unix()
+}
+
+func parseTime(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(sqliteTimeLayout, value.String)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsed, nil
+}
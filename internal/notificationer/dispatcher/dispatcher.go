@@ -0,0 +1,290 @@
+package dispatcher
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/utils"
+	"sort"
+	"sync"
+	"time"
+)
+
+type servicer interface {
+	GetAll(hour string) ([]domain.Notification, error)
+	GetTimeZones() ([]string, error)
+	CompleteNotification(notificationID string, completedAt time.Time) error
+	EnqueueDeliveries(deliveries []domain.Delivery) (int, error)
+	GetLastDispatchedSlot() (*time.Time, error)
+	SaveLastDispatchedSlot(slot time.Time) error
+	ClaimSlot(slot time.Time) (bool, error)
+}
+
+// completer marks notifications as completed, both servicers of the package do it
+type completer interface {
+	CompleteNotification(notificationID string, completedAt time.Time) error
+}
+
+// Dispatcher enqueues the deliveries of the notifications that are scheduled for a given slot. The Outbox sends them
+type Dispatcher struct {
+	// mutex avoids enqueuing twice the same slot when the scheduler and the trigger endpoint dispatch at the same time
+	mutex         sync.Mutex
+	service       servicer
+	catchUpWindow time.Duration
+}
+
+// NewDispatcher creates a Dispatcher. The catch-up window is how far back missed slots are replayed, zero disables
+// the replay of missed slots
+func NewDispatcher(service servicer, catchUpWindow time.Duration) *Dispatcher {
+	return &Dispatcher{
+		service:       service,
+		catchUpWindow: catchUpWindow,
+	}
+}
+
+// Dispatch enqueues the deliveries of all the notifications scheduled for the given slot. It returns the amount of
+// notifications due for that slot. The slot is only saved as dispatched once its deliveries are in the outbox, and
+// each occurrence is enqueued once, so dispatching the same slot again does not send it twice. If another instance
+// claimed the slot, nothing is enqueued
+func (d *Dispatcher) Dispatch(slot time.Time) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	claimed, err := d.claim(slot)
+	if err != nil || !claimed {
+		return 0, err
+	}
+
+	return d.dispatch(slot)
+}
+
+// CatchUp dispatches the slot of the given instant, if this instance claims it. Before doing it, the slots missed
+// since the last dispatched one are replayed, going back at most the catch-up window. The occurrences found in the
+// missed slots are enqueued according to the catch-up policy of each notification. It returns the amount of
+// occurrences enqueued
+func (d *Dispatcher) CatchUp(now time.Time) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	currentSlot := utils.SlotOf(now)
+	claimed, err := d.claim(currentSlot)
+	if err != nil || !claimed {
+		return 0, err
+	}
+
+	missedSlots, err := d.missedSlots(currentSlot)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	if len(missedSlots) > 0 {
+		replayed, err = d.replay(missedSlots)
+		if err != nil {
+			return 0, err
+		}
+		logrus.Infof("Replayed %d missed slots: %d notifications", len(missedSlots), replayed)
+	}
+
+	dispatched, err := d.dispatch(currentSlot)
+	return replayed + dispatched, err
+}
+
+// claim returns true if this instance has to dispatch the given slot
+func (d *Dispatcher) claim(slot time.Time) (bool, error) {
+	claimed, err := d.service.ClaimSlot(slot)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", errClaimingSlot, err)
+	}
+
+	if !claimed {
+		logrus.Infof("Slot %s claimed by another instance", slot.Format(time.RFC3339))
+	}
+
+	return claimed, nil
+}
+
+func (d *Dispatcher) dispatch(slot time.Time) (int, error) {
+	notifications, err := d.dueNotifications(slot)
+	if err != nil {
+		return 0, err
+	}
+
+	var deliveries []domain.Delivery
+	for idx := range notifications {
+		deliveries = append(deliveries, newDeliveries(notifications[idx], slot)...)
+	}
+
+	_, err = d.service.EnqueueDeliveries(deliveries)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errEnqueuingDeliveries, err)
+	}
+
+	err = d.service.SaveLastDispatchedSlot(slot)
+	if err != nil {
+		logrus.Errorf("error saving last dispatched slot: %v", err)
+	}
+
+	return len(notifications), nil
+}
+
+// missedSlots returns the slots between the last dispatched one and the current one, both excluded. Only the slots
+// inside the catch-up window are returned
+func (d *Dispatcher) missedSlots(currentSlot time.Time) ([]time.Time, error) {
+	if d.catchUpWindow <= 0 {
+		return nil, nil
+	}
+
+	lastSlot, err := d.service.GetLastDispatchedSlot()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errSearchingLastDispatchedSlot, err)
+	}
+
+	windowStart := currentSlot.Add(-d.catchUpWindow)
+	firstSlot := utils.SlotOf(windowStart)
+	if firstSlot.Before(windowStart) {
+		firstSlot = firstSlot.Add(utils.SlotDuration)
+	}
+
+	if lastSlot != nil && !lastSlot.Before(firstSlot) {
+		firstSlot = lastSlot.Add(utils.SlotDuration)
+	}
+
+	var slots []time.Time
+	for slot := firstSlot; slot.Before(currentSlot); slot = slot.Add(utils.SlotDuration) {
+		slots = append(slots, slot)
+	}
+
+	return slots, nil
+}
+
+// occurrence of a notification at a given slot
+type occurrence struct {
+	notification domain.Notification
+	slot         time.Time
+}
+
+// replay enqueues the occurrences found in the given slots according to the catch-up policy of each notification:
+// + all: every occurrence is enqueued
+// + latest: only the latest occurrence is enqueued. Notifications without policy use this one
+// + skip: none is enqueued. One-shot notifications are completed, as they will never be sent
+func (d *Dispatcher) replay(slots []time.Time) (int, error) {
+	var occurrences []occurrence
+	latestOccurrences := make(map[string]occurrence)
+	for _, slot := range slots {
+		notifications, err := d.dueNotifications(slot)
+		if err != nil {
+			logrus.Errorf("error replaying slot %s: %v", slot.Format(time.RFC3339), err)
+			continue
+		}
+
+		for idx := range notifications {
+			notification := notifications[idx]
+			switch notification.CatchUp {
+			case domain.CatchUpSkip:
+				if notification.IsOneShot() {
+					complete(d.service, notification)
+				}
+			case domain.CatchUpAll:
+				occurrences = append(occurrences, occurrence{notification: notification, slot: slot})
+			default:
+				latestOccurrences[notification.ID] = occurrence{notification: notification, slot: slot}
+			}
+		}
+	}
+
+	for _, latestOccurrence := range latestOccurrences {
+		occurrences = append(occurrences, latestOccurrence)
+	}
+
+	// Deliveries are claimed by slot, so older occurrences are sent first
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].slot.Before(occurrences[j].slot)
+	})
+
+	var deliveries []domain.Delivery
+	for _, missedOccurrence := range occurrences {
+		deliveries = append(deliveries, newDeliveries(missedOccurrence.notification, missedOccurrence.slot)...)
+	}
+
+	_, err := d.service.EnqueueDeliveries(deliveries)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errEnqueuingDeliveries, err)
+	}
+
+	return len(occurrences), nil
+}
+
+// dueNotifications returns the notifications that must be sent at the given slot. Hours are saved in the time zone
+// of each notification, so the hours to look up are the ones that match the slot in every time zone in use
+func (d *Dispatcher) dueNotifications(slot time.Time) ([]domain.Notification, error) {
+	timeZones, err := d.service.GetTimeZones()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errSearchingTimeZones, err)
+	}
+
+	// Notifications without time zone use the one of the server
+	timeZones = append(timeZones, "")
+
+	var hours []string
+	for _, timeZone := range timeZones {
+		for _, hour := range slotHours(slot, utils.LoadLocation(timeZone)) {
+			if !utils.Contains(hours, hour) {
+				hours = append(hours, hour)
+			}
+		}
+	}
+
+	var dueNotifications []domain.Notification
+	for _, hour := range hours {
+		notifications, err := d.service.GetAll(hour)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %v", errSearchingNotifications, hour, err)
+		}
+
+		// Sanity check: the same hour might be the current slot in one time zone but not in another one. Also
+		// notifications out of their active window or already sent are skipped
+		for idx := range notifications {
+			if notifications[idx].IsDue(slot) {
+				dueNotifications = append(dueNotifications, notifications[idx])
+			}
+		}
+	}
+
+	return dueNotifications, nil
+}
+
+// newDeliveries returns the deliveries of the occurrence of the notification at the given slot, one per channel the
+// notification asks for
+func newDeliveries(notification domain.Notification, slot time.Time) []domain.Delivery {
+	var deliveries []domain.Delivery
+	for _, via := range notification.Via.Channels() {
+		deliveries = append(deliveries, domain.NewDelivery(notification.ID, via, slot, time.Now()))
+	}
+
+	return deliveries
+}
+
+// complete marks the notification as completed, it's never sent again
+func complete(service completer, notification domain.Notification) {
+	err := service.CompleteNotification(notification.ID, time.Now())
+	if err != nil {
+		logrus.Errorf("error completing notification %s: %v", notification.ID, err)
+	}
+}
+
+// slotHours returns the hours of the day, on the hour or at thirty, that happen at the given slot in the given location
+func slotHours(slot time.Time, location *time.Location) []string {
+	var hours []string
+	for hour := 0; hour < 24; hour++ {
+		for _, minutes := range []int{0, 30} {
+			key := utils.FormatHour(hour, minutes)
+			fireTime, err := utils.FireTime(slot, key, location)
+			if err == nil && fireTime.Equal(slot) {
+				hours = append(hours, key)
+			}
+		}
+	}
+
+	return hours
+}
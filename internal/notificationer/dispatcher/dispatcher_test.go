@@ -0,0 +1,269 @@
+package dispatcher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/externalservices/email"
+	"notification-scheduler/internal/notificationer/channel"
+	"notification-scheduler/internal/notificationer/db"
+	"notification-scheduler/internal/notificationer/service"
+	"notification-scheduler/internal/notificationer/templates"
+	"notification-scheduler/internal/utils"
+	"sync"
+	"testing"
+	"time"
+)
+
+type leaser interface {
+	AcquireLease(name string, owner string, ttl time.Duration) (bool, error)
+}
+
+var testOutboxConfig = OutboxConfig{
+	Workers:      1,
+	MaxAttempts:  3,
+	BaseBackoff:  time.Minute,
+	MaxBackoff:   time.Hour,
+	PollInterval: time.Second,
+}
+
+// emailClientMock records the sent mails. The first failures calls fail with err
+type emailClientMock struct {
+	mutex    sync.Mutex
+	sent     []email.Mail
+	failures int
+	err      error
+}
+
+func (ecm *emailClientMock) SendEmail(mail email.Mail) error {
+	ecm.mutex.Lock()
+	defer ecm.mutex.Unlock()
+	if ecm.failures > 0 {
+		ecm.failures--
+		return ecm.err
+	}
+
+	ecm.sent = append(ecm.sent, mail)
+	return nil
+}
+
+// newTestMailChannel returns a mail channel that renders the emails with the embedded templates
+func newTestMailChannel(t *testing.T, emailClient *emailClientMock) *channel.MailChannel {
+	renderer, err := templates.NewRenderer("")
+	require.NoError(t, err)
+
+	return channel.NewMailChannel(emailClient, renderer)
+}
+
+func TestDispatchSlotWithReplicasSharingStore(t *testing.T) {
+	slot := time.Date(2024, 7, 1, 8, 30, 0, 0, time.UTC)
+	request := domain.NotificationRequest{
+		Email:     "larrycapija@testmail.com",
+		Via:       domain.Mail,
+		Message:   "give the pills to Firulais",
+		StartDate: slot.AddDate(0, 0, -1),
+		Hours:     []string{"8:30"},
+		TimeZone:  "UTC",
+	}
+
+	testCases := []struct {
+		name      string
+		newLeaser func(store *db.FakeDB) leaser
+	}{
+		{
+			name:      "leases in the store",
+			newLeaser: func(store *db.FakeDB) leaser { return store },
+		},
+		{
+			name:      "leases in memory",
+			newLeaser: func(_ *db.FakeDB) leaser { return db.NewMemoryLeaser() },
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			store := db.NewFakeDB(nil)
+			sharedLeaser := testCase.newLeaser(store)
+			_, err := service.NewNotificationService(store, sharedLeaser, 0).ScheduleNotifications(request.ToNotification(), "")
+			require.NoError(t, err)
+
+			// Each replica has its own service, so its own instance ID, but both share the store
+			emailClient := &emailClientMock{}
+			replicas := []*Dispatcher{
+				NewDispatcher(service.NewNotificationService(store, sharedLeaser, 0), 0),
+				NewDispatcher(service.NewNotificationService(store, sharedLeaser, 0), 0),
+			}
+			channels := channel.NewRegistry(newTestMailChannel(t, emailClient))
+			outbox := NewOutbox(service.NewNotificationService(store, sharedLeaser, 0), channels, testOutboxConfig)
+
+			var wg sync.WaitGroup
+			dispatched := make([]int, len(replicas))
+			for idx := range replicas {
+				wg.Add(1)
+				go func(idx int) {
+					defer wg.Done()
+					var dispatchErr error
+					dispatched[idx], dispatchErr = replicas[idx].Dispatch(slot)
+					assert.NoError(t, dispatchErr)
+				}(idx)
+			}
+			wg.Wait()
+
+			assert.Equal(t, 1, dispatched[0]+dispatched[1])
+			assert.Equal(t, 1, outbox.deliverDue())
+			assert.Len(t, emailClient.sent, 1)
+
+			// The replica that claimed the slot can dispatch it again, but nothing is sent twice
+			for idx := range replicas {
+				_, err = replicas[idx].Dispatch(slot)
+				require.NoError(t, err)
+			}
+			assert.Zero(t, outbox.deliverDue())
+			assert.Len(t, emailClient.sent, 1)
+		})
+	}
+}
+
+func TestMissedSlots(t *testing.T) {
+	currentSlot := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		catchUpWindow time.Duration
+		lastSlot      *time.Time
+		expectedFirst time.Time
+		expectedLen   int
+	}{
+		{
+			name:          "replay disabled",
+			catchUpWindow: 0,
+		},
+		{
+			name:          "never dispatched",
+			catchUpWindow: 2 * time.Hour,
+			expectedFirst: currentSlot.Add(-2 * time.Hour),
+			expectedLen:   4,
+		},
+		{
+			name:          "window not aligned to slots",
+			catchUpWindow: 45 * time.Minute,
+			expectedFirst: currentSlot.Add(-30 * time.Minute),
+			expectedLen:   1,
+		},
+		{
+			name:          "last slot inside the window",
+			catchUpWindow: 2 * time.Hour,
+			lastSlot:      timePointer(currentSlot.Add(-time.Hour)),
+			expectedFirst: currentSlot.Add(-30 * time.Minute),
+			expectedLen:   1,
+		},
+		{
+			name:          "last slot before the window",
+			catchUpWindow: time.Hour,
+			lastSlot:      timePointer(currentSlot.Add(-24 * time.Hour)),
+			expectedFirst: currentSlot.Add(-time.Hour),
+			expectedLen:   2,
+		},
+		{
+			name:          "previous slot dispatched",
+			catchUpWindow: time.Hour,
+			lastSlot:      timePointer(currentSlot.Add(-30 * time.Minute)),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			store := db.NewFakeDB(nil)
+			notificationService := service.NewNotificationService(store, store, 0)
+			if testCase.lastSlot != nil {
+				require.NoError(t, notificationService.SaveLastDispatchedSlot(*testCase.lastSlot))
+			}
+
+			slots, err := NewDispatcher(notificationService, testCase.catchUpWindow).missedSlots(currentSlot)
+			require.NoError(t, err)
+			require.Len(t, slots, testCase.expectedLen)
+			for idx := range slots {
+				assert.Equal(t, testCase.expectedFirst.Add(time.Duration(idx)*30*time.Minute), slots[idx])
+			}
+		})
+	}
+}
+
+func TestCatchUpReplaysMissedOccurrencesByPolicy(t *testing.T) {
+	now := time.Date(2024, 7, 4, 12, 10, 0, 0, time.UTC)
+	lastSlot := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		policy   domain.CatchUpPolicy
+		expected int
+	}{
+		{policy: domain.CatchUpAll, expected: 3},
+		{policy: domain.CatchUpLatest, expected: 1},
+		{policy: "", expected: 1},
+		{policy: domain.CatchUpSkip, expected: 0},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.policy), func(t *testing.T) {
+			store := db.NewFakeDB(nil)
+			notificationService := service.NewNotificationService(store, store, 0)
+			request := domain.NotificationRequest{
+				Email:     "larrycapija@testmail.com",
+				Via:       domain.Mail,
+				Message:   "give the pills to Firulais",
+				StartDate: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+				Hours:     []string{"8:00"},
+				TimeZone:  "UTC",
+				CatchUp:   testCase.policy,
+			}
+			_, err := notificationService.ScheduleNotifications(request.ToNotification(), "")
+			require.NoError(t, err)
+			require.NoError(t, notificationService.SaveLastDispatchedSlot(lastSlot))
+
+			dispatched, err := NewDispatcher(notificationService, 7*24*time.Hour).CatchUp(now)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, dispatched)
+
+			emailClient := &emailClientMock{}
+			outbox := NewOutbox(notificationService, channel.NewRegistry(newTestMailChannel(t, emailClient)), testOutboxConfig)
+			assert.Equal(t, testCase.expected, outbox.deliverDue())
+			assert.Len(t, emailClient.sent, testCase.expected)
+
+			lastDispatched, err := notificationService.GetLastDispatchedSlot()
+			require.NoError(t, err)
+			assert.Equal(t, utils.SlotOf(now), *lastDispatched)
+		})
+	}
+}
+
+func TestCatchUpCompletesSkippedOneShots(t *testing.T) {
+	now := time.Date(2024, 7, 4, 12, 10, 0, 0, time.UTC)
+	fireAt := time.Date(2024, 7, 4, 9, 0, 0, 0, time.UTC)
+	store := db.NewFakeDB(nil)
+	notificationService := service.NewNotificationService(store, store, 0)
+	request := domain.NotificationRequest{
+		Email:     "larrycapija@testmail.com",
+		Via:       domain.Mail,
+		Message:   "give the pills to Firulais",
+		StartDate: fireAt,
+		FireAt:    &fireAt,
+		TimeZone:  "UTC",
+		CatchUp:   domain.CatchUpSkip,
+	}
+	_, err := notificationService.ScheduleNotifications(request.ToNotification(), "")
+	require.NoError(t, err)
+	require.NoError(t, notificationService.SaveLastDispatchedSlot(fireAt.Add(-time.Hour)))
+
+	dispatched, err := NewDispatcher(notificationService, 24*time.Hour).CatchUp(now)
+	require.NoError(t, err)
+	assert.Zero(t, dispatched)
+
+	notifications, err := notificationService.GetNotificationsByUserEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.NotNil(t, notifications[0].CompletedAt)
+}
+
+func timePointer(t time.Time) *time.Time {
+	return &t
+}
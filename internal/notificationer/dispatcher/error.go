@@ -0,0 +1,11 @@
+package dispatcher
+
+import "errors"
+
+var (
+	errSearchingNotifications      = errors.New("error searching all notifications for given hour")
+	errSearchingTimeZones          = errors.New("error searching time zones")
+	errSearchingLastDispatchedSlot = errors.New("error searching last dispatched slot")
+	errClaimingSlot                = errors.New("error claiming slot")
+	errEnqueuingDeliveries         = errors.New("error enqueuing deliveries")
+)
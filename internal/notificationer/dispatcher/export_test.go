@@ -0,0 +1,18 @@
+package dispatcher
+
+// deliverDue sends the deliveries due now one after another, until none is left. It returns the amount of
+// deliveries attempted
+func (o *Outbox) deliverDue() int {
+	attempted := 0
+	for {
+		claimed := o.claim()
+		for _, batch := range o.batches(claimed) {
+			o.deliverBatch(batch)
+		}
+
+		attempted += len(claimed)
+		if len(claimed) < claimBatchSize {
+			return attempted
+		}
+	}
+}
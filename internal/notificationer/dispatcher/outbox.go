@@ -0,0 +1,360 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"math/rand"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/notificationer/channel"
+	"sync"
+	"time"
+)
+
+const (
+	// claimBatchSize maximum amount of deliveries claimed at once
+	claimBatchSize = 50
+	// deliveryLease how long a claimed delivery is hidden from the other workers. It must be longer than the time
+	// the providers take to answer a request, otherwise a slow delivery might be sent twice
+	deliveryLease = 2 * time.Minute
+	// leaseMargin time left of the lease needed to start sending a batch. Batches that waited longer for a worker are
+	// left to be claimed again once their lease expires
+	leaseMargin = 30 * time.Second
+)
+
+type outboxServicer interface {
+	GetNotification(notificationID string) (domain.Notification, error)
+	MarkAsSent(notificationID string, slot time.Time) error
+	CompleteNotification(notificationID string, completedAt time.Time) error
+	ClaimDeliveries(now time.Time, leaseUntil time.Time, limit int) ([]domain.Delivery, error)
+	UpdateDelivery(delivery domain.Delivery) (bool, error)
+}
+
+type channelRegistry interface {
+	Get(name domain.Via) (channel.Channel, error)
+}
+
+// notFoundError errors of the service for notifications that do not exist
+type notFoundError interface {
+	NotFound() bool
+}
+
+// permanentError errors of the providers that fail again if retried, for example the ones of invalid recipients
+type permanentError interface {
+	Permanent() bool
+}
+
+// OutboxConfig configuration of the Outbox:
+// + Workers: amount of deliveries sent at the same time
+//
+// + MaxAttempts: attempts of a delivery before it's marked as failed
+//
+// + BaseBackoff: wait after the first failed attempt. It's doubled on each attempt, up to MaxBackoff
+//
+// + PollInterval: how often the outbox looks for due deliveries when it's empty
+//
+// + BatchSize: maximum amount of deliveries sent together through a channel.BatchChannel, zero means no limit. It
+// should be the amount the channel sends on each request, so every batch is sent before its lease expires
+type OutboxConfig struct {
+	Workers      int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// Outbox sends the deliveries enqueued by the Dispatcher. Deliveries are claimed before they are sent, so replicas
+// sharing the store do not send the same one at the same time. The claimed deliveries of a channel.BatchChannel are
+// sent together. Failed attempts are retried with an exponential backoff with jitter. A delivery only counts as done
+// once the provider accepts it, then its occurrence is marked as sent
+type Outbox struct {
+	service  outboxServicer
+	channels channelRegistry
+	config   OutboxConfig
+	now      func() time.Time
+}
+
+func NewOutbox(service outboxServicer, channels channelRegistry, config OutboxConfig) *Outbox {
+	return &Outbox{
+		service:  service,
+		channels: channels,
+		config:   config,
+		now:      time.Now,
+	}
+}
+
+// Run blocks until the given context is done. The due deliveries are claimed every poll interval, or right away
+// while full batches are claimed, and sent by the workers. Deliveries claimed but not sent when it stops are claimed
+// again once their lease expires
+func (o *Outbox) Run(ctx context.Context) {
+	logrus.Infof("Outbox started with %d workers", o.config.Workers)
+	batches := make(chan []domain.Delivery)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < o.config.Workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				o.deliverBatch(batch)
+			}
+		}()
+	}
+
+	defer func() {
+		close(batches)
+		wg.Wait()
+		logrus.Info("Outbox stopped")
+	}()
+
+	ticker := time.NewTicker(o.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		claimed := o.claim()
+		for _, batch := range o.batches(claimed) {
+			select {
+			case <-ctx.Done():
+				return
+			case batches <- batch:
+			}
+		}
+
+		if len(claimed) == claimBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (o *Outbox) claim() []domain.Delivery {
+	now := o.now()
+	deliveries, err := o.service.ClaimDeliveries(now, now.Add(deliveryLease), claimBatchSize)
+	if err != nil {
+		logrus.Errorf("error claiming deliveries: %v", err)
+		return nil
+	}
+
+	return deliveries
+}
+
+// batches groups the deliveries of each channel.BatchChannel, so they are sent together, in batches of at most the
+// configured size. The rest of the deliveries are sent one by one
+func (o *Outbox) batches(deliveries []domain.Delivery) [][]domain.Delivery {
+	var batches [][]domain.Delivery
+	batchIndexes := make(map[domain.Via]int)
+	for _, delivery := range deliveries {
+		if o.batchChannel(delivery) != nil {
+			idx, found := batchIndexes[delivery.Channel]
+			if found && (o.config.BatchSize <= 0 || len(batches[idx]) < o.config.BatchSize) {
+				batches[idx] = append(batches[idx], delivery)
+				continue
+			}
+			batchIndexes[delivery.Channel] = len(batches)
+		}
+
+		batches = append(batches, []domain.Delivery{delivery})
+	}
+
+	return batches
+}
+
+// batchChannel returns the channel of the delivery if it's a channel.BatchChannel, otherwise nil
+func (o *Outbox) batchChannel(delivery domain.Delivery) channel.BatchChannel {
+	if delivery.Channel == "" {
+		return nil
+	}
+
+	deliveryChannel, err := o.channels.Get(delivery.Channel)
+	if err != nil {
+		return nil
+	}
+
+	batchChannel, _ := deliveryChannel.(channel.BatchChannel)
+	return batchChannel
+}
+
+// deliverBatch sends the given deliveries, all of them of the same channel.BatchChannel unless there is only one.
+// Only the deliveries that failed are retried. Deliveries whose lease is about to expire are not sent, another worker
+// might claim them while they are being sent
+func (o *Outbox) deliverBatch(deliveries []domain.Delivery) {
+	if o.now().Add(leaseMargin).After(deliveries[0].NextAttemptAt) {
+		logrus.Warnf("lease of %d deliveries about to expire before sending them, they are claimed again once it "+
+			"expires", len(deliveries))
+		return
+	}
+
+	if len(deliveries) == 1 {
+		o.deliver(deliveries[0])
+		return
+	}
+
+	var sendable []domain.Delivery
+	notifications := make(map[string]domain.Notification, len(deliveries))
+	for _, delivery := range deliveries {
+		notification, ok := o.notificationOf(delivery)
+		if ok {
+			sendable = append(sendable, delivery)
+			notifications[delivery.ID] = notification
+		}
+	}
+
+	if len(sendable) == 0 {
+		return
+	}
+
+	errs := o.batchChannel(sendable[0]).SendBatch(notifications)
+	for _, delivery := range sendable {
+		o.result(delivery, notifications[delivery.ID], errs[delivery.ID])
+	}
+}
+
+// deliver sends the claimed delivery. Deliveries without channel that failed after being sent through some of the
+// channels of the notification are retried only through the channel that failed
+func (o *Outbox) deliver(delivery domain.Delivery) {
+	notification, ok := o.notificationOf(delivery)
+	if !ok {
+		return
+	}
+
+	channels, err := o.channelsOf(delivery, notification)
+	if err != nil {
+		logrus.Errorf("error sending delivery %s: %v", delivery.ID, err)
+		o.finish(delivery, domain.DeliveryFailed, err.Error())
+		return
+	}
+
+	for idx, deliveryChannel := range channels {
+		err = deliveryChannel.Send(notification)
+		if err != nil {
+			if idx == len(channels)-1 {
+				delivery.Channel = deliveryChannel.Name()
+			}
+			break
+		}
+	}
+
+	o.result(delivery, notification, err)
+}
+
+// notificationOf returns the notification of the delivery and true if it has to be sent. Deliveries of notifications
+// deleted or paused in the meantime are cancelled, and the ones whose notification cannot be fetched are retried
+func (o *Outbox) notificationOf(delivery domain.Delivery) (domain.Notification, bool) {
+	notification, err := o.service.GetNotification(delivery.NotificationID)
+	var notFound notFoundError
+	switch {
+	case errors.As(err, &notFound) && notFound.NotFound():
+		o.finish(delivery, domain.DeliveryCancelled, "notification not found")
+		return domain.Notification{}, false
+	case err != nil:
+		o.retry(delivery, err)
+		return domain.Notification{}, false
+	case notification.Paused || notification.DeletedAt != nil:
+		o.finish(delivery, domain.DeliveryCancelled, "notification paused or deleted")
+		return domain.Notification{}, false
+	}
+
+	return notification, true
+}
+
+// result saves the result of sending the delivery: if there is no error it's sent, otherwise it's retried
+func (o *Outbox) result(delivery domain.Delivery, notification domain.Notification, err error) {
+	if err != nil {
+		o.retry(delivery, err)
+		return
+	}
+
+	o.finish(delivery, domain.DeliverySent, "")
+	o.markAsSent(notification, delivery.Slot)
+}
+
+// channelsOf returns the channel of the delivery. Deliveries without channel are sent through every channel of the
+// notification
+func (o *Outbox) channelsOf(delivery domain.Delivery, notification domain.Notification) ([]channel.Channel, error) {
+	names := []domain.Via{delivery.Channel}
+	if delivery.Channel == "" {
+		names = notification.Via.Channels()
+	}
+
+	channels := make([]channel.Channel, 0, len(names))
+	for _, name := range names {
+		deliveryChannel, err := o.channels.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, deliveryChannel)
+	}
+
+	return channels, nil
+}
+
+// retry leaves the delivery pending until its backoff is over. Permanent errors, and deliveries that reached the
+// maximum attempts, are not retried: the delivery is marked as failed
+func (o *Outbox) retry(delivery domain.Delivery, err error) {
+	var permanent permanentError
+	if (errors.As(err, &permanent) && permanent.Permanent()) || delivery.Attempts >= o.config.MaxAttempts {
+		logrus.Errorf("error sending delivery %s, giving up after %d attempts: %v", delivery.ID, delivery.Attempts, err)
+		o.finish(delivery, domain.DeliveryFailed, err.Error())
+		return
+	}
+
+	nextAttemptAt := o.now().Add(o.backoff(delivery.Attempts))
+	logrus.Warnf("error sending delivery %s, attempt %d, retrying at %s: %v", delivery.ID, delivery.Attempts,
+		nextAttemptAt.Format(time.RFC3339), err)
+	delivery.Retry(err.Error(), nextAttemptAt)
+	o.update(delivery)
+}
+
+func (o *Outbox) finish(delivery domain.Delivery, status domain.DeliveryStatus, lastError string) {
+	delivery.Finish(status, lastError, o.now())
+	o.update(delivery)
+}
+
+func (o *Outbox) update(delivery domain.Delivery) {
+	updated, err := o.service.UpdateDelivery(delivery)
+	if err != nil {
+		logrus.Errorf("error updating delivery %s: %v", delivery.ID, err)
+		return
+	}
+
+	if !updated {
+		logrus.Warnf("delivery %s was claimed again before its attempt finished, the result is discarded", delivery.ID)
+	}
+}
+
+// markAsSent records the sent occurrence, unless a later one was already recorded. One-shot notifications are also
+// completed, they are never sent again
+func (o *Outbox) markAsSent(notification domain.Notification, slot time.Time) {
+	if notification.LastSent == nil || notification.LastSent.Before(slot) {
+		err := o.service.MarkAsSent(notification.ID, slot)
+		if err != nil {
+			logrus.Errorf("error marking notification %s as sent: %v", notification.ID, err)
+		}
+	}
+
+	if notification.IsOneShot() {
+		complete(o.service, notification)
+	}
+}
+
+// backoff returns how long to wait after the given failed attempt: the base backoff doubled on each attempt, up to
+// the maximum one. Half of the wait is random, so deliveries that failed together are not retried together
+func (o *Outbox) backoff(attempt int) time.Duration {
+	wait := o.config.BaseBackoff
+	for i := 1; i < attempt && wait < o.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+
+	if wait > o.config.MaxBackoff {
+		wait = o.config.MaxBackoff
+	}
+
+	half := wait / 2
+	return half + time.Duration(rand.Int63n(int64(wait-half)+1))
+}
@@ -0,0 +1,251 @@
+package dispatcher
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/notificationer/channel"
+	"notification-scheduler/internal/notificationer/db"
+	"notification-scheduler/internal/notificationer/service"
+	"testing"
+	"time"
+)
+
+// permanentTestError error of a recipient that does not exist
+type permanentTestError struct{}
+
+func (permanentTestError) Error() string   { return "invalid recipient" }
+func (permanentTestError) Permanent() bool { return true }
+
+// telegramerMock records the requests and the notifications sent. The first failures notifications fail
+type telegramerMock struct {
+	requests int
+	sent     []domain.Notification
+	failures int
+}
+
+func (tm *telegramerMock) SendNotifications(notifications map[string]domain.Notification) map[string]error {
+	tm.requests++
+	errs := make(map[string]error, len(notifications))
+	for deliveryID := range notifications {
+		if tm.failures > 0 {
+			tm.failures--
+			errs[deliveryID] = errors.New("too many requests")
+			continue
+		}
+		errs[deliveryID] = nil
+		tm.sent = append(tm.sent, notifications[deliveryID])
+	}
+
+	return errs
+}
+
+// newTestOutbox schedules one notification per given via and enqueues their deliveries. The clock of the outbox
+// starts after the deliveries were enqueued, so they are due
+func newTestOutbox(
+	t *testing.T, emailClient *emailClientMock, telegramer *telegramerMock, vias ...domain.Via,
+) (*Outbox, *service.NotificationService, *time.Time) {
+	slot := time.Date(2024, 7, 1, 8, 30, 0, 0, time.UTC)
+	store := db.NewFakeDB(nil)
+	notificationService := service.NewNotificationService(store, store, 0)
+	for idx, via := range vias {
+		request := domain.NotificationRequest{
+			Email:      "larrycapija@testmail.com",
+			TelegramID: fmt.Sprintf("larrycapija-%d", idx),
+			Via:        via,
+			Message:    "give the pills to Firulais",
+			StartDate:  slot.AddDate(0, 0, -1),
+			Hours:      []string{"8:30"},
+			TimeZone:   "UTC",
+		}
+		_, err := notificationService.ScheduleNotifications(request.ToNotification(), "")
+		require.NoError(t, err)
+	}
+
+	dispatched, err := NewDispatcher(notificationService, 0).Dispatch(slot)
+	require.NoError(t, err)
+	require.Equal(t, len(vias), dispatched)
+
+	clock := time.Now()
+	channels := channel.NewRegistry(newTestMailChannel(t, emailClient), channel.NewTelegramChannel(telegramer))
+	outbox := NewOutbox(notificationService, channels, testOutboxConfig)
+	outbox.now = func() time.Time { return clock }
+	return outbox, notificationService, &clock
+}
+
+func TestOutboxRetriesWithBackoff(t *testing.T) {
+	emailClient := &emailClientMock{failures: 1, err: errors.New("provider unavailable")}
+	outbox, notificationService, clock := newTestOutbox(t, emailClient, nil, domain.Mail)
+
+	assert.Equal(t, 1, outbox.deliverDue())
+	assert.Empty(t, emailClient.sent)
+
+	// The retry waits at least half of the base backoff
+	*clock = clock.Add(testOutboxConfig.BaseBackoff / 4)
+	assert.Zero(t, outbox.deliverDue())
+
+	*clock = clock.Add(testOutboxConfig.BaseBackoff)
+	assert.Equal(t, 1, outbox.deliverDue())
+	require.Len(t, emailClient.sent, 1)
+	assert.Equal(t, "Reminder: give the pills to Firulais", emailClient.sent[0].Subject)
+	assert.Contains(t, emailClient.sent[0].HTML, "give the pills to Firulais")
+
+	notifications, err := notificationService.GetNotificationsByUserEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.NotNil(t, notifications[0].LastSent)
+
+	// Sent deliveries are never claimed again
+	*clock = clock.Add(24 * time.Hour)
+	assert.Zero(t, outbox.deliverDue())
+}
+
+func TestOutboxSendsThroughEveryChannel(t *testing.T) {
+	emailClient := &emailClientMock{}
+	telegramer := &telegramerMock{failures: 1}
+	outbox, _, clock := newTestOutbox(t, emailClient, telegramer, domain.Both)
+
+	// Each channel has its own delivery, so only the failed one is retried
+	assert.Equal(t, 2, outbox.deliverDue())
+	assert.Len(t, emailClient.sent, 1)
+	assert.Empty(t, telegramer.sent)
+
+	*clock = clock.Add(testOutboxConfig.MaxBackoff)
+	assert.Equal(t, 1, outbox.deliverDue())
+	assert.Len(t, emailClient.sent, 1)
+	assert.Len(t, telegramer.sent, 1)
+}
+
+func TestOutboxRetriesOnlyFailedNotificationsOfBatches(t *testing.T) {
+	telegramer := &telegramerMock{failures: 1}
+	outbox, _, clock := newTestOutbox(t, nil, telegramer, domain.Telegram, domain.Telegram, domain.Telegram)
+
+	assert.Equal(t, 3, outbox.deliverDue())
+	assert.Equal(t, 1, telegramer.requests)
+	assert.Len(t, telegramer.sent, 2)
+
+	*clock = clock.Add(testOutboxConfig.MaxBackoff)
+	assert.Equal(t, 1, outbox.deliverDue())
+	assert.Equal(t, 2, telegramer.requests)
+	assert.Len(t, telegramer.sent, 3)
+}
+
+func TestOutboxSplitsBatches(t *testing.T) {
+	telegramer := &telegramerMock{}
+	outbox, _, _ := newTestOutbox(t, nil, telegramer, domain.Telegram, domain.Telegram, domain.Telegram)
+	outbox.config.BatchSize = 2
+
+	assert.Equal(t, 3, outbox.deliverDue())
+	assert.Equal(t, 2, telegramer.requests)
+	assert.Len(t, telegramer.sent, 3)
+}
+
+func TestOutboxSkipsBatchesWithExpiringLease(t *testing.T) {
+	telegramer := &telegramerMock{}
+	outbox, _, clock := newTestOutbox(t, nil, telegramer, domain.Telegram, domain.Telegram)
+
+	// The batch waited for a worker until its lease was about to expire
+	claimed := outbox.claim()
+	require.Len(t, claimed, 2)
+	*clock = clock.Add(deliveryLease - leaseMargin/2)
+	outbox.deliverBatch(claimed)
+	assert.Zero(t, telegramer.requests)
+
+	*clock = clock.Add(leaseMargin)
+	assert.Equal(t, 2, outbox.deliverDue())
+	assert.Len(t, telegramer.sent, 2)
+}
+
+func TestOutboxRetriesDeliveriesWithoutChannelThroughFailedChannel(t *testing.T) {
+	emailClient := &emailClientMock{}
+	telegramer := &telegramerMock{failures: 1}
+	outbox, notificationService, clock := newTestOutbox(t, emailClient, telegramer)
+
+	// Deliveries enqueued before each channel had its own one are sent through every channel of the notification
+	request := domain.NotificationRequest{
+		Email:      "larrycapija@testmail.com",
+		TelegramID: "larrycapija",
+		Via:        domain.Both,
+		Message:    "give the pills to Firulais",
+		StartDate:  clock.AddDate(0, 0, -1),
+		Hours:      []string{"8:30"},
+		TimeZone:   "UTC",
+	}
+	notifications, err := notificationService.ScheduleNotifications(request.ToNotification(), "")
+	require.NoError(t, err)
+	slot := time.Date(2024, 7, 1, 8, 30, 0, 0, time.UTC)
+	delivery := domain.NewDelivery(notifications[0].ID, "", slot, clock.Add(-time.Minute))
+	_, err = notificationService.EnqueueDeliveries([]domain.Delivery{delivery})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, outbox.deliverDue())
+	assert.Len(t, emailClient.sent, 1)
+	assert.Empty(t, telegramer.sent)
+
+	// The mail was already sent, so only the telegram is retried
+	*clock = clock.Add(testOutboxConfig.MaxBackoff)
+	assert.Equal(t, 1, outbox.deliverDue())
+	assert.Len(t, emailClient.sent, 1)
+	assert.Len(t, telegramer.sent, 1)
+}
+
+func TestOutboxGivesUp(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		attempts int
+	}{
+		{
+			name:     "after the maximum attempts",
+			err:      errors.New("provider unavailable"),
+			attempts: testOutboxConfig.MaxAttempts,
+		},
+		{name: "on permanent errors", err: permanentTestError{}, attempts: 1},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			emailClient := &emailClientMock{failures: 100, err: testCase.err}
+			outbox, notificationService, clock := newTestOutbox(t, emailClient, nil, domain.Mail)
+
+			attempts := 0
+			for idx := 0; idx < 2*testOutboxConfig.MaxAttempts; idx++ {
+				attempts += outbox.deliverDue()
+				*clock = clock.Add(testOutboxConfig.MaxBackoff)
+			}
+
+			assert.Equal(t, testCase.attempts, attempts)
+			assert.Empty(t, emailClient.sent)
+
+			notifications, err := notificationService.GetNotificationsByUserEmail("larrycapija@testmail.com")
+			require.NoError(t, err)
+			require.Len(t, notifications, 1)
+			assert.Nil(t, notifications[0].LastSent)
+		})
+	}
+}
+
+func TestOutboxCancelsDeliveriesOfPausedNotifications(t *testing.T) {
+	emailClient := &emailClientMock{}
+	outbox, notificationService, _ := newTestOutbox(t, emailClient, nil, domain.Mail)
+
+	notifications, err := notificationService.GetNotificationsByUserEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	notifications[0].Paused = true
+	require.NoError(t, notificationService.UpdateNotification(notifications[0], ""))
+
+	assert.Equal(t, 1, outbox.deliverDue())
+	assert.Empty(t, emailClient.sent)
+}
+
+func TestBackoff(t *testing.T) {
+	outbox := NewOutbox(nil, nil, testOutboxConfig)
+	for attempt, maxWait := range map[int]time.Duration{1: time.Minute, 3: 4 * time.Minute, 20: time.Hour} {
+		wait := outbox.backoff(attempt)
+		assert.GreaterOrEqual(t, wait, maxWait/2)
+		assert.LessOrEqual(t, wait, maxWait)
+	}
+}
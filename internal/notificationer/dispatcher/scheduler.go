@@ -0,0 +1,59 @@
+package dispatcher
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"notification-scheduler/internal/utils"
+	"time"
+)
+
+type slotDispatcher interface {
+	CatchUp(now time.Time) (int, error)
+}
+
+// Scheduler wakes up on every slot boundary (on the hour and at thirty) and dispatches the notifications
+// of that slot. On start, the slots missed while the service was down are caught up
+type Scheduler struct {
+	dispatcher slotDispatcher
+	now        func() time.Time
+}
+
+func NewScheduler(dispatcher slotDispatcher) *Scheduler {
+	return &Scheduler{
+		dispatcher: dispatcher,
+		now:        time.Now,
+	}
+}
+
+// Run blocks until the given context is done. Each time a slot begins, the notifications of that slot are dispatched
+func (s *Scheduler) Run(ctx context.Context) {
+	logrus.Info("Scheduler started")
+	s.dispatch(s.now())
+
+	for {
+		nextSlot := utils.SlotOf(s.now()).Add(utils.SlotDuration)
+		timer := time.NewTimer(nextSlot.Sub(s.now()))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logrus.Info("Scheduler stopped")
+			return
+		case <-timer.C:
+		}
+
+		s.dispatch(nextSlot)
+	}
+}
+
+// dispatch sends the notifications of the slot of the given instant, catching up any slot missed before it
+func (s *Scheduler) dispatch(now time.Time) {
+	slot := utils.SlotOf(now)
+	dispatched, err := s.dispatcher.CatchUp(now)
+	if err != nil {
+		logrus.Errorf("error dispatching slot %s: %v", slot.Format(time.RFC3339), err)
+		return
+	}
+
+	logrus.Infof("Slot %s dispatched: %d notifications", slot.Format(time.RFC3339), dispatched)
+}
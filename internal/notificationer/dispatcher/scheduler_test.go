@@ -0,0 +1,51 @@
+package dispatcher
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// slotDispatcherMock sends the instant of every catch up to calls
+type slotDispatcherMock struct {
+	calls chan time.Time
+}
+
+func (sdm *slotDispatcherMock) CatchUp(now time.Time) (int, error) {
+	sdm.calls <- now
+	return 0, nil
+}
+
+func TestSchedulerDispatchesOnStartAndOnEverySlotBoundary(t *testing.T) {
+	boundary := time.Date(2024, 7, 1, 8, 30, 0, 0, time.UTC)
+	start := boundary.Add(-20 * time.Millisecond)
+	begin := time.Now()
+
+	dispatcher := &slotDispatcherMock{calls: make(chan time.Time, 2)}
+	scheduler := NewScheduler(dispatcher)
+	scheduler.now = func() time.Time { return start.Add(time.Since(begin)) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(stopped)
+	}()
+
+	var calls []time.Time
+	for len(calls) < 2 {
+		select {
+		case call := <-dispatcher.calls:
+			calls = append(calls, call)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "the scheduler did not dispatch the slot boundary")
+		}
+	}
+	cancel()
+	<-stopped
+
+	assert.True(t, calls[0].Before(boundary))
+	assert.Equal(t, boundary, calls[1])
+}
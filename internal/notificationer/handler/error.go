@@ -16,12 +16,15 @@ type serviceError interface {
 	NotFound() bool
 	AlreadyExists() bool
 	InternalError() bool
+	VersionConflict() bool
 }
 
 var (
 	errGettingAppContext             = errors.New("error getting app")
 	errInvalidNotificationBody       = errors.New("error invalid notification request body")
 	errInvalidUpdateRequest          = errors.New("error invalid update request body")
+	errInvalidSearchRequest          = errors.New("error invalid search query")
+	errSearchRequestValidation       = errors.New("error search request validation")
 	errInvalidMail                   = errors.New("error invalid mail structure")
 	errSendingEmail                  = errors.New("error sending email")
 	errNotificationRequestValidation = errors.New("error notification request validation")
@@ -33,6 +36,23 @@ var (
 	errUpdatingNotification          = errors.New("error updating")
 	errMissingNotificationID         = errors.New("error missing notificationID")
 	errDeletingNotification          = errors.New("error deleting notification")
+	errTriggeringNotifications       = errors.New("error triggering notifications")
+	errMissingScheduleID             = errors.New("error missing scheduleID")
+	errFetchingSchedule              = errors.New("error fetching schedule")
+	errUpdatingSchedule              = errors.New("error updating schedule")
+	errDeletingSchedule              = errors.New("error deleting schedule")
+	errPreconditionFailed            = errors.New("error the current version does not match the If-Match header")
+	errRestoringNotification         = errors.New("error restoring notification")
+	errFetchingHistory               = errors.New("error fetching notification history")
+	errInvalidExportFormat           = errors.New("error invalid export format")
+	errExportingNotifications        = errors.New("error exporting notifications")
+	errInvalidImportFormat           = errors.New("error invalid import format")
+	errInvalidImportFile             = errors.New("error invalid import file")
+	errCreatingWebhookSecret         = errors.New("error creating webhook secret")
+	errDeletingWebhookSecret         = errors.New("error deleting webhook secret")
+	errTemplateNotFound              = errors.New("error template not found")
+	errInvalidPreviewFormat          = errors.New("error invalid preview format")
+	errRenderingTemplate             = errors.New("error rendering template")
 )
 
 var statusCodeByErr = map[error]int{
@@ -41,12 +61,27 @@ var statusCodeByErr = map[error]int{
 	errFetchingUserNotifications:     http.StatusInternalServerError,
 	errDeletingNotification:          http.StatusInternalServerError,
 	errSendingEmail:                  http.StatusInternalServerError,
+	errTriggeringNotifications:       http.StatusInternalServerError,
+	errDeletingSchedule:              http.StatusInternalServerError,
+	errExportingNotifications:        http.StatusInternalServerError,
+	errCreatingWebhookSecret:         http.StatusInternalServerError,
+	errDeletingWebhookSecret:         http.StatusInternalServerError,
+	errRenderingTemplate:             http.StatusInternalServerError,
+	errTemplateNotFound:              http.StatusNotFound,
+	errInvalidPreviewFormat:          http.StatusBadRequest,
 	errInvalidNotificationBody:       http.StatusBadRequest,
 	errNotificationRequestValidation: http.StatusBadRequest,
 	errMissingNotificationID:         http.StatusBadRequest,
+	errMissingScheduleID:             http.StatusBadRequest,
 	errInvalidMail:                   http.StatusBadRequest,
 	errUpdateRequestValidation:       http.StatusBadRequest,
+	errInvalidSearchRequest:          http.StatusBadRequest,
+	errSearchRequestValidation:       http.StatusBadRequest,
+	errInvalidExportFormat:           http.StatusBadRequest,
+	errInvalidImportFormat:           http.StatusBadRequest,
+	errInvalidImportFile:             http.StatusBadRequest,
 	errUserNotAllowed:                http.StatusUnauthorized,
+	errPreconditionFailed:            http.StatusPreconditionFailed,
 }
 
 func NewErrorResponse(err error) ErrorResponse {
@@ -59,6 +94,13 @@ func NewErrorResponse(err error) ErrorResponse {
 		}
 	}
 
+	if isServiceError && serviceErrorData.VersionConflict() {
+		return ErrorResponse{
+			StatusCode: http.StatusConflict,
+			Message:    serviceErrorData.Error(),
+		}
+	}
+
 	if isServiceError && serviceErrorData.InternalError() {
 		return ErrorResponse{
 			StatusCode: http.StatusInternalServerError,
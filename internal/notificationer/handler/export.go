@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/internal/context"
+	"notification-scheduler/internal/notificationer/handler/internal/exporter"
+	"notification-scheduler/internal/notificationer/handler/internal/validator"
+	"strings"
+	"time"
+)
+
+// maxImportSize maximum size in bytes of an imported file
+const maxImportSize = 1 << 20
+
+// ExportNotifications godoc
+//
+//	@Summary		Exports the notifications of the user
+//	@Description	Returns a file with all the notifications of the user. JSON and CSV files have one row per schedule, with the fields of the notification requests, so they can be imported again. ICS files can be opened by calendar apps: each notification is a recurring event with an alarm. Completed notifications are left out
+//
+//	@Tags			Notification
+//	@Produce		json
+//	@Produce		text/csv
+//	@Produce		text/calendar
+//	@Param			Authorization	header		string	true	"jwt data, must contain the email of the user"
+//	@Param			format			query		string	false	"format of the file: json, csv or ics. Default: json"
+//	@Success		200				{file}		file
+//	@Failure		400,401,403		{object}	ErrorResponse
+//	@Router			/notifications/export [get]
+func (nh *NotificationHandler) ExportNotifications(c *gin.Context) {
+	appContext, err := context.GetAppContext(c.Request.Context())
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errGettingAppContext, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	if appContext.TelegramRequest {
+		errResponse := NewErrorResponse(fmt.Errorf("requests from Telegram are not allowed"))
+		c.JSON(http.StatusForbidden, errResponse)
+		return
+	}
+
+	format := exporter.Format(strings.ToLower(c.DefaultQuery("format", string(exporter.JSON))))
+	if !exporter.ValidFormat(format) {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %s", errInvalidExportFormat, format))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	notifications, err := nh.service.GetNotificationsByUserEmail(appContext.Email)
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errFetchingUserNotifications, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	var file bytes.Buffer
+	err = exporter.Write(&file, format, notifications, time.Now())
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errExportingNotifications, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="notifications.%s"`, format))
+	c.Data(http.StatusOK, exporter.ContentType(format)+"; charset=utf-8", file.Bytes())
+}
+
+// ImportNotifications godoc
+//
+//	@Summary		Imports notifications of the user
+//	@Description	Schedules the notifications of a JSON or CSV file, like the ones exported. Each row is validated and scheduled on its own, like a domain.NotificationRequest: the rows with errors are reported, the ones whose notifications already exist are skipped and the rest are imported. At most 500 rows can be imported at once
+//
+//	@Tags			Notification
+//	@Accept			json
+//	@Accept			text/csv
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"jwt data, must contain the email of the user"
+//	@Param			format			query		string	false	"format of the file: json or csv. Default: csv if the content type is text/csv, otherwise json"
+//	@Param			file			body		string	true	"file with the notifications"
+//	@Success		200				{object}	domain.ImportResponse
+//	@Failure		400,401,403		{object}	ErrorResponse
+//	@Router			/notifications/import [post]
+func (nh *NotificationHandler) ImportNotifications(c *gin.Context) {
+	appContext, err := context.GetAppContext(c.Request.Context())
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errGettingAppContext, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	if appContext.TelegramRequest {
+		errResponse := NewErrorResponse(fmt.Errorf("requests from Telegram are not allowed"))
+		c.JSON(http.StatusForbidden, errResponse)
+		return
+	}
+
+	format := exporter.Format(strings.ToLower(c.Query("format")))
+	if format == "" {
+		format = exporter.JSON
+		if c.ContentType() == exporter.ContentType(exporter.CSV) {
+			format = exporter.CSV
+		}
+	}
+
+	if !exporter.Importable(format) {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %s", errInvalidImportFormat, format))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	rows, err := exporter.Read(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize), format)
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errInvalidImportFile, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	response := domain.ImportResponse{
+		Rows: make([]domain.ImportRowResult, 0, len(rows)),
+	}
+	for _, row := range rows {
+		result := nh.importRow(row, appContext)
+		if result.Error != "" {
+			response.Failed++
+		} else if result.Skipped {
+			response.Skipped++
+		} else {
+			response.Imported++
+		}
+		response.Rows = append(response.Rows, result)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// importRow validates and schedules the notification of the row, like ScheduleNotification does. Rows whose
+// notifications already exist are skipped
+func (nh *NotificationHandler) importRow(row exporter.Row, appContext context.AppContext) domain.ImportRowResult {
+	result := domain.ImportRowResult{Row: row.Number}
+	if row.Err != nil {
+		result.Error = row.Err.Error()
+		return result
+	}
+
+	notificationRequest := row.Request
+	notificationRequest.TelegramID = appContext.TelegramID
+	notificationRequest.Email = appContext.Email
+
+	err := validator.ValidateNotificationRequest(notificationRequest)
+	if err != nil {
+		result.Error = fmt.Errorf("%w: %v", errNotificationRequestValidation, err).Error()
+		return result
+	}
+
+	notification := notificationRequest.ToNotification()
+	err = nh.channels.ValidateRecipients(notification)
+	if err != nil {
+		result.Error = fmt.Errorf("%w: %v", errNotificationRequestValidation, err).Error()
+		return result
+	}
+
+	createdNotifications, err := nh.service.ScheduleNotifications(notification, actorOf(appContext))
+	var serviceErrorContext serviceError
+	if errors.As(err, &serviceErrorContext) && serviceErrorContext.AlreadyExists() {
+		result.Skipped = true
+		return result
+	}
+
+	if err != nil {
+		result.Error = fmt.Errorf("%w: %v", errSchedulingNotification, err).Error()
+		return result
+	}
+
+	if len(createdNotifications) > 0 {
+		result.ScheduleID = createdNotifications[0].ScheduleID
+	}
+
+	return result
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/notificationer/service"
+	"testing"
+)
+
+// existingService reports that the notifications with the given message already exist
+type existingService struct {
+	*service.NotificationService
+	existingMessage string
+}
+
+func (es existingService) ScheduleNotifications(
+	notification domain.Notification, actor string,
+) ([]domain.Notification, error) {
+	if notification.Message == es.existingMessage {
+		return nil, alreadyExistsError{}
+	}
+
+	return es.NotificationService.ScheduleNotifications(notification, actor)
+}
+
+type alreadyExistsError struct{}
+
+func (alreadyExistsError) Error() string         { return "notification already exists" }
+func (alreadyExistsError) NotFound() bool        { return false }
+func (alreadyExistsError) AlreadyExists() bool   { return true }
+func (alreadyExistsError) InternalError() bool   { return false }
+func (alreadyExistsError) VersionConflict() bool { return false }
+
+// anyRecipient accepts the recipients of every notification
+type anyRecipient struct{}
+
+func (anyRecipient) ValidateRecipients(domain.Notification) error {
+	return nil
+}
+
+func TestImportSkipsExistingNotifications(t *testing.T) {
+	t.Setenv("secret", "ay harringui")
+	t.Setenv("algorithm", "HS256")
+	gin.SetMode(gin.TestMode)
+	notificationService, _ := newTestService(t)
+	router := gin.New()
+	servicer := existingService{NotificationService: notificationService, existingMessage: "give the pills to Firulais"}
+	NewNotificationHandler(servicer, nil, nil, anyRecipient{}, nil).RegisterRoutes(router)
+
+	body := `[
+		{"via": "Mail", "message": "give the pills to Firulais", "start_date": "2024-07-01T00:00:00Z",
+			"hours": ["8:30"]},
+		{"via": "Mail", "message": "walk Firulais", "start_date": "2024-07-01T00:00:00Z", "hours": ["19:00"]},
+		{"via": "Mail", "message": "no", "start_date": "2024-07-01T00:00:00Z", "hours": ["19:00"]}
+	]`
+	response := doRequest(router, http.MethodPost, "/notifications/import", body, "")
+	require.Equal(t, http.StatusOK, response.Code)
+
+	var importResponse domain.ImportResponse
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &importResponse))
+	assert.Equal(t, 1, importResponse.Imported)
+	assert.Equal(t, 1, importResponse.Skipped)
+	assert.Equal(t, 1, importResponse.Failed)
+	require.Len(t, importResponse.Rows, 3)
+	assert.True(t, importResponse.Rows[0].Skipped)
+	assert.Empty(t, importResponse.Rows[0].Error)
+	assert.NotEmpty(t, importResponse.Rows[1].ScheduleID)
+	assert.NotEmpty(t, importResponse.Rows[2].Error)
+}
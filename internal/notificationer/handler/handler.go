@@ -4,50 +4,77 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
 	"net/http"
 	"notification-scheduler/internal/domain"
 	"notification-scheduler/internal/externalservices/email"
 	"notification-scheduler/internal/internal/context"
 	"notification-scheduler/internal/notificationer/handler/internal/validator"
+	"notification-scheduler/internal/notificationer/templates"
+	"strings"
 	"time"
 )
 
+// servicer the actor of the changes is who made the request, see actorOf
 type servicer interface {
-	ScheduleNotifications(notification domain.Notification) ([]domain.Notification, error)
+	ScheduleNotifications(notification domain.Notification, actor string) ([]domain.Notification, error)
 	GetNotificationsByUserEmail(email string) ([]domain.Notification, error)
+	SearchNotifications(query domain.NotificationQuery) (domain.NotificationPage, error)
 	GetNotification(notificationID string) (domain.Notification, error)
-	UpdateNotification(notification domain.Notification) error
-	DeleteNotification(notificationID string) error
-	GetAll(hour string) ([]domain.Notification, error)
+	GetRestorableNotification(notificationID string) (domain.Notification, error)
+	UpdateNotification(notification domain.Notification, actor string) error
+	DeleteNotification(notificationID string, version int64, actor string) error
+	RestoreNotification(notification domain.Notification, actor string) (domain.Notification, error)
+	GetNotificationHistory(notificationID string) ([]domain.AuditEntry, error)
+	GetSchedule(scheduleID string) ([]domain.Notification, error)
+	UpdateSchedule(notifications []domain.Notification, actor string) error
+	DeleteSchedule(notifications []domain.Notification, actor string) error
+	RotateWebhookSecret(email string) (domain.WebhookSecret, error)
+	DeleteWebhookSecret(email string) error
 }
 
 type emailService interface {
 	SendEmail(email email.Mail) error
 }
 
-type telegramService interface {
-	SendNotifications(notifications []domain.Notification) error
+type dispatcher interface {
+	CatchUp(now time.Time) (int, error)
+}
+
+// channelValidator checks that the channels of a notification exist and can reach the user
+type channelValidator interface {
+	ValidateRecipients(notification domain.Notification) error
+}
+
+// emailRenderer renders the email templates of the notifications
+type emailRenderer interface {
+	Names() []string
+	Render(name string, data templates.Data) (templates.Email, error)
 }
 
 type NotificationHandler struct {
 	service     servicer
 	emailClient emailService
-	telegramer  telegramService
+	dispatcher  dispatcher
+	channels    channelValidator
+	renderer    emailRenderer
 }
 
-func NewNotificationHandler(service servicer, emailClient emailService, telegramer telegramService) *NotificationHandler {
+func NewNotificationHandler(
+	service servicer, emailClient emailService, dispatcher dispatcher, channels channelValidator, renderer emailRenderer,
+) *NotificationHandler {
 	return &NotificationHandler{
 		service:     service,
 		emailClient: emailClient,
-		telegramer:  telegramer,
+		dispatcher:  dispatcher,
+		channels:    channels,
+		renderer:    renderer,
 	}
 }
 
 // ScheduleNotification godoc
 //
 //	@Summary		Schedules notifications
-//	@Description	Receives a domain.NotificationRequest, performs validations and if it's all OK then one notification per each specified hour is saved.
+//	@Description	Receives a domain.NotificationRequest, performs validations and if it's all OK then one notification per each specified hour is saved. All of them belong to the same schedule, that can be managed as a whole.
 //	@Tags			Notification
 //	@Accept			json
 //	@Produce		json
@@ -90,7 +117,14 @@ func (nh *NotificationHandler) ScheduleNotification(c *gin.Context) {
 	}
 
 	notification := notificationRequest.ToNotification()
-	createdNotifications, err := nh.service.ScheduleNotifications(notification)
+	err = nh.channels.ValidateRecipients(notification)
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errNotificationRequestValidation, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	createdNotifications, err := nh.service.ScheduleNotifications(notification, actorOf(appContext))
 	var serviceErrorContext serviceError
 	if errors.As(err, &serviceErrorContext) && serviceErrorContext.AlreadyExists() {
 		c.JSON(http.StatusOK, domain.NewNotificationResponse(notification))
@@ -114,14 +148,23 @@ func (nh *NotificationHandler) ScheduleNotification(c *gin.Context) {
 
 // GetNotifications godoc
 //
-//	@Summary		Search all notifications by user email
-//	@Description	Returns all the notifications of the given user
+//	@Summary		Search the notifications of the user
+//	@Description	Returns a page of the notifications of the given user that match the filters, sorted by hour and then by ID. To get the next page, send the next_cursor of the metadata as cursor
 //
 //	@Tags			Notification
 //	@Accept			json
 //	@Produce		json
 //	@Param			Authorization	header		string	true	"jwt data, must contain the email of the user"
-//	@Success		200				{object}	[]domain.NotificationResponse
+//	@Param			via				query		string	false	"only notifications sent by this service: telegram, mail, both or webhook"
+//	@Param			status			query		string	false	"only active or expired notifications"
+//	@Param			hour_from		query		string	false	"only notifications sent from this hour, HH:MM"
+//	@Param			hour_to			query		string	false	"only notifications sent until this hour, HH:MM"
+//	@Param			q				query		string	false	"only notifications whose message contains this text"
+//	@Param			from			query		string	false	"only notifications active from this date, RFC 3339"
+//	@Param			to				query		string	false	"only notifications active until this date, RFC 3339"
+//	@Param			cursor			query		string	false	"cursor returned by the previous page"
+//	@Param			limit			query		int		false	"notifications per page, from 1 to 100. Default: 20"
+//	@Success		200				{object}	domain.NotificationListResponse
 //	@Failure		400,401,404		{object}	ErrorResponse
 //	@Router			/notifications/notification [get]
 func (nh *NotificationHandler) GetNotifications(c *gin.Context) {
@@ -132,30 +175,36 @@ func (nh *NotificationHandler) GetNotifications(c *gin.Context) {
 		return
 	}
 
-	notifications, err := nh.service.GetNotificationsByUserEmail(appContext.Email)
+	var searchRequest domain.NotificationSearchRequest
+	err = c.ShouldBindQuery(&searchRequest)
 	if err != nil {
-		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errFetchingUserNotifications, err))
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errInvalidSearchRequest, err))
 		c.JSON(errResponse.StatusCode, errResponse)
 		return
 	}
 
-	if len(notifications) == 0 {
-		c.JSON(http.StatusNoContent, nil)
+	err = validator.ValidateSearchRequest(searchRequest)
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errSearchRequestValidation, err))
+		c.JSON(errResponse.StatusCode, errResponse)
 		return
 	}
 
-	var response []domain.NotificationResponse
-	for idx := range notifications {
-		response = append(response, domain.NewNotificationResponse(notifications[idx]))
+	query := searchRequest.ToQuery(appContext.Email, time.Now())
+	page, err := nh.service.SearchNotifications(query)
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errFetchingUserNotifications, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, domain.NewNotificationListResponse(page, query.Limit))
 }
 
 // GetNotificationData godoc
 //
 //	@Summary		Fetches notification by ID
-//	@Description	Fetches notification by ID
+//	@Description	Fetches notification by ID. The ETag header holds its version, to be sent in the If-Match header of updates and deletions
 //
 //	@Tags			Notification
 //	@Accept			json
@@ -163,6 +212,7 @@ func (nh *NotificationHandler) GetNotifications(c *gin.Context) {
 //	@Param			Authorization	header		string	true	"jwt data"
 //	@Param			notificationID	path		string	true	"id of the notification"
 //	@Success		200				{object}	domain.NotificationResponse
+//	@Header			200				{string}	ETag	"version of the notification"
 //	@Failure		400,401,404		{object}	ErrorResponse
 //	@Router			/notifications/notification/{notificationID} [get]
 func (nh *NotificationHandler) GetNotificationData(c *gin.Context) {
@@ -194,22 +244,25 @@ func (nh *NotificationHandler) GetNotificationData(c *gin.Context) {
 	}
 
 	response := domain.NewNotificationResponse(notification)
+	c.Header("ETag", notification.ETag())
 	c.JSON(http.StatusOK, response)
 }
 
 // UpdateNotification godoc
 //
 //	@Summary		Updates a notification
-//	@Description	Updates attributes of certain notification. The attributes that can be updated are: message and end date
+//	@Description	Updates attributes of certain notification. The attributes that can be updated are: message and end date. If the If-Match header is given, the notification is only updated if its ETag matches
 //
 //	@Tags			Notification
 //	@Accept			json
 //	@Produce		json
 //	@Param			Authorization	header		string								true	"jwt data"
+//	@Param			If-Match		header		string								false	"ETag returned when the notification was fetched"
 //	@Param			notificationID	path		string								true	"id of the notification"
 //	@Param			UpdateRequest	body		domain.UpdateNotificationRequest	true	"Fields to update"
 //	@Success		200				{object}	nil
 //	@Failure		400,401,404		{object}	ErrorResponse
+//	@Failure		409,412			{object}	ErrorResponse
 //	@Router			/notifications/notification/{notificationID} [patch]
 func (nh *NotificationHandler) UpdateNotification(c *gin.Context) {
 	appContext, err := context.GetAppContext(c.Request.Context())
@@ -261,10 +314,17 @@ func (nh *NotificationHandler) UpdateNotification(c *gin.Context) {
 		return
 	}
 
+	if !matchesETag(c, notification.ETag()) {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: current ETag %s", errPreconditionFailed, notification.ETag()))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	// The update only succeeds if the notification was not modified since it was read
 	updatedNotification := domain.Merge(notification, updateRequest)
-	err = nh.service.UpdateNotification(updatedNotification)
+	err = nh.service.UpdateNotification(updatedNotification, actorOf(appContext))
 	if err != nil {
-		errResponse := NewErrorResponse(fmt.Errorf("%w: %w", errUpdatingNotification, err))
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %w", errUpdatingNotification, preconditionError(c, err)))
 		c.JSON(errResponse.StatusCode, errResponse)
 		return
 	}
@@ -275,15 +335,17 @@ func (nh *NotificationHandler) UpdateNotification(c *gin.Context) {
 // DeleteNotification godoc
 //
 //	@Summary		Deletes a notification
-//	@Description	If exists, deletes the notification with the given notificationID. This action is triggered by the users, if a notification reaches the end date nothing happens
+//	@Description	If exists, deletes the notification with the given notificationID. This action is triggered by the users. Deleted notifications can be restored during the grace period. Notifications that reach their end date are completed by the sweeper and deleted after the retention period
 //
 //	@Tags			Notification
 //	@Accept			json
 //	@Produce		json
 //	@Param			Authorization	header		string	true	"jwt data"
+//	@Param			If-Match		header		string	false	"ETag returned when the notification was fetched"
 //	@Param			notificationID	path		string	true	"id of the notification"
 //	@Success		200				{object}	nil
 //	@Failure		400,401,404		{object}	ErrorResponse
+//	@Failure		409,412			{object}	ErrorResponse
 //	@Router			/notifications/notification/{notificationID} [delete]
 func (nh *NotificationHandler) DeleteNotification(c *gin.Context) {
 	appContext, err := context.GetAppContext(c.Request.Context())
@@ -314,9 +376,15 @@ func (nh *NotificationHandler) DeleteNotification(c *gin.Context) {
 		return
 	}
 
-	err = nh.service.DeleteNotification(notificationID)
+	if !matchesETag(c, notification.ETag()) {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: current ETag %s", errPreconditionFailed, notification.ETag()))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	err = nh.service.DeleteNotification(notificationID, notification.Version, actorOf(appContext))
 	if err != nil {
-		errResponse := NewErrorResponse(fmt.Errorf("%w: %w", errDeletingNotification, err))
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %w", errDeletingNotification, preconditionError(c, err)))
 		c.JSON(errResponse.StatusCode, errResponse)
 		return
 	}
@@ -358,54 +426,66 @@ func (nh *NotificationHandler) SendEmail(c *gin.Context) {
 // TriggerNotifications godoc
 //
 //	@Summary		sends notifications
-//	@Description	Sends notifications to all users that have scheduled one for the hour of this request
+//	@Description	Manual override of the scheduler: enqueues the notifications of all users that have scheduled one for the current slot, catching up the slots missed since the last dispatch. The outbox sends them, retrying the failed ones. The scheduler already does this on every slot, so calling this endpoint is optional. Each slot is dispatched once: if it was already dispatched, by the scheduler or by another instance, nothing is enqueued and 204 is returned
 //	@Tags			Notification
 //	@Accept			json
 //	@Produce		json
 //	@Param			Authorization	header		string	true	"jwt data"
-//	@Success		200				{object}	nil
-//	@Success		204				{object}	nil
+//	@Success		200				{object}	nil	"notifications enqueued"
+//	@Success		204				{object}	nil	"no notifications due, or slot already dispatched"
 //	@Failure		400,404			{object}	ErrorResponse
 //	@Router			/notifications/trigger [post]
 func (nh *NotificationHandler) TriggerNotifications(c *gin.Context) {
-	currentHour := time.Now().Hour()
-	notifications, err := nh.service.GetAll(fmt.Sprint(currentHour))
+	dispatched, err := nh.dispatcher.CatchUp(time.Now())
 	if err != nil {
-		a := fmt.Errorf("error searching all notifications for given hour %d: %v", currentHour, err)
-		errResponse := NewErrorResponse(a)
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errTriggeringNotifications, err))
 		c.JSON(errResponse.StatusCode, errResponse)
 		return
 	}
 
-	if len(notifications) == 0 {
+	if dispatched == 0 {
 		c.JSON(http.StatusNoContent, nil)
 		return
 	}
 
-	for idx := range notifications {
-		notification := notifications[idx]
-		if notification.Via == domain.Mail || notification.Via == domain.Both {
-			mail := email.Mail{
-				To:      notification.Email,
-				Subject: "Scheduled notification",
-				Body:    notification.Message,
-			}
-			err = nh.emailClient.SendEmail(mail)
-			if err != nil {
-				logrus.Errorf("error sending mail: %v", err)
-			}
-			continue
-		}
+	c.JSON(http.StatusOK, nil)
+}
+
+// actorOf returns who made the request, to be recorded in the audit trail: the ID of the user, or telegram for the
+// requests of the Telegram service
+func actorOf(appContext context.AppContext) string {
+	if appContext.TelegramRequest {
+		return "telegram"
+	}
+
+	return appContext.UserID
+}
+
+// matchesETag returns true if the request has no If-Match header, or if one of its ETags is the current one of the
+// resource. Weak ETags never match
+func matchesETag(c *gin.Context, currentETag string) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		return true
+	}
 
-		if notification.Via == domain.Telegram || notification.Via == domain.Both {
-			// ToDo: refactor. Licha
-			err = nh.telegramer.SendNotifications([]domain.Notification{notification})
-			if err != nil {
-				logrus.Errorf("error sending telegram: %v", err)
-			}
-			continue
+	for _, etag := range strings.Split(ifMatch, ",") {
+		etag = strings.TrimSpace(etag)
+		if etag == "*" || etag == currentETag {
+			return true
 		}
 	}
 
-	c.JSON(http.StatusOK, nil)
+	return false
+}
+
+// preconditionError returns a precondition failed error if the given error is a version conflict and the request has
+// an If-Match header. Without it, the conflict is returned as it is
+func preconditionError(c *gin.Context, err error) error {
+	var serviceErrorData serviceError
+	if c.GetHeader("If-Match") != "" && errors.As(err, &serviceErrorData) && serviceErrorData.VersionConflict() {
+		return fmt.Errorf("%w: %v", errPreconditionFailed, err)
+	}
+
+	return err
 }
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/internal/headers"
+	"notification-scheduler/internal/notificationer/db"
+	"notification-scheduler/internal/notificationer/service"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testToken JWT signed with HS256 and the secret 'ay harringui'. It contains the user_id 69-abc, the email
+// larrycapija@testmail.com and the telegram_id 123
+const testToken = "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.eyJ1c2VyX2lkIjoiNjktYWJjIiwiZW1haWwiOiJsYXJyeWNhcGlq" +
+	"YUB0ZXN0bWFpbC5jb20iLCJ0ZWxlZ3JhbV9pZCI6IjEyMyJ9.tddxCgzgHvCPHBHsakVod6fiN6C5Hf5t57OgpZHaKig"
+
+func newTestRouter(t *testing.T, servicer servicer) *gin.Engine {
+	t.Setenv("secret", "ay harringui")
+	t.Setenv("algorithm", "HS256")
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	NewNotificationHandler(servicer, nil, nil, nil, nil).RegisterRoutes(router)
+	return router
+}
+
+func newTestService(t *testing.T) (*service.NotificationService, string) {
+	notificationService := service.NewNotificationService(db.NewFakeDB(nil), db.NewMemoryLeaser(), time.Hour)
+	created, err := notificationService.ScheduleNotifications(domain.Notification{
+		Email:     "larrycapija@testmail.com",
+		Via:       domain.Mail,
+		Message:   "give the pills to Firulais",
+		StartDate: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		Hours:     []string{"08:30", "20:00"},
+		TimeZone:  "America/Argentina/Buenos_Aires",
+	}, "69-abc")
+	require.NoError(t, err)
+
+	return notificationService, created[0].ScheduleID
+}
+
+func doRequest(router *gin.Engine, method string, path string, body string, ifMatch string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set(headers.JWT, testToken)
+	request.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		request.Header.Set("If-Match", ifMatch)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestNotificationIfMatch(t *testing.T) {
+	notificationService, scheduleID := newTestService(t)
+	router := newTestRouter(t, notificationService)
+	notifications, err := notificationService.GetSchedule(scheduleID)
+	require.NoError(t, err)
+	path := "/notifications/notification/" + notifications[0].ID
+
+	response := doRequest(router, http.MethodGet, path, "", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	etag := response.Header().Get("ETag")
+	assert.Equal(t, `"1"`, etag)
+
+	response = doRequest(router, http.MethodPatch, path, `{"message": "walk Firulais"}`, `"2"`)
+	assert.Equal(t, http.StatusPreconditionFailed, response.Code)
+
+	response = doRequest(router, http.MethodPatch, path, `{"message": "walk Firulais"}`, etag)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	// Sending the notification does not change its ETag
+	require.NoError(t, notificationService.MarkAsSent(notifications[0].ID, time.Now()))
+	response = doRequest(router, http.MethodDelete, path, "", etag)
+	assert.Equal(t, http.StatusPreconditionFailed, response.Code)
+
+	response = doRequest(router, http.MethodDelete, path, "", `"2"`)
+	assert.Equal(t, http.StatusOK, response.Code)
+}
+
+func TestScheduleNotificationRejectsTimeZonesNotAlignedWithSlots(t *testing.T) {
+	notificationService, _ := newTestService(t)
+	router := newTestRouter(t, notificationService)
+
+	testCases := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "recurring",
+			body: `{"via": "Mail", "message": "give the pills to Firulais", "start_date": "2030-07-01T00:00:00Z", ` +
+				`"hours": ["8:30"], "time_zone": "Asia/Kathmandu"}`,
+		},
+		{
+			name: "one-shot",
+			body: `{"via": "Mail", "message": "give the pills to Firulais", "fire_at": "2030-07-01T08:00:00Z", ` +
+				`"time_zone": "Asia/Kathmandu"}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := doRequest(router, http.MethodPost, "/notifications/notification", testCase.body, "")
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			assert.Contains(t, response.Body.String(), "Asia/Kathmandu")
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/internal/context"
+)
+
+// RestoreNotification godoc
+//
+//	@Summary		Restores a deleted notification
+//	@Description	Undoes the deletion of the notification. It's only possible during the grace period after the deletion, then the notification is purged. The ETag header holds the version of the restored notification
+//
+//	@Tags			Notification
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"jwt data"
+//	@Param			notificationID	path		string	true	"id of the notification"
+//	@Success		200				{object}	domain.NotificationResponse
+//	@Header			200				{string}	ETag	"version of the restored notification"
+//	@Failure		400,401,404,409	{object}	ErrorResponse
+//	@Router			/notifications/notification/{notificationID}/restore [post]
+func (nh *NotificationHandler) RestoreNotification(c *gin.Context) {
+	appContext, err := context.GetAppContext(c.Request.Context())
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errGettingAppContext, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	notificationID := c.Param("notificationID")
+	if notificationID == "" {
+		errResponse := NewErrorResponse(errMissingNotificationID)
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	notification, err := nh.service.GetRestorableNotification(notificationID)
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %w", errFetchingNotification, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	// Sanity check: only the user that deleted the notification can restore it
+	if notification.Email != appContext.Email {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: cannot restore notification, userID %s", errUserNotAllowed, appContext.UserID))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	restoredNotification, err := nh.service.RestoreNotification(notification, actorOf(appContext))
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %w", errRestoringNotification, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	c.Header("ETag", restoredNotification.ETag())
+	c.JSON(http.StatusOK, domain.NewNotificationResponse(restoredNotification))
+}
+
+// GetNotificationHistory godoc
+//
+//	@Summary		Fetches the history of a notification
+//	@Description	Fetches every change made to the notification, oldest first: who made it, when, and the notification before and after it. The history is kept after the notification is deleted
+//
+//	@Tags			Notification
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"jwt data"
+//	@Param			notificationID	path		string	true	"id of the notification"
+//	@Success		200				{object}	[]domain.AuditEntryResponse
+//	@Failure		400,401,404		{object}	ErrorResponse
+//	@Router			/notifications/notification/{notificationID}/history [get]
+func (nh *NotificationHandler) GetNotificationHistory(c *gin.Context) {
+	appContext, err := context.GetAppContext(c.Request.Context())
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errGettingAppContext, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	notificationID := c.Param("notificationID")
+	if notificationID == "" {
+		errResponse := NewErrorResponse(errMissingNotificationID)
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	entries, err := nh.service.GetNotificationHistory(notificationID)
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %w", errFetchingHistory, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	response := make([]domain.AuditEntryResponse, 0, len(entries))
+	for idx := range entries {
+		// Sanity check: the notification must belong to the user
+		if entries[idx].Email != appContext.Email {
+			errResponse := NewErrorResponse(fmt.Errorf("%w: userID %s", errUserNotAllowed, appContext.UserID))
+			c.JSON(errResponse.StatusCode, errResponse)
+			return
+		}
+
+		response = append(response, domain.NewAuditEntryResponse(entries[idx]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"notification-scheduler/internal/domain"
+	"testing"
+)
+
+func TestRestoreNotificationETag(t *testing.T) {
+	notificationService, scheduleID := newTestService(t)
+	router := newTestRouter(t, notificationService)
+	notifications, err := notificationService.GetSchedule(scheduleID)
+	require.NoError(t, err)
+	path := "/notifications/notification/" + notifications[0].ID
+
+	response := doRequest(router, http.MethodDelete, path, "", `"1"`)
+	require.Equal(t, http.StatusOK, response.Code)
+
+	// The ETag of the restored notification holds the version it is stored with
+	response = doRequest(router, http.MethodPost, path+"/restore", "", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	etag := response.Header().Get("ETag")
+	assert.Equal(t, `"3"`, etag)
+
+	response = doRequest(router, http.MethodPatch, path, `{"message": "walk Firulais"}`, etag)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	entries, err := notificationService.GetNotificationHistory(notifications[0].ID)
+	require.NoError(t, err)
+	var actions []domain.AuditAction
+	for idx := range entries {
+		actions = append(actions, entries[idx].Action)
+	}
+	expected := []domain.AuditAction{domain.AuditCreated, domain.AuditDeleted, domain.AuditRestored, domain.AuditUpdated}
+	assert.Equal(t, expected, actions)
+}
@@ -0,0 +1,203 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// csvColumns header of the CSV files. Hours are separated by spaces and dates have RFC 3339 format
+var csvColumns = []string{
+	"schedule_id",
+	"via",
+	"message",
+	"start_date",
+	"end_date",
+	"hours",
+	"time_zone",
+	"recurrence",
+	"fire_at",
+	"catch_up",
+	"webhook_url",
+}
+
+// requiredCSVColumns columns that imported files must have. The rest are optional and may be in any order
+var requiredCSVColumns = []string{"via", "message"}
+
+// formulaPrefixes first characters that make spreadsheets take a cell as a formula. Cells starting with them are
+// written after a quote, that is removed when the file is imported
+const formulaPrefixes = "=+-@\t\r"
+
+func writeCSV(w io.Writer, records []record) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write(csvColumns)
+	if err != nil {
+		return err
+	}
+
+	for _, current := range records {
+		fields := []string{
+			current.ScheduleID,
+			current.Via,
+			current.Message,
+			formatTime(&current.StartDate),
+			formatTime(current.EndDate),
+			strings.Join(current.Hours, " "),
+			current.TimeZone,
+			current.Recurrence,
+			formatTime(current.FireAt),
+			current.CatchUp,
+			current.WebhookURL,
+		}
+		for idx := range fields {
+			fields[idx] = escapeFormula(fields[idx])
+		}
+
+		err = writer.Write(fields)
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func readCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	// Missing trailing fields are taken as empty
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header: %v", errInvalidFile, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for idx, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = idx
+	}
+
+	for _, column := range requiredCSVColumns {
+		if _, found := columns[column]; !found {
+			return nil, fmt.Errorf("%w: %s", errMissingColumn, column)
+		}
+	}
+
+	var rows []Row
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidFile, err)
+		}
+
+		if len(rows) == MaxImportRows {
+			return nil, fmt.Errorf("%w: the maximum is %d", errTooManyRows, MaxImportRows)
+		}
+
+		row := Row{Number: len(rows) + 1}
+		current, err := parseCSVRecord(fields, columns)
+		if err != nil {
+			row.Err = err
+		} else {
+			row.Request = current.toRequest()
+		}
+		rows = append(rows, row)
+	}
+}
+
+// parseCSVRecord returns the record of the given fields. Columns have the position of each field
+func parseCSVRecord(fields []string, columns map[string]int) (record, error) {
+	field := func(column string) string {
+		idx, found := columns[column]
+		if !found || idx >= len(fields) {
+			return ""
+		}
+		return strings.TrimSpace(unescapeFormula(fields[idx]))
+	}
+
+	current := record{
+		ScheduleID: field("schedule_id"),
+		Via:        field("via"),
+		Message:    field("message"),
+		Hours:      strings.Fields(field("hours")),
+		TimeZone:   field("time_zone"),
+		Recurrence: field("recurrence"),
+		CatchUp:    field("catch_up"),
+		WebhookURL: field("webhook_url"),
+	}
+
+	startDate, err := parseTime("start_date", field("start_date"))
+	if err != nil {
+		return record{}, err
+	}
+	if startDate != nil {
+		current.StartDate = *startDate
+	}
+
+	current.EndDate, err = parseTime("end_date", field("end_date"))
+	if err != nil {
+		return record{}, err
+	}
+
+	current.FireAt, err = parseTime("fire_at", field("fire_at"))
+	if err != nil {
+		return record{}, err
+	}
+
+	return current, nil
+}
+
+// escapeFormula returns the cell after a quote if it would be taken as a formula, otherwise as it is
+func escapeFormula(cell string) string {
+	if isFormula(cell) {
+		return "'" + cell
+	}
+
+	return cell
+}
+
+// unescapeFormula returns the cell without the quote added by escapeFormula
+func unescapeFormula(cell string) string {
+	if strings.HasPrefix(cell, "'") && isFormula(cell[1:]) {
+		return cell[1:]
+	}
+
+	return cell
+}
+
+// isFormula returns true if the cell starts with a formula prefix. Cells that start with quotes before one are taken
+// as formulas too, so the quotes they already had are kept when they are imported
+func isFormula(cell string) bool {
+	trimmed := strings.TrimLeft(cell, "'")
+	return trimmed != "" && strings.ContainsRune(formulaPrefixes, rune(trimmed[0]))
+}
+
+// parseTime parses an RFC 3339 date. Empty dates are nil
+func parseTime(column string, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errInvalidField, column, err)
+	}
+
+	return &parsed, nil
+}
+
+// formatTime returns the date with RFC 3339 format. Nil and zero dates are empty
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
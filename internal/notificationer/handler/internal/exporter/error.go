@@ -0,0 +1,12 @@
+package exporter
+
+import "errors"
+
+var (
+	errUnsupportedFormat = errors.New("error unsupported format")
+	errInvalidFile       = errors.New("error invalid file")
+	errMissingColumn     = errors.New("error missing column")
+	errTooManyRows       = errors.New("error too many rows")
+	errInvalidField      = errors.New("error invalid field")
+	errInvalidRecurrence = errors.New("error invalid recurrence")
+)
@@ -0,0 +1,233 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/recurrence"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Format format of the files with the notifications of a user
+type Format string
+
+const (
+	JSON Format = "json"
+	CSV  Format = "csv"
+	// ICS iCalendar file, to move the notifications to a calendar app. It can only be exported
+	ICS Format = "ics"
+)
+
+// MaxImportRows maximum amount of rows of an imported file
+const MaxImportRows = 500
+
+var contentTypes = map[Format]string{
+	JSON: "application/json",
+	CSV:  "text/csv",
+	ICS:  "text/calendar",
+}
+
+// ValidFormat returns true if the notifications can be exported with the given format, otherwise false
+func ValidFormat(format Format) bool {
+	_, found := contentTypes[format]
+	return found
+}
+
+// Importable returns true if the notifications can be imported from a file with the given format, otherwise false
+func Importable(format Format) bool {
+	return format == JSON || format == CSV
+}
+
+// ContentType returns the MIME type of the files with the given format
+func ContentType(format Format) string {
+	return contentTypes[format]
+}
+
+// Row notification request read from a row of an imported file. Rows are numbered from 1, in the order of the file.
+// Err is set when the row cannot be read, in that case the request is empty
+type Row struct {
+	Number  int
+	Request domain.NotificationRequest
+	Err     error
+}
+
+// Write writes the given notifications with the given format. JSON and CSV files have one row per schedule, with
+// the same fields as the notification requests, so they can be imported again. ICS files have one event per
+// notification. Completed notifications are left out, they are not sent anymore. The instant is the one on which the
+// file is created
+func Write(w io.Writer, format Format, notifications []domain.Notification, instant time.Time) error {
+	notifications = pending(notifications)
+	switch format {
+	case JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(newRecords(notifications))
+	case CSV:
+		return writeCSV(w, newRecords(notifications))
+	case ICS:
+		return writeICS(w, notifications, instant)
+	default:
+		return fmt.Errorf("%w: %s", errUnsupportedFormat, format)
+	}
+}
+
+// Read reads the rows of a file with the given format. An error is returned if the file itself cannot be read,
+// the errors of each row are set in the row
+func Read(r io.Reader, format Format) ([]Row, error) {
+	switch format {
+	case JSON:
+		return readJSON(r)
+	case CSV:
+		return readCSV(r)
+	default:
+		return nil, fmt.Errorf("%w: %s cannot be imported", errUnsupportedFormat, format)
+	}
+}
+
+// record schedule as it's saved in JSON and CSV files. The hours are the ones of the notifications of the schedule,
+// they are left empty if they are defined by a cron expression or the notification is one-shot
+type record struct {
+	ScheduleID string     `json:"schedule_id,omitempty"`
+	Via        string     `json:"via"`
+	Message    string     `json:"message"`
+	StartDate  time.Time  `json:"start_date"`
+	EndDate    *time.Time `json:"end_date,omitempty"`
+	Hours      []string   `json:"hours,omitempty"`
+	TimeZone   string     `json:"time_zone,omitempty"`
+	Recurrence string     `json:"recurrence,omitempty"`
+	FireAt     *time.Time `json:"fire_at,omitempty"`
+	CatchUp    string     `json:"catch_up,omitempty"`
+	WebhookURL string     `json:"webhook_url,omitempty"`
+}
+
+// newRecords returns one record per schedule. Notifications of the same schedule that were updated separately
+// have different records
+func newRecords(notifications []domain.Notification) []record {
+	sorted := make([]domain.Notification, len(notifications))
+	copy(sorted, notifications)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].ScheduleID != sorted[j].ScheduleID {
+			return sorted[i].ScheduleID < sorted[j].ScheduleID
+		}
+		if sorted[i].Hours[0] != sorted[j].Hours[0] {
+			return sorted[i].Hours[0] < sorted[j].Hours[0]
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	records := make([]record, 0, len(sorted))
+	for idx := range sorted {
+		notification := sorted[idx]
+		current := record{
+			ScheduleID: notification.ScheduleID,
+			Via:        string(notification.Via),
+			Message:    notification.Message,
+			StartDate:  notification.StartDate,
+			EndDate:    notification.EndDate,
+			TimeZone:   notification.TimeZone,
+			Recurrence: notification.Recurrence,
+			FireAt:     notification.FireAt,
+			CatchUp:    string(notification.CatchUp),
+			WebhookURL: notification.WebhookURL,
+		}
+
+		// Cron expressions and one-shot notifications define the hours by themselves
+		isCron := notification.Recurrence != "" && !recurrence.IsRRule(notification.Recurrence)
+		hasHours := notification.FireAt == nil && !isCron
+		last := len(records) - 1
+		if last >= 0 && records[last].sameSchedule(current) {
+			if hasHours {
+				records[last].Hours = append(records[last].Hours, notification.Hours[0])
+			}
+			continue
+		}
+
+		if hasHours {
+			current.Hours = []string{notification.Hours[0]}
+		}
+		records = append(records, current)
+	}
+
+	return records
+}
+
+// sameSchedule returns true if both records belong to the same schedule and have the same fields, besides the hours
+func (r record) sameSchedule(other record) bool {
+	return r.ScheduleID != "" &&
+		r.ScheduleID == other.ScheduleID &&
+		r.Via == other.Via &&
+		r.Message == other.Message &&
+		r.StartDate.Equal(other.StartDate) &&
+		equalTimes(r.EndDate, other.EndDate) &&
+		r.TimeZone == other.TimeZone &&
+		r.Recurrence == other.Recurrence &&
+		equalTimes(r.FireAt, other.FireAt) &&
+		r.CatchUp == other.CatchUp &&
+		r.WebhookURL == other.WebhookURL
+}
+
+// toRequest returns the record as a notification request, normalized the same way as the requests of the API
+func (r record) toRequest() domain.NotificationRequest {
+	return domain.NotificationRequest{
+		Via:        domain.Via(strings.ToLower(strings.TrimSpace(r.Via))),
+		Message:    r.Message,
+		StartDate:  r.StartDate,
+		EndDate:    r.EndDate,
+		Hours:      r.Hours,
+		TimeZone:   strings.TrimSpace(r.TimeZone),
+		Recurrence: strings.TrimSpace(r.Recurrence),
+		FireAt:     r.FireAt,
+		CatchUp:    domain.CatchUpPolicy(strings.ToLower(strings.TrimSpace(r.CatchUp))),
+		WebhookURL: strings.TrimSpace(r.WebhookURL),
+	}
+}
+
+func readJSON(r io.Reader) ([]Row, error) {
+	var rawRecords []json.RawMessage
+	err := json.NewDecoder(r).Decode(&rawRecords)
+	if err != nil {
+		return nil, fmt.Errorf("%w: expected an array of notifications: %v", errInvalidFile, err)
+	}
+
+	if len(rawRecords) > MaxImportRows {
+		return nil, fmt.Errorf("%w: %d, the maximum is %d", errTooManyRows, len(rawRecords), MaxImportRows)
+	}
+
+	rows := make([]Row, 0, len(rawRecords))
+	for idx, rawRecord := range rawRecords {
+		row := Row{Number: idx + 1}
+		var current record
+		err = json.Unmarshal(rawRecord, &current)
+		if err != nil {
+			row.Err = fmt.Errorf("%w: %v", errInvalidField, err)
+		} else {
+			row.Request = current.toRequest()
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// pending returns the notifications that are not completed
+func pending(notifications []domain.Notification) []domain.Notification {
+	var pendingNotifications []domain.Notification
+	for idx := range notifications {
+		if notifications[idx].CompletedAt == nil {
+			pendingNotifications = append(pendingNotifications, notifications[idx])
+		}
+	}
+
+	return pendingNotifications
+}
+
+func equalTimes(first *time.Time, second *time.Time) bool {
+	if first == nil || second == nil {
+		return first == second
+	}
+
+	return first.Equal(*second)
+}
@@ -0,0 +1,251 @@
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"notification-scheduler/internal/domain"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testNotifications() []domain.Notification {
+	startDate := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	fireAt := time.Date(2030, 1, 15, 9, 30, 0, 0, time.UTC)
+	return []domain.Notification{
+		{
+			ID:         "2",
+			ScheduleID: "schedule-1",
+			Email:      "larrycapija@testmail.com",
+			Message:    "give the pills to Firulais",
+			Via:        domain.Mail,
+			StartDate:  startDate,
+			EndDate:    &endDate,
+			Hours:      []string{"20:30"},
+			TimeZone:   "America/Argentina/Buenos_Aires",
+			Recurrence: "FREQ=WEEKLY;BYDAY=MO,TH",
+			CatchUp:    domain.CatchUpLatest,
+		},
+		{
+			ID:         "1",
+			ScheduleID: "schedule-1",
+			Email:      "larrycapija@testmail.com",
+			Message:    "give the pills to Firulais",
+			Via:        domain.Mail,
+			StartDate:  startDate,
+			EndDate:    &endDate,
+			Hours:      []string{"08:00"},
+			TimeZone:   "America/Argentina/Buenos_Aires",
+			Recurrence: "FREQ=WEEKLY;BYDAY=MO,TH",
+			CatchUp:    domain.CatchUpLatest,
+		},
+		{
+			ID:         "3",
+			ScheduleID: "schedule-2",
+			Email:      "larrycapija@testmail.com",
+			Message:    "pay the rent, the bills; and the phone",
+			Via:        domain.Mail,
+			StartDate:  startDate,
+			Hours:      []string{"10:00"},
+			Recurrence: "0 10 1 * MON",
+			CatchUp:    domain.CatchUpSkip,
+		},
+		{
+			ID:         "4",
+			ScheduleID: "schedule-3",
+			Email:      "larrycapija@testmail.com",
+			Message:    "call the vet",
+			Via:        domain.Mail,
+			StartDate:  fireAt,
+			Hours:      []string{"09:30"},
+			TimeZone:   "UTC",
+			FireAt:     &fireAt,
+			CatchUp:    domain.CatchUpLatest,
+		},
+	}
+}
+
+func TestExportedFilesCanBeImported(t *testing.T) {
+	for _, format := range []Format{JSON, CSV} {
+		t.Run(string(format), func(t *testing.T) {
+			var file bytes.Buffer
+			err := Write(&file, format, testNotifications(), time.Now())
+			require.NoError(t, err)
+
+			rows, err := Read(&file, format)
+			require.NoError(t, err)
+			require.Len(t, rows, 3)
+
+			for idx, row := range rows {
+				assert.Equal(t, idx+1, row.Number)
+				assert.NoError(t, row.Err)
+			}
+
+			// Both hours of the first schedule are in a single row
+			assert.Equal(t, []string{"08:00", "20:30"}, rows[0].Request.Hours)
+			assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO,TH", rows[0].Request.Recurrence)
+			assert.Equal(t, "America/Argentina/Buenos_Aires", rows[0].Request.TimeZone)
+			require.NotNil(t, rows[0].Request.EndDate)
+			assert.True(t, rows[0].Request.EndDate.Equal(*testNotifications()[0].EndDate))
+
+			// Cron expressions define the hours
+			assert.Empty(t, rows[1].Request.Hours)
+			assert.Equal(t, "pay the rent, the bills; and the phone", rows[1].Request.Message)
+			assert.Equal(t, domain.CatchUpSkip, rows[1].Request.CatchUp)
+
+			// One-shot notifications only have the fire instant
+			assert.Empty(t, rows[2].Request.Hours)
+			require.NotNil(t, rows[2].Request.FireAt)
+			assert.True(t, rows[2].Request.FireAt.Equal(*testNotifications()[3].FireAt))
+		})
+	}
+}
+
+func TestReadCSVReportsRowErrors(t *testing.T) {
+	file := "message,via,start_date,hours\n" +
+		"give the pills to Firulais,MAIL,2024-07-01T00:00:00Z,8:30 20:00\n" +
+		"call the vet,mail,yesterday,10:00\n" +
+		"pay the rent,mail\n"
+
+	rows, err := Read(strings.NewReader(file), CSV)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+
+	assert.NoError(t, rows[0].Err)
+	assert.Equal(t, domain.Mail, rows[0].Request.Via)
+	assert.Equal(t, []string{"8:30", "20:00"}, rows[0].Request.Hours)
+
+	assert.ErrorIs(t, rows[1].Err, errInvalidField)
+
+	assert.NoError(t, rows[2].Err)
+	assert.True(t, rows[2].Request.StartDate.IsZero())
+
+	_, err = Read(strings.NewReader("message,hours\n"), CSV)
+	assert.ErrorIs(t, err, errMissingColumn)
+
+	_, err = Read(strings.NewReader("message,via\n"), ICS)
+	assert.ErrorIs(t, err, errUnsupportedFormat)
+}
+
+func TestCSVEscapesFormulas(t *testing.T) {
+	messages := []string{"=HYPERLINK(\"http://sarasa.com\")", "-5 pills", "'@Firulais", "'quoted"}
+	var notifications []domain.Notification
+	for idx, message := range messages {
+		notification := testNotifications()[3]
+		notification.ScheduleID = fmt.Sprintf("schedule-%d", idx)
+		notification.Message = message
+		notifications = append(notifications, notification)
+	}
+
+	var file bytes.Buffer
+	require.NoError(t, Write(&file, CSV, notifications, time.Now()))
+	assert.Contains(t, file.String(), `"'=HYPERLINK(""http://sarasa.com"")"`)
+	assert.Contains(t, file.String(), ",'-5 pills,")
+	assert.Contains(t, file.String(), ",''@Firulais,")
+	assert.Contains(t, file.String(), ",'quoted,")
+
+	rows, err := Read(&file, CSV)
+	require.NoError(t, err)
+	require.Len(t, rows, len(messages))
+	for idx := range rows {
+		assert.Equal(t, messages[idx], rows[idx].Request.Message)
+	}
+}
+
+func TestWriteICS(t *testing.T) {
+	var file bytes.Buffer
+	instant := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	completedAt := instant
+	completed := testNotifications()[3]
+	completed.ID = "5"
+	completed.CompletedAt = &completedAt
+	err := Write(&file, ICS, append(testNotifications(), completed), instant)
+	require.NoError(t, err)
+
+	content := file.String()
+	for _, line := range strings.Split(strings.TrimSuffix(content, "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), icsLineLength)
+	}
+
+	// Folded lines are joined back
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n ", ""), "\r\n")
+	assert.Equal(t, "BEGIN:VCALENDAR", lines[0])
+	assert.Equal(t, 5, strings.Count(content, "BEGIN:VEVENT"))
+	assert.Equal(t, 5, strings.Count(content, "BEGIN:VALARM"))
+
+	assert.Contains(t, lines, "UID:1@notification-scheduler")
+	assert.Contains(t, lines, "DTSTAMP:20240701T120000Z")
+	assert.Contains(t, lines, "DTSTART;TZID=America/Argentina/Buenos_Aires:20240701T080000")
+	assert.Contains(t, lines, "RRULE:FREQ=WEEKLY;BYDAY=MO,TH;UNTIL=20241231T000000Z")
+
+	// The time zones of the events are defined once, one-shot notifications are in UTC
+	assert.Equal(t, 1, strings.Count(content, "BEGIN:VTIMEZONE"))
+	assert.Contains(t, lines, "TZID:America/Argentina/Buenos_Aires")
+	assert.Contains(t, lines, "TZOFFSETTO:-0300")
+
+	// Cron expressions restricting both days of month and week need two events. The days of month are left out of
+	// the second one, so July 1, a monday, only occurs once
+	assert.Contains(t, lines, "UID:3-1@notification-scheduler")
+	assert.Contains(t, lines, "RRULE:FREQ=DAILY;BYMONTHDAY=1")
+	assert.Contains(t, lines, "UID:3-2@notification-scheduler")
+	otherDays := "2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17,18,19,20,21,22,23,24,25,26,27,28,29,30,31"
+	assert.Contains(t, lines, "RRULE:FREQ=DAILY;BYMONTHDAY="+otherDays+";BYDAY=MO")
+	assert.Equal(t, 1, strings.Count(content, "DTSTART:20240701T100000"))
+	assert.Contains(t, lines, "DTSTART:20240708T100000")
+	assert.Contains(t, lines, `SUMMARY:pay the rent\, the bills\; and the phone`)
+
+	// One-shot notifications do not recur, and completed ones are left out
+	assert.Contains(t, lines, "DTSTART:20300115T093000Z")
+	assert.NotContains(t, lines, "UID:5@notification-scheduler")
+	assert.Equal(t, 4, strings.Count(content, "RRULE:"))
+}
+
+func TestICSTimeZone(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected []string
+	}{
+		{
+			name: "Europe/Madrid",
+			expected: []string{
+				"BEGIN:VTIMEZONE", "TZID:Europe/Madrid",
+				"BEGIN:DAYLIGHT", "DTSTART:20240331T020000", "TZOFFSETFROM:+0100", "TZOFFSETTO:+0200",
+				"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU", "TZNAME:CEST", "END:DAYLIGHT",
+				"BEGIN:STANDARD", "DTSTART:20241027T030000", "TZOFFSETFROM:+0200", "TZOFFSETTO:+0100",
+				"RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU", "TZNAME:CET", "END:STANDARD",
+				"END:VTIMEZONE",
+			},
+		},
+		{
+			name: "America/New_York",
+			expected: []string{
+				"BEGIN:VTIMEZONE", "TZID:America/New_York",
+				"BEGIN:DAYLIGHT", "DTSTART:20240310T020000", "TZOFFSETFROM:-0500", "TZOFFSETTO:-0400",
+				"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU", "TZNAME:EDT", "END:DAYLIGHT",
+				"BEGIN:STANDARD", "DTSTART:20241103T020000", "TZOFFSETFROM:-0400", "TZOFFSETTO:-0500",
+				"RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU", "TZNAME:EST", "END:STANDARD",
+				"END:VTIMEZONE",
+			},
+		},
+		{
+			name: "America/Argentina/Buenos_Aires",
+			expected: []string{
+				"BEGIN:VTIMEZONE", "TZID:America/Argentina/Buenos_Aires",
+				"BEGIN:STANDARD", "DTSTART:19700101T000000", "TZOFFSETFROM:-0300", "TZOFFSETTO:-0300", "END:STANDARD",
+				"END:VTIMEZONE",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			location, err := time.LoadLocation(testCase.name)
+			require.NoError(t, err)
+			instant := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+			assert.Equal(t, testCase.expected, icsTimeZone(testCase.name, location, instant))
+		})
+	}
+}
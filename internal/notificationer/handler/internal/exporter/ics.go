@@ -0,0 +1,292 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/recurrence"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// icsLocalTime format of local times, without time zone, of iCalendar files
+	icsLocalTime = "20060102T150405"
+	// icsUTCTime format of UTC times of iCalendar files
+	icsUTCTime = "20060102T150405Z"
+	// icsLineLength maximum amount of bytes of a line. Longer lines are folded
+	icsLineLength = 75
+)
+
+// writeICS writes an iCalendar (RFC 5545) calendar with one event per notification. Recurring notifications have
+// recurring events, and every event has an alarm when it starts. Notifications without time zone use floating
+// times, that calendar apps show in the time zone of the user. The time zones of the rest are defined in the file
+func writeICS(w io.Writer, notifications []domain.Notification, instant time.Time) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//notification-scheduler//EN",
+		"CALSCALE:GREGORIAN",
+	}
+
+	timeZones := make(map[string]*time.Location)
+	for idx := range notifications {
+		if notifications[idx].TimeZone != "" && notifications[idx].FireAt == nil {
+			timeZones[notifications[idx].TimeZone] = notifications[idx].Location()
+		}
+	}
+
+	names := make([]string, 0, len(timeZones))
+	for name := range timeZones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, icsTimeZone(name, timeZones[name], instant)...)
+	}
+
+	for idx := range notifications {
+		events, err := icsEvents(notifications[idx], instant)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, events...)
+	}
+
+	lines = append(lines, "END:VCALENDAR")
+
+	var content strings.Builder
+	for _, line := range lines {
+		content.WriteString(foldLine(line))
+		content.WriteString("\r\n")
+	}
+
+	_, err := io.WriteString(w, content.String())
+	return err
+}
+
+// icsEvents returns the lines of the events of the notification. It has a single event, unless its recurrence
+// needs several rules, see recurrence.ToCalendarRules. Events start on the first occurrence of their rule, and
+// the ones that never occur are left out
+func icsEvents(notification domain.Notification, instant time.Time) ([]string, error) {
+	if notification.FireAt != nil {
+		start := "DTSTART:" + notification.FireAt.UTC().Format(icsUTCTime)
+		return icsEvent(notification.ID, notification.Message, start, "", instant), nil
+	}
+
+	rules := []recurrence.CalendarRule{{RRule: "FREQ=DAILY"}}
+	if notification.Recurrence != "" {
+		var err error
+		rules, err = recurrence.ToCalendarRules(notification.Recurrence)
+		if err != nil {
+			return nil, fmt.Errorf("%w: notification %s: %v", errInvalidRecurrence, notification.ID, err)
+		}
+	}
+
+	var lines []string
+	for idx, rule := range rules {
+		eventNotification := notification
+		eventNotification.Recurrence = rule.Expression
+		start, found := firstStart(eventNotification, rule.Excluded)
+		if !found {
+			continue
+		}
+
+		uid := notification.ID
+		if len(rules) > 1 {
+			uid = fmt.Sprintf("%s-%d", notification.ID, idx+1)
+		}
+
+		rrule := rule.RRule
+		if notification.EndDate != nil && !limited(rrule) {
+			rrule += ";UNTIL=" + formatICSTime(notification, *notification.EndDate)
+		}
+
+		lines = append(lines, icsEvent(uid, notification.Message, icsStart(notification, start), rrule, instant)...)
+	}
+
+	return lines, nil
+}
+
+// firstStart returns the first fire time of the notification that is not on a day of the excluded expression, if any
+func firstStart(notification domain.Notification, excluded string) (time.Time, bool) {
+	excludedNotification := notification
+	excludedNotification.Recurrence = excluded
+	for {
+		start, found := notification.FirstFireTime()
+		if !found || excluded == "" || !excludedNotification.OccursOn(start) {
+			return start, found
+		}
+
+		// The search goes on from the next day
+		year, month, day := start.In(notification.Location()).Date()
+		notification.StartDate = time.Date(year, month, day+1, 0, 0, 0, 0, notification.Location())
+	}
+}
+
+// icsEvent returns the lines of an event with an alarm when it starts. Events without RRULE do not recur
+func icsEvent(uid string, message string, start string, rrule string, instant time.Time) []string {
+	lines := []string{
+		"BEGIN:VEVENT",
+		"UID:" + uid + "@notification-scheduler",
+		"DTSTAMP:" + instant.UTC().Format(icsUTCTime),
+		start,
+	}
+	if rrule != "" {
+		lines = append(lines, "RRULE:"+rrule)
+	}
+
+	return append(lines,
+		"SUMMARY:"+escapeText(message),
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:"+escapeText(message),
+		"TRIGGER:PT0S",
+		"END:VALARM",
+		"END:VEVENT",
+	)
+}
+
+// icsStart returns the DTSTART of a recurring notification, in its time zone
+func icsStart(notification domain.Notification, start time.Time) string {
+	localStart := start.In(notification.Location()).Format(icsLocalTime)
+	if notification.TimeZone == "" {
+		return "DTSTART:" + localStart
+	}
+
+	return fmt.Sprintf("DTSTART;TZID=%s:%s", notification.TimeZone, localStart)
+}
+
+// icsTimeZone returns the VTIMEZONE of the given time zone, with its offsets in the year of the given instant. The
+// changes of a zone with daylight saving time repeat every year on the same weekday of the month, like the second
+// sunday of March. Zones without changes have a single offset
+func icsTimeZone(name string, location *time.Location, instant time.Time) []string {
+	lines := []string{"BEGIN:VTIMEZONE", "TZID:" + name}
+	yearStart := time.Date(instant.Year(), 1, 1, 0, 0, 0, 0, location)
+	yearEnd := yearStart.AddDate(1, 0, 0)
+	var changes []time.Time
+	for _, end := yearStart.ZoneBounds(); !end.IsZero() && end.Before(yearEnd); _, end = end.ZoneBounds() {
+		changes = append(changes, end)
+	}
+
+	if len(changes) == 0 {
+		zoneName, offset := yearStart.Zone()
+		lines = append(lines, icsObservance("STANDARD", "19700101T000000", offset, offset, zoneName, "")...)
+	}
+
+	for _, change := range changes {
+		local := change.In(location)
+		zoneName, offsetTo := local.Zone()
+		_, offsetFrom := change.Add(-time.Second).In(location).Zone()
+		kind := "STANDARD"
+		if local.IsDST() {
+			kind = "DAYLIGHT"
+		}
+
+		// The start is the local time before the change. Only the changes of daylight saving time repeat
+		start := change.In(time.FixedZone("", offsetFrom))
+		rrule := ""
+		if len(changes) == 2 {
+			rrule = fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%s", start.Month(), weekdayOfMonth(start))
+		}
+		lines = append(lines, icsObservance(kind, start.Format(icsLocalTime), offsetFrom, offsetTo, zoneName, rrule)...)
+	}
+
+	return append(lines, "END:VTIMEZONE")
+}
+
+// icsObservance returns the lines of a STANDARD or DAYLIGHT observance of a VTIMEZONE
+func icsObservance(kind string, start string, offsetFrom int, offsetTo int, zoneName string, rrule string) []string {
+	lines := []string{
+		"BEGIN:" + kind,
+		"DTSTART:" + start,
+		"TZOFFSETFROM:" + formatOffset(offsetFrom),
+		"TZOFFSETTO:" + formatOffset(offsetTo),
+	}
+	if rrule != "" {
+		lines = append(lines, "RRULE:"+rrule)
+	}
+
+	// Zones without abbreviation are named after their offset, like -03
+	if zoneName != "" && !strings.HasPrefix(zoneName, "+") && !strings.HasPrefix(zoneName, "-") {
+		lines = append(lines, "TZNAME:"+zoneName)
+	}
+
+	return append(lines, "END:"+kind)
+}
+
+// weekdayOfMonth returns the BYDAY value of the day in its month, like 2SU for the second sunday. The fourth and fifth
+// weekdays that are the last ones of the month are taken as the last one, -1SU
+func weekdayOfMonth(day time.Time) string {
+	weekday := strings.ToUpper(day.Weekday().String()[:2])
+	ordinal := (day.Day()-1)/7 + 1
+	lastDay := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if ordinal >= 4 && day.Day()+7 > lastDay {
+		return "-1" + weekday
+	}
+
+	return fmt.Sprintf("%d%s", ordinal, weekday)
+}
+
+// formatOffset returns the UTC offset, in seconds, with the format of iCalendar files, like -0300
+func formatOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+
+	formatted := fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset%3600/60)
+	if offset%60 != 0 {
+		formatted += fmt.Sprintf("%02d", offset%60)
+	}
+
+	return formatted
+}
+
+// formatICSTime returns the time as UTC, or as a floating time if the notification has no time zone, as RFC 5545
+// requires for the UNTIL of the rules
+func formatICSTime(notification domain.Notification, t time.Time) string {
+	if notification.TimeZone == "" {
+		return t.In(notification.Location()).Format(icsLocalTime)
+	}
+
+	return t.UTC().Format(icsUTCTime)
+}
+
+// limited returns true if the RRULE already has an end, COUNT or UNTIL
+func limited(rrule string) bool {
+	upperRule := strings.ToUpper(rrule)
+	return strings.Contains(upperRule, "UNTIL=") || strings.Contains(upperRule, "COUNT=")
+}
+
+// escapeText escapes the characters that cannot be used in iCalendar texts
+func escapeText(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(text)
+}
+
+// foldLine splits the line in lines of at most icsLineLength bytes. Continuation lines start with a space, and
+// characters are never split
+func foldLine(line string) string {
+	var folded strings.Builder
+	length := 0
+	for _, char := range line {
+		size := utf8.RuneLen(char)
+		if length+size > icsLineLength {
+			folded.WriteString("\r\n ")
+			length = 1
+		}
+		folded.WriteRune(char)
+		length += size
+	}
+
+	return folded.String()
+}
@@ -3,14 +3,23 @@ package validator
 import "errors"
 
 var (
-	errInvalidMessage         = errors.New("error invalid message")
-	errInvalidStartDate       = errors.New("error invalid start date")
-	errInvalidEndDate         = errors.New("error invalid end date")
-	errInvalidHour            = errors.New("error invalid hour")
-	errRepeatedHour           = errors.New("error repeated hour")
-	errInvalidVia             = errors.New("error invalid via")
-	errMissingTelegramID      = errors.New("error missing telegramID")
-	errMissingEmail           = errors.New("error missing telegramID")
-	errMissingUserInformation = errors.New("error missing user information")
-	errNothingToUpdate        = errors.New("error nothing to update")
+	errInvalidMessage       = errors.New("error invalid message")
+	errInvalidStartDate     = errors.New("error invalid start date")
+	errInvalidEndDate       = errors.New("error invalid end date")
+	errInvalidHour          = errors.New("error invalid hour")
+	errRepeatedHour         = errors.New("error repeated hour")
+	errInvalidTimeZone      = errors.New("error invalid time zone")
+	errInvalidRecurrence    = errors.New("error invalid recurrence")
+	errHoursWithCron        = errors.New("error hours given with cron expression")
+	errMissingHours         = errors.New("error missing hours")
+	errMissingStartDate     = errors.New("error missing start date")
+	errInvalidFireAt        = errors.New("error invalid fire at")
+	errInvalidCatchUpPolicy = errors.New("error invalid catch-up policy")
+	errInvalidVia           = errors.New("error invalid via")
+	errNothingToUpdate      = errors.New("error nothing to update")
+	errInvalidStatus        = errors.New("error invalid status")
+	errInvalidHourRange     = errors.New("error invalid hour range")
+	errInvalidDateRange     = errors.New("error invalid date range")
+	errInvalidCursor        = errors.New("error invalid cursor")
+	errInvalidLimit         = errors.New("error invalid limit")
 )
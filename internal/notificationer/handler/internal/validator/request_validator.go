@@ -3,6 +3,7 @@ package validator
 import (
 	"fmt"
 	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/recurrence"
 	"notification-scheduler/internal/utils"
 	"time"
 )
@@ -10,11 +11,17 @@ import (
 // ValidateNotificationRequest validates the given notification request. The following checks are performed:
 // + Message must be at least of length 5
 // + StartDate and EndDate must be from now on, not from the past
-// + The hours must be on the hour or thirty. Their range go from 0 to 23
+// + The hours must be on the hour or thirty, with format H:MM or HH:MM. Their range go from 0 to 23
 // + Via must be a valid one. Actually only Telegram, Mail or Both are valid
-// + If via is 'telegram', the notification must contain the telegramID of the user
-// + If via is 'mail', the notification must contain the email of the user
-// + If via is 'both', the notification must contain the email and telegramId of the user
+// + If a time zone is given, it must be a valid IANA time zone whose offsets are whole half hours, otherwise its
+// hours would never be on a slot
+// + If a recurrence is given, it must be a valid cron expression or RRULE. Cron expressions define the hours of
+// the notification, so no hours can be given with them. Otherwise, at least one hour is required
+// + One-shot notifications, the ones with a fire instant, cannot have hours nor recurrence. The instant must be
+// on the hour or thirty and from now on. The rest of the notifications must have a start date
+// + If a catch-up policy is given, it must be a valid one: all, latest or skip
+//
+// The recipients that each channel needs, like the email of the user, are validated by the channels
 func ValidateNotificationRequest(notification domain.NotificationRequest) error {
 	//currentTime := time.Now()
 
@@ -30,36 +37,93 @@ func ValidateNotificationRequest(notification domain.NotificationRequest) error
 	//	return fmt.Errorf("%w: date from the past", errInvalidEndDate)
 	//}
 
+	var err error
+	if notification.FireAt != nil {
+		err = validateOneShot(notification)
+	} else {
+		err = validateRecurrence(notification)
+	}
+	if err != nil {
+		return err
+	}
+
 	hoursSet := make(map[string]bool)
 	for _, hour := range notification.Hours {
 		if !utils.ValidHour(hour) {
 			return fmt.Errorf("%w: hours must be o'clock or 30, and range from 0 to 23. Given: %s", errInvalidHour, hour)
 		}
-		if hoursSet[hour] {
+		// 8:00 and 08:00 are the same hour
+		normalizedHour, _ := utils.NormalizeHour(hour)
+		if hoursSet[normalizedHour] {
 			return fmt.Errorf("%w: %s", errRepeatedHour, hour)
 		}
-		hoursSet[hour] = true
+		hoursSet[normalizedHour] = true
+	}
+
+	if notification.TimeZone != "" {
+		location, err := time.LoadLocation(notification.TimeZone)
+		if err != nil {
+			return fmt.Errorf("%w: %s", errInvalidTimeZone, notification.TimeZone)
+		}
+
+		if !utils.AlignedWithSlots(location, time.Now()) {
+			return fmt.Errorf("%w: %s offset is not a whole half hour", errInvalidTimeZone, notification.TimeZone)
+		}
+	}
+
+	if notification.CatchUp != "" && !domain.ValidCatchUpPolicy(notification.CatchUp) {
+		return fmt.Errorf("%w: %s", errInvalidCatchUpPolicy, notification.CatchUp)
 	}
 
 	if !domain.ValidVia(notification.Via) {
 		return fmt.Errorf("%w: %s", errInvalidVia, notification.Via)
 	}
 
-	if notification.Via == domain.Telegram && notification.TelegramID == "" {
-		return errMissingTelegramID
+	return nil
+}
+
+// validateOneShot checks that the request only contains the fire instant to schedule the notification
+func validateOneShot(notification domain.NotificationRequest) error {
+	if len(notification.Hours) > 0 || notification.Recurrence != "" {
+		return fmt.Errorf("%w: one-shot notifications cannot have hours nor recurrence", errInvalidFireAt)
+	}
+
+	if !utils.SlotOf(*notification.FireAt).Equal(*notification.FireAt) {
+		return fmt.Errorf("%w: must be o'clock or 30", errInvalidFireAt)
+	}
+
+	if notification.FireAt.Before(time.Now()) {
+		return fmt.Errorf("%w: date from the past", errInvalidFireAt)
+	}
+
+	return nil
+}
+
+// validateRecurrence checks the recurrence of the request and that the hours are consistent with it
+func validateRecurrence(notification domain.NotificationRequest) error {
+	if notification.StartDate.IsZero() {
+		return errMissingStartDate
+	}
+
+	isCron := notification.Recurrence != "" && !recurrence.IsRRule(notification.Recurrence)
+	if isCron && len(notification.Hours) > 0 {
+		return fmt.Errorf("%w: hours are defined by the cron expression", errHoursWithCron)
+	}
+
+	if !isCron && len(notification.Hours) == 0 {
+		return errMissingHours
 	}
 
-	if notification.Via == domain.Mail && notification.Email == "" {
-		return errMissingEmail
+	if notification.Recurrence == "" {
+		return nil
 	}
 
-	if notification.Via == domain.Both && (notification.Email == "" || notification.TelegramID == "") {
-		return fmt.Errorf(
-			"%w: email or telegramID is missing. Got email: %s - telegramID: %s",
-			errMissingUserInformation,
-			notification.Email,
-			notification.TelegramID,
-		)
+	// The rule starts on the requested day in the time zone of the notification, like it does when it's checked
+	year, month, day := notification.StartDate.Date()
+	start := time.Date(year, month, day, 0, 0, 0, 0, utils.LoadLocation(notification.TimeZone))
+	_, err := recurrence.Parse(notification.Recurrence, start)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidRecurrence, err)
 	}
 
 	return nil
@@ -84,3 +148,48 @@ func ValidateUpdateRequest(notification domain.UpdateNotificationRequest) error
 
 	return nil
 }
+
+// ValidateSearchRequest validates the given search request. The following checks are performed:
+// + If a via is given, it must be a valid one
+// + If a status is given, it must be active or expired
+// + Hours must be on the hour or thirty, and the hour range cannot end before it starts
+// + The date range cannot end before it starts
+// + If a cursor is given, it must be one returned by a previous search
+// + The limit must range from 0, the default limit, to domain.MaxSearchLimit
+func ValidateSearchRequest(search domain.NotificationSearchRequest) error {
+	query := search.ToQuery("", time.Now())
+	if search.Via != "" && !domain.ValidVia(query.Via) {
+		return fmt.Errorf("%w: %s", errInvalidVia, search.Via)
+	}
+
+	if search.Status != "" && !domain.ValidNotificationStatus(query.Status) {
+		return fmt.Errorf("%w: must be active or expired. Given: %s", errInvalidStatus, search.Status)
+	}
+
+	for _, hour := range []string{search.HourFrom, search.HourTo} {
+		if hour != "" && !utils.ValidHour(hour) {
+			return fmt.Errorf("%w: hours must be o'clock or 30, and range from 0 to 23. Given: %s", errInvalidHour, hour)
+		}
+	}
+
+	if query.HourFrom != "" && query.HourTo != "" && query.HourTo < query.HourFrom {
+		return fmt.Errorf("%w: %s is before %s", errInvalidHourRange, search.HourTo, search.HourFrom)
+	}
+
+	if query.From != nil && query.To != nil && query.To.Before(*query.From) {
+		return fmt.Errorf("%w: to is before from", errInvalidDateRange)
+	}
+
+	if search.Cursor != "" {
+		_, err := domain.DecodeNotificationCursor(search.Cursor)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errInvalidCursor, err)
+		}
+	}
+
+	if search.Limit < 0 || search.Limit > domain.MaxSearchLimit {
+		return fmt.Errorf("%w: must range from 0, the default limit, to %d", errInvalidLimit, domain.MaxSearchLimit)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/notificationer/templates"
+	"notification-scheduler/internal/utils"
+	"strings"
+)
+
+// PreviewTemplate godoc
+//
+//	@Summary		Previews an email template
+//	@Description	Renders the email template with sample data. Templates: reminder, for notifications sent on every occurrence, and one_shot. With format html or text only that version of the body is returned, so it can be opened in a browser
+//
+//	@Tags			Mail
+//	@Produce		json
+//	@Produce		text/html
+//	@Produce		text/plain
+//	@Param			Authorization	header		string	true	"jwt data"
+//	@Param			name			path		string	true	"name of the template"
+//	@Param			message			query		string	false	"message of the sample notification"
+//	@Param			format			query		string	false	"json, html or text. Default: json"
+//	@Success		200				{object}	domain.EmailPreviewResponse
+//	@Failure		400,401,404		{object}	ErrorResponse
+//	@Router			/notifications/templates/{name}/preview [get]
+func (nh *NotificationHandler) PreviewTemplate(c *gin.Context) {
+	name := c.Param("name")
+	if !utils.Contains(nh.renderer.Names(), name) {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %s, available: %s", errTemplateNotFound, name,
+			strings.Join(nh.renderer.Names(), ", ")))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "json"))
+	if format != "json" && format != "html" && format != "text" {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %s", errInvalidPreviewFormat, format))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	data := templates.SampleData(name)
+	if message := strings.TrimSpace(c.Query("message")); message != "" {
+		data.Message = message
+	}
+
+	rendered, err := nh.renderer.Render(name, data)
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errRenderingTemplate, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	switch format {
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(rendered.HTML))
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(rendered.Text))
+	default:
+		c.JSON(http.StatusOK, domain.EmailPreviewResponse{
+			Template: name,
+			Subject:  rendered.Subject,
+			Text:     rendered.Text,
+			HTML:     rendered.HTML,
+		})
+	}
+}
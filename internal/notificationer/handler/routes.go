@@ -22,7 +22,19 @@ func (nh *NotificationHandler) RegisterRoutes(r *gin.Engine) {
 	group.GET("/notification/:notificationID", nh.GetNotificationData)
 	group.PATCH("/notification/:notificationID", nh.UpdateNotification)
 	group.DELETE("/notification/:notificationID", nh.DeleteNotification)
+	group.POST("/notification/:notificationID/restore", nh.RestoreNotification)
+	group.GET("/notification/:notificationID/history", nh.GetNotificationHistory)
+	group.GET("/export", nh.ExportNotifications)
+	group.POST("/import", nh.ImportNotifications)
+	group.GET("/schedule/:scheduleID", nh.GetSchedule)
+	group.PATCH("/schedule/:scheduleID", nh.UpdateSchedule)
+	group.POST("/schedule/:scheduleID/pause", nh.PauseSchedule)
+	group.POST("/schedule/:scheduleID/resume", nh.ResumeSchedule)
+	group.DELETE("/schedule/:scheduleID", nh.DeleteSchedule)
+	group.POST("/webhook/secret", nh.CreateWebhookSecret)
+	group.DELETE("/webhook/secret", nh.DeleteWebhookSecret)
 	group.POST("/email", nh.SendEmail)
+	group.GET("/templates/:name/preview", nh.PreviewTemplate)
 
 	group.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 }
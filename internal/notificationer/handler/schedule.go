@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/internal/context"
+	"notification-scheduler/internal/notificationer/handler/internal/validator"
+)
+
+// GetSchedule godoc
+//
+//	@Summary		Fetches a schedule by ID
+//	@Description	Fetches all the notifications of the schedule, one per hour. The ETag header holds their versions, to be sent in the If-Match header of the changes of the schedule
+//
+//	@Tags			Schedule
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"jwt data"
+//	@Param			scheduleID		path		string	true	"id of the schedule"
+//	@Success		200				{object}	domain.ScheduleResponse
+//	@Header			200				{string}	ETag	"versions of the notifications of the schedule"
+//	@Failure		400,401,404		{object}	ErrorResponse
+//	@Router			/notifications/schedule/{scheduleID} [get]
+func (nh *NotificationHandler) GetSchedule(c *gin.Context) {
+	scheduleID, notifications, ok := nh.userSchedule(c)
+	if !ok {
+		return
+	}
+
+	c.Header("ETag", domain.ScheduleETag(notifications))
+	c.JSON(http.StatusOK, domain.NewScheduleResponse(scheduleID, notifications))
+}
+
+// UpdateSchedule godoc
+//
+//	@Summary		Updates a schedule
+//	@Description	Updates attributes of all the notifications of the schedule. The attributes that can be updated are: message and end date. If the If-Match header is given, the schedule is only updated if its ETag matches
+//
+//	@Tags			Schedule
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string								true	"jwt data"
+//	@Param			If-Match		header		string								false	"ETag returned when the schedule was fetched"
+//	@Param			scheduleID		path		string								true	"id of the schedule"
+//	@Param			UpdateRequest	body		domain.UpdateNotificationRequest	true	"Fields to update"
+//	@Success		200				{object}	nil
+//	@Failure		400,401,404		{object}	ErrorResponse
+//	@Failure		409,412			{object}	ErrorResponse
+//	@Router			/notifications/schedule/{scheduleID} [patch]
+func (nh *NotificationHandler) UpdateSchedule(c *gin.Context) {
+	appContext, err := context.GetAppContext(c.Request.Context())
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errGettingAppContext, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	if appContext.TelegramRequest {
+		errResponse := NewErrorResponse(fmt.Errorf("requests from Telegram are not allowed"))
+		c.JSON(http.StatusForbidden, errResponse)
+		return
+	}
+
+	var updateRequest domain.UpdateNotificationRequest
+	err = c.ShouldBindJSON(&updateRequest)
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errInvalidUpdateRequest, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	err = validator.ValidateUpdateRequest(updateRequest)
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errUpdateRequestValidation, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	_, notifications, ok := nh.writableSchedule(c)
+	if !ok {
+		return
+	}
+
+	for idx := range notifications {
+		notifications[idx] = domain.Merge(notifications[idx], updateRequest)
+	}
+
+	nh.updateSchedule(c, notifications)
+}
+
+// PauseSchedule godoc
+//
+//	@Summary		Pauses a schedule
+//	@Description	None of the notifications of the schedule is sent until it's resumed. Occurrences missed while paused are not sent later
+//
+//	@Tags			Schedule
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"jwt data"
+//	@Param			If-Match		header		string	false	"ETag returned when the schedule was fetched"
+//	@Param			scheduleID		path		string	true	"id of the schedule"
+//	@Success		200				{object}	nil
+//	@Failure		400,401,404		{object}	ErrorResponse
+//	@Failure		409,412			{object}	ErrorResponse
+//	@Router			/notifications/schedule/{scheduleID}/pause [post]
+func (nh *NotificationHandler) PauseSchedule(c *gin.Context) {
+	nh.setSchedulePaused(c, true)
+}
+
+// ResumeSchedule godoc
+//
+//	@Summary		Resumes a schedule
+//	@Description	The notifications of the schedule are sent again, starting from the next slot
+//
+//	@Tags			Schedule
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"jwt data"
+//	@Param			If-Match		header		string	false	"ETag returned when the schedule was fetched"
+//	@Param			scheduleID		path		string	true	"id of the schedule"
+//	@Success		200				{object}	nil
+//	@Failure		400,401,404		{object}	ErrorResponse
+//	@Failure		409,412			{object}	ErrorResponse
+//	@Router			/notifications/schedule/{scheduleID}/resume [post]
+func (nh *NotificationHandler) ResumeSchedule(c *gin.Context) {
+	nh.setSchedulePaused(c, false)
+}
+
+// DeleteSchedule godoc
+//
+//	@Summary		Deletes a schedule
+//	@Description	Deletes all the notifications of the schedule. If the If-Match header is given, the schedule is only deleted if its ETag matches
+//
+//	@Tags			Schedule
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"jwt data"
+//	@Param			If-Match		header		string	false	"ETag returned when the schedule was fetched"
+//	@Param			scheduleID		path		string	true	"id of the schedule"
+//	@Success		200				{object}	nil
+//	@Failure		400,401,404		{object}	ErrorResponse
+//	@Failure		409,412			{object}	ErrorResponse
+//	@Router			/notifications/schedule/{scheduleID} [delete]
+func (nh *NotificationHandler) DeleteSchedule(c *gin.Context) {
+	_, notifications, ok := nh.writableSchedule(c)
+	if !ok {
+		return
+	}
+
+	appContext, err := context.GetAppContext(c.Request.Context())
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errGettingAppContext, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	err = nh.service.DeleteSchedule(notifications, actorOf(appContext))
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %w", errDeletingSchedule, preconditionError(c, err)))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	c.JSON(http.StatusOK, nil)
+}
+
+func (nh *NotificationHandler) setSchedulePaused(c *gin.Context, paused bool) {
+	_, notifications, ok := nh.writableSchedule(c)
+	if !ok {
+		return
+	}
+
+	for idx := range notifications {
+		notifications[idx].Paused = paused
+	}
+
+	nh.updateSchedule(c, notifications)
+}
+
+func (nh *NotificationHandler) updateSchedule(c *gin.Context, notifications []domain.Notification) {
+	appContext, err := context.GetAppContext(c.Request.Context())
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errGettingAppContext, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	err = nh.service.UpdateSchedule(notifications, actorOf(appContext))
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %w", errUpdatingSchedule, preconditionError(c, err)))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	c.JSON(http.StatusOK, nil)
+}
+
+// userSchedule returns the ID and the notifications of the schedule in the path. Sanity check: all of them must
+// belong to the user. If something fails, the error response is written and false is returned
+func (nh *NotificationHandler) userSchedule(c *gin.Context) (string, []domain.Notification, bool) {
+	appContext, err := context.GetAppContext(c.Request.Context())
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errGettingAppContext, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return "", nil, false
+	}
+
+	scheduleID := c.Param("scheduleID")
+	if scheduleID == "" {
+		errResponse := NewErrorResponse(errMissingScheduleID)
+		c.JSON(errResponse.StatusCode, errResponse)
+		return "", nil, false
+	}
+
+	notifications, err := nh.service.GetSchedule(scheduleID)
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %w", errFetchingSchedule, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return "", nil, false
+	}
+
+	for idx := range notifications {
+		if notifications[idx].Email != appContext.Email {
+			errResponse := NewErrorResponse(fmt.Errorf("%w: userID %s", errUserNotAllowed, appContext.UserID))
+			c.JSON(errResponse.StatusCode, errResponse)
+			return "", nil, false
+		}
+	}
+
+	return scheduleID, notifications, true
+}
+
+// writableSchedule returns the schedule in the path, like userSchedule, if it matches the If-Match header of the
+// request. Otherwise, the precondition failed response is written and false is returned
+func (nh *NotificationHandler) writableSchedule(c *gin.Context) (string, []domain.Notification, bool) {
+	scheduleID, notifications, ok := nh.userSchedule(c)
+	if !ok {
+		return "", nil, false
+	}
+
+	etag := domain.ScheduleETag(notifications)
+	if !matchesETag(c, etag) {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: current ETag %s", errPreconditionFailed, etag))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return "", nil, false
+	}
+
+	return scheduleID, notifications, true
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/notificationer/service"
+	"testing"
+)
+
+// racingService changes the notifications of the schedule right before they are written, like another request
+// that wins the race
+type racingService struct {
+	*service.NotificationService
+}
+
+func (rs racingService) UpdateSchedule(notifications []domain.Notification, actor string) error {
+	for idx := range notifications {
+		concurrent := notifications[idx]
+		concurrent.Message = "walk Firulais"
+		err := rs.NotificationService.UpdateNotification(concurrent, actor)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rs.NotificationService.UpdateSchedule(notifications, actor)
+}
+
+func TestScheduleIfMatch(t *testing.T) {
+	notificationService, scheduleID := newTestService(t)
+	router := newTestRouter(t, notificationService)
+	path := "/notifications/schedule/" + scheduleID
+
+	response := doRequest(router, http.MethodGet, path, "", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	etag := response.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	testCases := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		ifMatch string
+	}{
+		{"update", http.MethodPatch, path, `{"message": "walk Firulais"}`, `"stale"`},
+		{"pause", http.MethodPost, path + "/pause", "", `"stale"`},
+		{"resume", http.MethodPost, path + "/resume", "", `W/` + etag},
+		{"delete", http.MethodDelete, path, "", `"stale"`},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response := doRequest(router, testCase.method, testCase.path, testCase.body, testCase.ifMatch)
+			assert.Equal(t, http.StatusPreconditionFailed, response.Code)
+		})
+	}
+
+	// Nothing was changed, so the ETag is still valid
+	response = doRequest(router, http.MethodPatch, path, `{"message": "walk Firulais"}`, etag)
+	require.Equal(t, http.StatusOK, response.Code)
+
+	response = doRequest(router, http.MethodDelete, path, "", etag)
+	assert.Equal(t, http.StatusPreconditionFailed, response.Code)
+
+	response = doRequest(router, http.MethodGet, path, "", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.NotEqual(t, etag, response.Header().Get("ETag"))
+
+	response = doRequest(router, http.MethodDelete, path, "", response.Header().Get("ETag"))
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	response = doRequest(router, http.MethodGet, path, "", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestScheduleConcurrentUpdate(t *testing.T) {
+	notificationService, scheduleID := newTestService(t)
+	router := newTestRouter(t, racingService{notificationService})
+	path := "/notifications/schedule/" + scheduleID
+
+	// Without If-Match the lost race is a conflict, with it the precondition failed
+	response := doRequest(router, http.MethodPatch, path, `{"message": "feed Pepita"}`, "")
+	assert.Equal(t, http.StatusConflict, response.Code)
+
+	response = doRequest(router, http.MethodGet, path, "", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	response = doRequest(router, http.MethodPatch, path, `{"message": "feed Pepita"}`, response.Header().Get("ETag"))
+	assert.Equal(t, http.StatusPreconditionFailed, response.Code)
+
+	notifications, err := notificationService.GetSchedule(scheduleID)
+	require.NoError(t, err)
+	for idx := range notifications {
+		assert.Equal(t, "walk Firulais", notifications[idx].Message)
+	}
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/internal/context"
+)
+
+// CreateWebhookSecret godoc
+//
+//	@Summary		Creates the webhook secret of the user
+//	@Description	Generates the secret used to sign the webhooks of the user, replacing the previous one. Each webhook has the headers X-Webhook-Timestamp, the unix time of the request, and X-Webhook-Signature: sha256= followed by the hex encoded HMAC-SHA256 of the timestamp, a dot and the body. The secret is only returned once
+//
+//	@Tags			Webhook
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"jwt data, must contain the email of the user"
+//	@Success		201				{object}	domain.WebhookSecretResponse
+//	@Failure		401,403			{object}	ErrorResponse
+//	@Router			/notifications/webhook/secret [post]
+func (nh *NotificationHandler) CreateWebhookSecret(c *gin.Context) {
+	appContext, err := context.GetAppContext(c.Request.Context())
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errGettingAppContext, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	if appContext.TelegramRequest {
+		errResponse := NewErrorResponse(fmt.Errorf("requests from Telegram are not allowed"))
+		c.JSON(http.StatusForbidden, errResponse)
+		return
+	}
+
+	secret, err := nh.service.RotateWebhookSecret(appContext.Email)
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %w", errCreatingWebhookSecret, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	c.JSON(http.StatusCreated, domain.NewWebhookSecretResponse(secret))
+}
+
+// DeleteWebhookSecret godoc
+//
+//	@Summary		Deletes the webhook secret of the user
+//	@Description	Deletes the secret used to sign the webhooks of the user. Webhooks are not sent until a new one is created
+//
+//	@Tags			Webhook
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"jwt data, must contain the email of the user"
+//	@Success		200				{object}	nil
+//	@Failure		401,403			{object}	ErrorResponse
+//	@Router			/notifications/webhook/secret [delete]
+func (nh *NotificationHandler) DeleteWebhookSecret(c *gin.Context) {
+	appContext, err := context.GetAppContext(c.Request.Context())
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %v", errGettingAppContext, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	if appContext.TelegramRequest {
+		errResponse := NewErrorResponse(fmt.Errorf("requests from Telegram are not allowed"))
+		c.JSON(http.StatusForbidden, errResponse)
+		return
+	}
+
+	err = nh.service.DeleteWebhookSecret(appContext.Email)
+	if err != nil {
+		errResponse := NewErrorResponse(fmt.Errorf("%w: %w", errDeletingWebhookSecret, err))
+		c.JSON(errResponse.StatusCode, errResponse)
+		return
+	}
+
+	c.JSON(http.StatusOK, nil)
+}
@@ -0,0 +1,8 @@
+package service
+
+import (
+	"notification-scheduler/internal/notificationer/db/dbtest"
+)
+
+// The conformance suite of the stores must cover every method the service uses
+var _ database = dbtest.Store(nil)
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+	"notification-scheduler/internal/domain"
+	"time"
+)
+
+// EnqueueDeliveries writes the given deliveries to the outbox. Deliveries already enqueued are skipped, so the same
+// occurrence can be enqueued again safely. It returns the amount of deliveries enqueued
+func (ns *NotificationService) EnqueueDeliveries(deliveries []domain.Delivery) (int, error) {
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+
+	enqueued, err := ns.db.EnqueueDeliveries(deliveries)
+	if err != nil {
+		return enqueued, newInternalError("EnqueueDeliveries", err, fmt.Sprintf("deliveries: %d", len(deliveries)))
+	}
+
+	return enqueued, nil
+}
+
+// ClaimDeliveries takes up to limit deliveries that are due at the given instant. Other instances do not claim them
+// until leaseUntil, when they are claimed again if they were not updated, for example because this instance died
+func (ns *NotificationService) ClaimDeliveries(now time.Time, leaseUntil time.Time, limit int) ([]domain.Delivery, error) {
+	deliveries, err := ns.db.ClaimDeliveries(now, leaseUntil, limit)
+	if err != nil {
+		return nil, newInternalError("ClaimDeliveries", err, "")
+	}
+
+	return deliveries, nil
+}
+
+// UpdateDelivery saves the result of an attempt of the delivery. It returns false if the delivery was claimed again
+// in the meantime, in that case the result is discarded
+func (ns *NotificationService) UpdateDelivery(delivery domain.Delivery) (bool, error) {
+	updated, err := ns.db.UpdateDelivery(delivery)
+	if err != nil {
+		return false, newInternalError("UpdateDelivery", err, "deliveryID: "+delivery.ID)
+	}
+
+	return updated, nil
+}
+
+// PurgeFinishedDeliveries deletes the deliveries that finished before the given instant. It returns the amount of
+// deliveries deleted
+func (ns *NotificationService) PurgeFinishedDeliveries(finishedBefore time.Time) (int, error) {
+	purged, err := ns.db.PurgeDeliveries(finishedBefore)
+	if err != nil {
+		return 0, newInternalError("PurgeFinishedDeliveries", err, "finished before: "+finishedBefore.Format(time.RFC3339))
+	}
+
+	return purged, nil
+}
@@ -8,6 +8,9 @@ import (
 var (
 	errNotificationNotFound      = errors.New("error notification not found")
 	errNotificationAlreadyExists = errors.New("error notification already exists")
+	errVersionConflict           = errors.New("error notification was modified concurrently")
+	errWebhookSecretNotFound     = errors.New("error webhook secret not found")
+	errGeneratingWebhookSecret   = errors.New("error generating webhook secret")
 )
 
 type serviceError struct {
@@ -16,6 +19,7 @@ type serviceError struct {
 	extraData        string
 	alreadyExists    bool
 	notFound         bool
+	versionConflict  bool
 	dbError          bool
 }
 
@@ -37,6 +41,15 @@ func newNotificationNotFoundError(operation string, extraData string) error {
 	}
 }
 
+func newWebhookSecretNotFoundError(operation string, extraData string) error {
+	return serviceError{
+		serviceOperation: operation,
+		err:              errWebhookSecretNotFound,
+		extraData:        extraData,
+		notFound:         true,
+	}
+}
+
 func newNotificationAlreadyExistsError(operation string, extraData string) error {
 	return serviceError{
 		serviceOperation: operation,
@@ -46,6 +59,15 @@ func newNotificationAlreadyExistsError(operation string, extraData string) error
 	}
 }
 
+func newVersionConflictError(operation string, extraData string) error {
+	return serviceError{
+		serviceOperation: operation,
+		err:              errVersionConflict,
+		extraData:        extraData,
+		versionConflict:  true,
+	}
+}
+
 func (se serviceError) Error() string {
 	if se.extraData != "" {
 		return fmt.Sprintf("%v: %s - operation: %s", se.err, se.extraData, se.serviceOperation)
@@ -65,3 +87,7 @@ func (se serviceError) InternalError() bool {
 func (se serviceError) AlreadyExists() bool {
 	return se.alreadyExists
 }
+
+func (se serviceError) VersionConflict() bool {
+	return se.versionConflict
+}
@@ -1,34 +1,78 @@
 package service
 
 import (
+	"fmt"
+	"github.com/google/uuid"
 	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/utils"
+	"time"
 )
 
+// sweepLeaseName name of the lease held by the instance that sweeps the notifications
+const sweepLeaseName = "sweep"
+
 type searchFunction func(notification domain.Notification) bool
 
 type database interface {
-	CreateNotifications(notification domain.Notification) ([]domain.Notification, error)
+	CreateNotifications(notification domain.Notification, actor string) ([]domain.Notification, error)
 	GetNotificationsByEmail(email string) ([]domain.Notification, error)
+	GetNotificationsBySchedule(scheduleID string) ([]domain.Notification, error)
+	SearchNotifications(query domain.NotificationQuery) (domain.NotificationPage, error)
 	GetNotification(notificationID string) (*domain.Notification, error)
-	UpdateNotification(notification domain.Notification) error
-	DeleteNotification(notificationID string) (bool, error)
-	GetAll(currentHour string) []domain.Notification
+	UpdateNotification(notification domain.Notification, auditEntries ...domain.AuditEntry) (bool, error)
+	UpdateNotifications(notifications []domain.Notification, auditEntries ...domain.AuditEntry) (bool, error)
+	GetAll(currentHour string) ([]domain.Notification, error)
+	GetTimeZones() ([]string, error)
+	SetLastSent(notificationID string, lastSent time.Time) error
+	CompleteNotification(notificationID string, completedAt time.Time) error
+	GetLastDispatchedSlot() (*time.Time, error)
+	SaveLastDispatchedSlot(slot time.Time) error
+	CompleteExpired(now time.Time) (int, error)
+	PurgeCompleted(completedBefore time.Time) (int, error)
+	PurgeDeleted(deletedBefore time.Time) (int, error)
+	GetAuditEntries(notificationID string) ([]domain.AuditEntry, error)
+	PurgeAuditEntries(savedBefore time.Time) (int, error)
+	EnqueueDeliveries(deliveries []domain.Delivery) (int, error)
+	ClaimDeliveries(now time.Time, leaseUntil time.Time, limit int) ([]domain.Delivery, error)
+	UpdateDelivery(delivery domain.Delivery) (bool, error)
+	PurgeDeliveries(finishedBefore time.Time) (int, error)
+	SaveWebhookSecret(secret domain.WebhookSecret) error
+	GetWebhookSecret(email string) (*domain.WebhookSecret, error)
+	DeleteWebhookSecret(email string) error
+}
+
+// leaser grants leases, so only one instance performs a task at the same time. AcquireLease returns true if the
+// given owner holds the lease, either because it was free, expired or already belonged to that owner
+type leaser interface {
+	AcquireLease(name string, owner string, ttl time.Duration) (bool, error)
 }
 
+// NotificationService deleted notifications are kept during the grace period, so they can be restored. Every change
+// made by the users is recorded in the audit trail, along with who made it: the actor
 type NotificationService struct {
-	db database
+	db          database
+	leaser      leaser
+	instanceID  string
+	gracePeriod time.Duration
 }
 
-func NewNotificationService(db database) *NotificationService {
+func NewNotificationService(db database, leaser leaser, gracePeriod time.Duration) *NotificationService {
 	return &NotificationService{
-		db: db,
+		db:          db,
+		leaser:      leaser,
+		instanceID:  uuid.NewString(),
+		gracePeriod: gracePeriod,
 	}
 }
 
 // ScheduleNotifications creates the notifications. From one notification multiple can be created. This method
-// contains all the logic to create the corresponding amount of notifications.
-func (ns *NotificationService) ScheduleNotifications(notification domain.Notification) ([]domain.Notification, error) {
-	createdNotifications, err := ns.db.CreateNotifications(notification)
+// contains all the logic to create the corresponding amount of notifications. All of them share the same schedule
+func (ns *NotificationService) ScheduleNotifications(notification domain.Notification, actor string) ([]domain.Notification, error) {
+	if notification.ScheduleID == "" {
+		notification.ScheduleID = uuid.NewString()
+	}
+
+	createdNotifications, err := ns.db.CreateNotifications(notification, actor)
 	if err != nil {
 		return nil, newInternalError("ScheduleNotifications", err, "")
 	}
@@ -36,7 +80,7 @@ func (ns *NotificationService) ScheduleNotifications(notification domain.Notific
 	return createdNotifications, nil
 }
 
-// GetNotificationsByUserEmail searches all the notifications that have the given email
+// GetNotificationsByUserEmail searches all the notifications that have the given email. Deleted ones are excluded
 func (ns *NotificationService) GetNotificationsByUserEmail(email string) ([]domain.Notification, error) {
 	operation := "GetNotificationsByUserEmail"
 	notifications, err := ns.db.GetNotificationsByEmail(email)
@@ -44,10 +88,20 @@ func (ns *NotificationService) GetNotificationsByUserEmail(email string) ([]doma
 		return nil, newInternalError(operation, err, "email: "+email)
 	}
 
-	return notifications, nil
+	return withoutDeleted(notifications), nil
 }
 
-// GetNotification returns a single notification. If it does not exist, an error is returned
+// SearchNotifications returns a page of the notifications of the user that match the given query
+func (ns *NotificationService) SearchNotifications(query domain.NotificationQuery) (domain.NotificationPage, error) {
+	page, err := ns.db.SearchNotifications(query)
+	if err != nil {
+		return domain.NotificationPage{}, newInternalError("SearchNotifications", err, "email: "+query.Email)
+	}
+
+	return page, nil
+}
+
+// GetNotification returns a single notification. If it does not exist or was deleted, an error is returned
 func (ns *NotificationService) GetNotification(notificationID string) (domain.Notification, error) {
 	operation := "GetNotification"
 	notification, err := ns.db.GetNotification(notificationID)
@@ -55,39 +109,357 @@ func (ns *NotificationService) GetNotification(notificationID string) (domain.No
 		return domain.Notification{}, newInternalError(operation, err, "notificationID: "+notificationID)
 	}
 
-	if notification == nil {
+	if notification == nil || notification.DeletedAt != nil {
 		return domain.Notification{}, newNotificationNotFoundError(operation, "notificationID: "+notificationID)
 	}
 
 	return *notification, err
 }
 
-// UpdateNotification updated the content of the given notification
-func (ns *NotificationService) UpdateNotification(updatedNotification domain.Notification) error {
+// GetRestorableNotification returns a deleted notification whose grace period is not over yet. Otherwise, an error
+// is returned
+func (ns *NotificationService) GetRestorableNotification(notificationID string) (domain.Notification, error) {
+	operation := "GetRestorableNotification"
+	notification, err := ns.db.GetNotification(notificationID)
+	if err != nil {
+		return domain.Notification{}, newInternalError(operation, err, "notificationID: "+notificationID)
+	}
+
+	if notification == nil || notification.DeletedAt == nil || time.Since(*notification.DeletedAt) > ns.gracePeriod {
+		return domain.Notification{}, newNotificationNotFoundError(operation, "deleted notificationID: "+notificationID)
+	}
+
+	return *notification, nil
+}
+
+// UpdateNotification updated the content of the given notification. The stored notification must have the same
+// version, otherwise a version conflict error is returned
+func (ns *NotificationService) UpdateNotification(updatedNotification domain.Notification, actor string) error {
 	operation := "UpdateNotification"
-	err := ns.db.UpdateNotification(updatedNotification)
+	notification, err := ns.GetNotification(updatedNotification.ID)
+	if err != nil {
+		return err
+	}
+
+	return ns.write(operation, domain.AuditUpdated, actor, notification, updatedNotification)
+}
+
+// RestoreNotification undoes the deletion of the given notification, that must be a restorable one. It returns the
+// restored notification, with its stored version
+func (ns *NotificationService) RestoreNotification(notification domain.Notification, actor string) (domain.Notification, error) {
+	restoredNotification := notification
+	restoredNotification.DeletedAt = nil
+	err := ns.write("RestoreNotification", domain.AuditRestored, actor, notification, restoredNotification)
 	if err != nil {
-		return newInternalError(operation, err, "notificationID: "+updatedNotification.ID)
+		return domain.Notification{}, err
+	}
+
+	restoredNotification.Version++
+	return restoredNotification, nil
+}
+
+// GetSchedule returns all the notifications of the given schedule. If there is none, an error is returned. Deleted
+// notifications are excluded
+func (ns *NotificationService) GetSchedule(scheduleID string) ([]domain.Notification, error) {
+	operation := "GetSchedule"
+	notifications, err := ns.db.GetNotificationsBySchedule(scheduleID)
+	if err != nil {
+		return nil, newInternalError(operation, err, "scheduleID: "+scheduleID)
+	}
+
+	notifications = withoutDeleted(notifications)
+	if len(notifications) == 0 {
+		return nil, newNotificationNotFoundError(operation, "scheduleID: "+scheduleID)
+	}
+
+	return notifications, nil
+}
+
+// UpdateSchedule updates the given notifications, that belong to the same schedule. All of them are updated or none:
+// if any stored notification does not have the same version, a version conflict error is returned
+func (ns *NotificationService) UpdateSchedule(notifications []domain.Notification, actor string) error {
+	storedNotifications := make([]domain.Notification, 0, len(notifications))
+	for idx := range notifications {
+		notification, err := ns.GetNotification(notifications[idx].ID)
+		if err != nil {
+			return err
+		}
+
+		storedNotifications = append(storedNotifications, notification)
+	}
+
+	return ns.writeAll("UpdateSchedule", domain.AuditUpdated, actor, storedNotifications, notifications)
+}
+
+// DeleteSchedule deletes the given notifications of a schedule, as they were read by the caller. All of them are
+// deleted or none: if any stored notification does not have the same version, a version conflict error is returned
+func (ns *NotificationService) DeleteSchedule(notifications []domain.Notification, actor string) error {
+	deletedAt := time.Now()
+	deletedNotifications := make([]domain.Notification, 0, len(notifications))
+	for idx := range notifications {
+		deletedNotification := notifications[idx]
+		deletedNotification.DeletedAt = &deletedAt
+		deletedNotifications = append(deletedNotifications, deletedNotification)
+	}
+
+	return ns.writeAll("DeleteSchedule", domain.AuditDeleted, actor, notifications, deletedNotifications)
+}
+
+// GetNotificationHistory returns the audit entries of the given notification, oldest first. If there is none, an
+// error is returned. The history is kept after the notification is purged
+func (ns *NotificationService) GetNotificationHistory(notificationID string) ([]domain.AuditEntry, error) {
+	operation := "GetNotificationHistory"
+	entries, err := ns.db.GetAuditEntries(notificationID)
+	if err != nil {
+		return nil, newInternalError(operation, err, "notificationID: "+notificationID)
+	}
+
+	if len(entries) == 0 {
+		return nil, newNotificationNotFoundError(operation, "notificationID: "+notificationID)
+	}
+
+	return entries, nil
+}
+
+// MarkAsSent records that the occurrence of the notification at the given slot was sent
+func (ns *NotificationService) MarkAsSent(notificationID string, slot time.Time) error {
+	err := ns.db.SetLastSent(notificationID, slot)
+	if err != nil {
+		return newInternalError("MarkAsSent", err, "notificationID: "+notificationID)
 	}
 
 	return nil
 }
 
-// DeleteNotification deletes a single notification. If it does not exist, an error is returned
-func (ns *NotificationService) DeleteNotification(notificationID string) error {
+// CompleteNotification marks the notification as completed, so it's never sent again
+func (ns *NotificationService) CompleteNotification(notificationID string, completedAt time.Time) error {
+	err := ns.db.CompleteNotification(notificationID, completedAt)
+	if err != nil {
+		return newInternalError("CompleteNotification", err, "notificationID: "+notificationID)
+	}
+
+	return nil
+}
+
+// CompleteExpiredNotifications completes the notifications that reached their end date. It returns the amount
+// of notifications completed
+func (ns *NotificationService) CompleteExpiredNotifications(now time.Time) (int, error) {
+	completed, err := ns.db.CompleteExpired(now)
+	if err != nil {
+		return 0, newInternalError("CompleteExpiredNotifications", err, "")
+	}
+
+	return completed, nil
+}
+
+// PurgeCompletedNotifications deletes the notifications completed before the given instant. It returns the amount
+// of notifications deleted
+func (ns *NotificationService) PurgeCompletedNotifications(completedBefore time.Time) (int, error) {
+	purged, err := ns.db.PurgeCompleted(completedBefore)
+	if err != nil {
+		return 0, newInternalError("PurgeCompletedNotifications", err, "completed before: "+completedBefore.Format(time.RFC3339))
+	}
+
+	return purged, nil
+}
+
+// PurgeDeletedNotifications removes the notifications deleted before the given instant, so they can't be restored
+// anymore. It returns the amount of notifications removed
+func (ns *NotificationService) PurgeDeletedNotifications(deletedBefore time.Time) (int, error) {
+	purged, err := ns.db.PurgeDeleted(deletedBefore)
+	if err != nil {
+		return 0, newInternalError("PurgeDeletedNotifications", err, "deleted before: "+deletedBefore.Format(time.RFC3339))
+	}
+
+	return purged, nil
+}
+
+// PurgeAuditEntries removes the audit entries saved before the given instant. It returns the amount of entries removed
+func (ns *NotificationService) PurgeAuditEntries(savedBefore time.Time) (int, error) {
+	purged, err := ns.db.PurgeAuditEntries(savedBefore)
+	if err != nil {
+		return 0, newInternalError("PurgeAuditEntries", err, "saved before: "+savedBefore.Format(time.RFC3339))
+	}
+
+	return purged, nil
+}
+
+// DeleteNotification deletes a single notification. If it does not exist, an error is returned. If a version other
+// than 0 is given, the stored notification must have it, otherwise a version conflict error is returned. The
+// notification can be restored during the grace period
+func (ns *NotificationService) DeleteNotification(notificationID string, version int64, actor string) error {
 	operation := "DeleteNotification"
-	deleted, err := ns.db.DeleteNotification(notificationID)
+	notification, err := ns.GetNotification(notificationID)
 	if err != nil {
-		return newInternalError(operation, err, "notificationID: "+notificationID)
+		return err
 	}
 
-	if !deleted {
-		return newNotificationNotFoundError(operation, "notificationID: "+notificationID)
+	if version != 0 {
+		notification.Version = version
+	}
+
+	return ns.softDelete(operation, notification, actor)
+}
+
+// softDelete marks the given notification as deleted
+func (ns *NotificationService) softDelete(operation string, notification domain.Notification, actor string) error {
+	deletedAt := time.Now()
+	deletedNotification := notification
+	deletedNotification.DeletedAt = &deletedAt
+	return ns.write(operation, domain.AuditDeleted, actor, notification, deletedNotification)
+}
+
+// write saves the changed notification, if the stored one has the same version, along with the record of the change
+// in the audit trail
+func (ns *NotificationService) write(operation string, action domain.AuditAction, actor string, before domain.Notification, after domain.Notification) error {
+	auditEntries, err := auditEntriesOf(action, actor, []domain.Notification{before}, []domain.Notification{after})
+	if err != nil {
+		return newInternalError(operation, err, "notificationID: "+after.ID)
+	}
+
+	updated, err := ns.db.UpdateNotification(after, auditEntries...)
+	if err != nil {
+		return newInternalError(operation, err, "notificationID: "+after.ID)
+	}
+
+	if !updated {
+		return ns.missedWriteError(operation, after.ID)
 	}
 
 	return nil
 }
 
+// writeAll saves all the changed notifications or none, if any stored one does not have the same version, along with
+// the records of the changes in the audit trail
+func (ns *NotificationService) writeAll(operation string, action domain.AuditAction, actor string, before []domain.Notification, after []domain.Notification) error {
+	notificationIDs := make([]string, 0, len(after))
+	for idx := range after {
+		notificationIDs = append(notificationIDs, after[idx].ID)
+	}
+
+	auditEntries, err := auditEntriesOf(action, actor, before, after)
+	if err != nil {
+		return newInternalError(operation, err, fmt.Sprintf("notificationIDs: %v", notificationIDs))
+	}
+
+	updated, err := ns.db.UpdateNotifications(after, auditEntries...)
+	if err != nil {
+		return newInternalError(operation, err, fmt.Sprintf("notificationIDs: %v", notificationIDs))
+	}
+
+	if !updated {
+		return ns.missedWriteError(operation, notificationIDs...)
+	}
+
+	return nil
+}
+
+// auditEntriesOf returns the audit entries of the given changes. The notifications after the change are recorded
+// with the version they are stored with
+func auditEntriesOf(action domain.AuditAction, actor string, before []domain.Notification, after []domain.Notification) ([]domain.AuditEntry, error) {
+	now := time.Now()
+	entries := make([]domain.AuditEntry, 0, len(after))
+	for idx := range after {
+		entryID, err := uuid.NewV7()
+		if err != nil {
+			return nil, err
+		}
+
+		changedNotification := after[idx]
+		changedNotification.Version++
+		entries = append(entries, domain.NewAuditEntry(entryID.String(), action, actor, &before[idx], &changedNotification, now))
+	}
+
+	return entries, nil
+}
+
+func withoutDeleted(notifications []domain.Notification) []domain.Notification {
+	var notDeleted []domain.Notification
+	for idx := range notifications {
+		if notifications[idx].DeletedAt == nil {
+			notDeleted = append(notDeleted, notifications[idx])
+		}
+	}
+
+	return notDeleted
+}
+
+// missedWriteError returns the error of a write that did not find the notifications with the expected versions: not
+// found if any of them does not exist anymore, otherwise a version conflict
+func (ns *NotificationService) missedWriteError(operation string, notificationIDs ...string) error {
+	for _, notificationID := range notificationIDs {
+		notification, err := ns.db.GetNotification(notificationID)
+		if err != nil {
+			return newInternalError(operation, err, "notificationID: "+notificationID)
+		}
+
+		if notification == nil {
+			return newNotificationNotFoundError(operation, "notificationID: "+notificationID)
+		}
+	}
+
+	return newVersionConflictError(operation, fmt.Sprintf("notificationIDs: %v", notificationIDs))
+}
+
+// GetAll returns the notifications scheduled at the given hour
 func (ns *NotificationService) GetAll(currentHour string) ([]domain.Notification, error) {
-	return ns.db.GetAll(currentHour), nil
+	notifications, err := ns.db.GetAll(currentHour)
+	if err != nil {
+		return nil, newInternalError("GetAll", err, "hour: "+currentHour)
+	}
+
+	return notifications, nil
+}
+
+// GetTimeZones returns the time zones used by the scheduled notifications
+func (ns *NotificationService) GetTimeZones() ([]string, error) {
+	timeZones, err := ns.db.GetTimeZones()
+	if err != nil {
+		return nil, newInternalError("GetTimeZones", err, "")
+	}
+
+	return timeZones, nil
+}
+
+// GetLastDispatchedSlot returns the last slot that was completely dispatched. Nil if none was dispatched yet
+func (ns *NotificationService) GetLastDispatchedSlot() (*time.Time, error) {
+	slot, err := ns.db.GetLastDispatchedSlot()
+	if err != nil {
+		return nil, newInternalError("GetLastDispatchedSlot", err, "")
+	}
+
+	return slot, nil
+}
+
+// SaveLastDispatchedSlot records the given slot as the last one that was completely dispatched
+func (ns *NotificationService) SaveLastDispatchedSlot(slot time.Time) error {
+	err := ns.db.SaveLastDispatchedSlot(slot)
+	if err != nil {
+		return newInternalError("SaveLastDispatchedSlot", err, "slot: "+slot.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// ClaimSweep takes the lease of the sweeper for this instance during the given duration. It returns true if this
+// instance has to sweep. When multiple instances share the same store, only one of them sweeps at the same time
+func (ns *NotificationService) ClaimSweep(duration time.Duration) (bool, error) {
+	claimed, err := ns.leaser.AcquireLease(sweepLeaseName, ns.instanceID, duration)
+	if err != nil {
+		return false, newInternalError("ClaimSweep", err, "lease: "+sweepLeaseName)
+	}
+
+	return claimed, nil
+}
+
+// ClaimSlot takes the lease of the given slot for this instance. It returns true if this instance is the one that has
+// to dispatch the slot. When multiple instances share the same store, only one of them can claim each slot
+func (ns *NotificationService) ClaimSlot(slot time.Time) (bool, error) {
+	leaseName := "slot:" + slot.UTC().Format(time.RFC3339)
+	claimed, err := ns.leaser.AcquireLease(leaseName, ns.instanceID, utils.SlotDuration)
+	if err != nil {
+		return false, newInternalError("ClaimSlot", err, "lease: "+leaseName)
+	}
+
+	return claimed, nil
 }
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"notification-scheduler/internal/domain"
+	"time"
+)
+
+// webhookSecretSize amount of random bytes of each webhook secret
+const webhookSecretSize = 32
+
+// RotateWebhookSecret generates a new webhook secret for the user and returns it. The previous one stops being valid
+// right away, so the webhooks sent from then on are signed with the new one
+func (ns *NotificationService) RotateWebhookSecret(email string) (domain.WebhookSecret, error) {
+	randomBytes := make([]byte, webhookSecretSize)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return domain.WebhookSecret{}, fmt.Errorf("%w: %v", errGeneratingWebhookSecret, err)
+	}
+
+	secret := domain.WebhookSecret{
+		Email:     email,
+		Secret:    hex.EncodeToString(randomBytes),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	err = ns.db.SaveWebhookSecret(secret)
+	if err != nil {
+		return domain.WebhookSecret{}, newInternalError("RotateWebhookSecret", err, "email: "+email)
+	}
+
+	return secret, nil
+}
+
+// GetWebhookSecret returns the webhook secret of the user. A not found error is returned if the user has none
+func (ns *NotificationService) GetWebhookSecret(email string) (domain.WebhookSecret, error) {
+	secret, err := ns.db.GetWebhookSecret(email)
+	if err != nil {
+		return domain.WebhookSecret{}, newInternalError("GetWebhookSecret", err, "email: "+email)
+	}
+
+	if secret == nil {
+		return domain.WebhookSecret{}, newWebhookSecretNotFoundError("GetWebhookSecret", "email: "+email)
+	}
+
+	return *secret, nil
+}
+
+// DeleteWebhookSecret deletes the webhook secret of the user. Webhooks of the user fail until a new one is generated
+func (ns *NotificationService) DeleteWebhookSecret(email string) error {
+	err := ns.db.DeleteWebhookSecret(email)
+	if err != nil {
+		return newInternalError("DeleteWebhookSecret", err, "email: "+email)
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package sweeper
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type servicer interface {
+	ClaimSweep(duration time.Duration) (bool, error)
+	CompleteExpiredNotifications(now time.Time) (int, error)
+	PurgeCompletedNotifications(completedBefore time.Time) (int, error)
+	PurgeDeletedNotifications(deletedBefore time.Time) (int, error)
+	PurgeFinishedDeliveries(finishedBefore time.Time) (int, error)
+	PurgeAuditEntries(savedBefore time.Time) (int, error)
+}
+
+// Sweeper periodically completes the notifications that reached their end date. Once a notification has been
+// completed for longer than the retention period, it's deleted. Deleted notifications are purged once their grace
+// period is over. Deliveries of the outbox are purged once they have been finished for longer than the retention
+// period. Audit entries are purged after their own retention period, if any. When multiple instances share the same
+// store, only the one that holds the sweep lease sweeps
+type Sweeper struct {
+	service        servicer
+	interval       time.Duration
+	retention      time.Duration
+	gracePeriod    time.Duration
+	auditRetention time.Duration
+	now            func() time.Time
+}
+
+// NewSweeper returns a sweeper with the given periods. A zero audit retention keeps the audit entries forever
+func NewSweeper(
+	service servicer, interval time.Duration, retention time.Duration, gracePeriod time.Duration,
+	auditRetention time.Duration,
+) *Sweeper {
+	return &Sweeper{
+		service:        service,
+		interval:       interval,
+		retention:      retention,
+		gracePeriod:    gracePeriod,
+		auditRetention: auditRetention,
+		now:            time.Now,
+	}
+}
+
+// Run blocks until the given context is done. It sweeps on start and then once per interval
+func (s *Sweeper) Run(ctx context.Context) {
+	logrus.Info("Sweeper started")
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.Sweep()
+
+		select {
+		case <-ctx.Done():
+			logrus.Info("Sweeper stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep completes the expired notifications and deletes the ones completed before the retention period, and the ones
+// deleted before the grace period. Finished deliveries older than the retention period are deleted too, and so are the
+// audit entries older than the audit retention period. Nothing is done if another instance holds the sweep lease
+func (s *Sweeper) Sweep() {
+	claimed, err := s.service.ClaimSweep(s.interval)
+	if err != nil {
+		logrus.Errorf("error claiming the sweep: %v", err)
+		return
+	}
+
+	if !claimed {
+		logrus.Info("Sweep claimed by another instance")
+		return
+	}
+
+	now := s.now()
+	completed, err := s.service.CompleteExpiredNotifications(now)
+	if err != nil {
+		logrus.Errorf("error completing expired notifications: %v", err)
+	}
+
+	purged, err := s.service.PurgeCompletedNotifications(now.Add(-s.retention))
+	if err != nil {
+		logrus.Errorf("error purging completed notifications: %v", err)
+	}
+
+	purgedDeleted, err := s.service.PurgeDeletedNotifications(now.Add(-s.gracePeriod))
+	if err != nil {
+		logrus.Errorf("error purging deleted notifications: %v", err)
+	}
+
+	purgedDeliveries, err := s.service.PurgeFinishedDeliveries(now.Add(-s.retention))
+	if err != nil {
+		logrus.Errorf("error purging finished deliveries: %v", err)
+	}
+
+	var purgedEntries int
+	if s.auditRetention > 0 {
+		purgedEntries, err = s.service.PurgeAuditEntries(now.Add(-s.auditRetention))
+		if err != nil {
+			logrus.Errorf("error purging audit entries: %v", err)
+		}
+	}
+
+	logrus.Infof("Sweep finished: %d notifications completed, %d notifications purged, %d deliveries purged, "+
+		"%d audit entries purged", completed, purged+purgedDeleted, purgedDeliveries, purgedEntries)
+}
@@ -0,0 +1,136 @@
+package sweeper
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"notification-scheduler/internal/domain"
+	"notification-scheduler/internal/notificationer/db"
+	"notification-scheduler/internal/notificationer/service"
+	"testing"
+	"time"
+)
+
+// servicerMock records the instants with which each operation is called. The sweep is claimed if claimed is true
+type servicerMock struct {
+	claimed        bool
+	claimDuration  time.Duration
+	expiredNow     []time.Time
+	completedUntil []time.Time
+	deletedUntil   []time.Time
+	finishedUntil  []time.Time
+	savedUntil     []time.Time
+}
+
+func (sm *servicerMock) ClaimSweep(duration time.Duration) (bool, error) {
+	sm.claimDuration = duration
+	return sm.claimed, nil
+}
+
+func (sm *servicerMock) CompleteExpiredNotifications(now time.Time) (int, error) {
+	sm.expiredNow = append(sm.expiredNow, now)
+	return 0, nil
+}
+
+func (sm *servicerMock) PurgeCompletedNotifications(completedBefore time.Time) (int, error) {
+	sm.completedUntil = append(sm.completedUntil, completedBefore)
+	return 0, nil
+}
+
+func (sm *servicerMock) PurgeDeletedNotifications(deletedBefore time.Time) (int, error) {
+	sm.deletedUntil = append(sm.deletedUntil, deletedBefore)
+	return 0, nil
+}
+
+func (sm *servicerMock) PurgeFinishedDeliveries(finishedBefore time.Time) (int, error) {
+	sm.finishedUntil = append(sm.finishedUntil, finishedBefore)
+	return 0, nil
+}
+
+func (sm *servicerMock) PurgeAuditEntries(savedBefore time.Time) (int, error) {
+	sm.savedUntil = append(sm.savedUntil, savedBefore)
+	return 0, nil
+}
+
+func TestSweepUsesRetentionAndGracePeriod(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	mock := &servicerMock{claimed: true}
+	sweeper := NewSweeper(mock, time.Hour, 30*24*time.Hour, 7*24*time.Hour, 365*24*time.Hour)
+	sweeper.now = func() time.Time { return now }
+
+	sweeper.Sweep()
+
+	assert.Equal(t, time.Hour, mock.claimDuration)
+	assert.Equal(t, []time.Time{now}, mock.expiredNow)
+	assert.Equal(t, []time.Time{now.AddDate(0, 0, -30)}, mock.completedUntil)
+	assert.Equal(t, []time.Time{now.AddDate(0, 0, -7)}, mock.deletedUntil)
+	assert.Equal(t, []time.Time{now.AddDate(0, 0, -30)}, mock.finishedUntil)
+	assert.Equal(t, []time.Time{now.AddDate(0, 0, -365)}, mock.savedUntil)
+}
+
+func TestSweepKeepsAuditEntriesWithoutRetention(t *testing.T) {
+	mock := &servicerMock{claimed: true}
+	NewSweeper(mock, time.Hour, time.Hour, time.Hour, 0).Sweep()
+
+	assert.Len(t, mock.finishedUntil, 1)
+	assert.Empty(t, mock.savedUntil)
+}
+
+func TestSweepDoesNothingIfClaimedByAnotherInstance(t *testing.T) {
+	mock := &servicerMock{claimed: false}
+	NewSweeper(mock, time.Hour, time.Hour, time.Hour, time.Hour).Sweep()
+
+	assert.Empty(t, mock.expiredNow)
+	assert.Empty(t, mock.completedUntil)
+	assert.Empty(t, mock.deletedUntil)
+	assert.Empty(t, mock.finishedUntil)
+	assert.Empty(t, mock.savedUntil)
+}
+
+func TestSweepCompletesAndPurgesExpiredNotifications(t *testing.T) {
+	now := time.Now()
+	endDate := now.AddDate(0, 0, -1)
+	store := db.NewFakeDB(nil)
+	notificationService := service.NewNotificationService(store, store, 0)
+	request := domain.NotificationRequest{
+		Email:     "larrycapija@testmail.com",
+		Via:       domain.Mail,
+		Message:   "give the pills to Firulais",
+		StartDate: now.AddDate(0, 0, -7),
+		EndDate:   &endDate,
+		Hours:     []string{"8:30"},
+		TimeZone:  "UTC",
+	}
+	_, err := notificationService.ScheduleNotifications(request.ToNotification(), "")
+	require.NoError(t, err)
+
+	// Another instance sharing the store holds the sweep lease, so this one does nothing
+	require.NoError(t, claimSweep(t, store, time.Hour))
+	sweeper := NewSweeper(notificationService, time.Hour, time.Hour, time.Hour, 0)
+	sweeper.Sweep()
+	notifications, err := notificationService.GetNotificationsByUserEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Nil(t, notifications[0].CompletedAt)
+
+	// A sweeper that holds its lease completes the notification and, after the retention period, purges it
+	sweeper = NewSweeper(service.NewNotificationService(store, db.NewMemoryLeaser(), 0), time.Hour, time.Hour, time.Hour, 0)
+	sweeper.Sweep()
+	notifications, err = notificationService.GetNotificationsByUserEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.NotNil(t, notifications[0].CompletedAt)
+
+	sweeper.now = func() time.Time { return now.Add(2 * time.Hour) }
+	sweeper.Sweep()
+	notifications, err = notificationService.GetNotificationsByUserEmail("larrycapija@testmail.com")
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+}
+
+// claimSweep takes the sweep lease of the store for another instance
+func claimSweep(t *testing.T, store *db.FakeDB, duration time.Duration) error {
+	t.Helper()
+	claimed, err := service.NewNotificationService(store, store, 0).ClaimSweep(duration)
+	assert.True(t, claimed)
+	return err
+}
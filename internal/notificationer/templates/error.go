@@ -0,0 +1,10 @@
+package templates
+
+import "errors"
+
+var (
+	errReadingTemplate   = errors.New("error reading template")
+	errParsingTemplate   = errors.New("error parsing template")
+	errRenderingTemplate = errors.New("error rendering template")
+	errUnknownTemplate   = errors.New("error unknown template")
+)
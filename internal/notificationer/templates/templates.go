@@ -0,0 +1,276 @@
+// Package templates renders the emails of the notifications. Each type of notification has three templates: the
+// subject, the plain text body and the HTML body, that is wrapped in a shared layout. The templates are embedded in
+// the binary, and each one can be replaced by a file with the same name in the overrides directory
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"notification-scheduler/internal/domain"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+const (
+	// Reminder template of the notifications sent on every occurrence of their schedule
+	Reminder = "reminder"
+	// OneShot template of the notifications sent only once
+	OneShot = "one_shot"
+
+	filesDir         = "files"
+	layoutFile       = "layout.html.tmpl"
+	subjectFile      = ".subject.tmpl"
+	textFile         = ".txt.tmpl"
+	htmlFile         = ".html.tmpl"
+	layoutName       = "layout"
+	maxSubjectLength = 200
+)
+
+//go:embed files/*.tmpl
+var embeddedFiles embed.FS
+
+// Data values available to the templates
+type Data struct {
+	NotificationID string
+	ScheduleID     string
+	Email          string
+	Message        string
+	// Hour when the notification is sent, HH:MM in its time zone. Empty for one-shot notifications
+	Hour     string
+	TimeZone string
+	// FireAt when a one-shot notification is sent, in its time zone
+	FireAt *time.Time
+}
+
+// NewData returns the data of the given notification
+func NewData(notification domain.Notification) Data {
+	data := Data{
+		NotificationID: notification.ID,
+		ScheduleID:     notification.ScheduleID,
+		Email:          notification.Email,
+		Message:        notification.Message,
+		TimeZone:       notification.TimeZone,
+	}
+
+	if notification.FireAt != nil {
+		fireAt := notification.FireAt.In(notification.Location())
+		data.FireAt = &fireAt
+	} else if len(notification.Hours) > 0 {
+		data.Hour = notification.Hours[0]
+	}
+
+	return data
+}
+
+// SampleData returns data to preview the template with the given name
+func SampleData(name string) Data {
+	data := Data{
+		NotificationID: "d9b1c2a4-5e7f-4a38-9c61-2f0e8b7d4a15",
+		ScheduleID:     "3f6c8e21-7b4d-4f90-a2e5-c1d8b6a9f047",
+		Email:          "larrycapija@testmail.com",
+		Message:        "Give the pills to Firulais",
+		TimeZone:       "America/Argentina/Buenos_Aires",
+	}
+
+	if name == OneShot {
+		location, err := time.LoadLocation(data.TimeZone)
+		if err != nil {
+			location = time.UTC
+		}
+		fireAt := time.Date(2024, 7, 1, 20, 30, 0, 0, location)
+		data.FireAt = &fireAt
+	} else {
+		data.Hour = "08:30"
+	}
+
+	return data
+}
+
+// NameOf returns the name of the template of the given notification
+func NameOf(notification domain.Notification) string {
+	if notification.IsOneShot() {
+		return OneShot
+	}
+
+	return Reminder
+}
+
+// Email rendered email
+type Email struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// set templates of a type of notification
+type set struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// Renderer renders the emails of the notifications. All the templates are parsed when it's created, so a broken
+// override is found at startup instead of when the first notification is sent. It's safe for concurrent use
+type Renderer struct {
+	sets map[string]set
+}
+
+// NewRenderer parses the embedded templates, replacing the ones that have a file with the same name in the given
+// directory. An empty directory means no overrides
+func NewRenderer(overridesDir string) (*Renderer, error) {
+	var overrides fs.FS
+	if overridesDir != "" {
+		overrides = os.DirFS(overridesDir)
+	}
+	files := layeredFS{overrides: overrides}
+
+	layout, err := files.read(layoutFile)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := templateNames()
+	if err != nil {
+		return nil, err
+	}
+
+	renderer := &Renderer{sets: make(map[string]set, len(names))}
+	for _, name := range names {
+		templateSet, err := parseSet(files, name, layout)
+		if err != nil {
+			return nil, err
+		}
+		renderer.sets[name] = templateSet
+	}
+
+	return renderer, nil
+}
+
+// Names returns the names of the templates, sorted
+func (r *Renderer) Names() []string {
+	names := make([]string, 0, len(r.sets))
+	for name := range r.sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Render renders the template with the given name. Line breaks are removed from the subject, and it's cut if it's
+// too long
+func (r *Renderer) Render(name string, data Data) (Email, error) {
+	templateSet, found := r.sets[name]
+	if !found {
+		return Email{}, fmt.Errorf("%w: %s", errUnknownTemplate, name)
+	}
+
+	var subject, text, html bytes.Buffer
+	err := errors.Join(
+		templateSet.subject.Execute(&subject, data),
+		templateSet.text.Execute(&text, data),
+		templateSet.html.ExecuteTemplate(&html, layoutName, data),
+	)
+	if err != nil {
+		return Email{}, fmt.Errorf("%w: %s: %v", errRenderingTemplate, name, err)
+	}
+
+	return Email{
+		Subject: cleanSubject(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// templateNames returns the names of the embedded templates, from the files of their subjects
+func templateNames() ([]string, error) {
+	entries, err := embeddedFiles.ReadDir(filesDir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errReadingTemplate, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if name, found := strings.CutSuffix(entry.Name(), subjectFile); found {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+func parseSet(files layeredFS, name string, layout string) (set, error) {
+	contents := make(map[string]string, 3)
+	for _, file := range []string{name + subjectFile, name + textFile, name + htmlFile} {
+		content, err := files.read(file)
+		if err != nil {
+			return set{}, err
+		}
+		contents[file] = content
+	}
+
+	subject, err := texttemplate.New(name + subjectFile).Option("missingkey=error").Parse(contents[name+subjectFile])
+	if err != nil {
+		return set{}, fmt.Errorf("%w: %v", errParsingTemplate, err)
+	}
+
+	text, err := texttemplate.New(name + textFile).Option("missingkey=error").Parse(contents[name+textFile])
+	if err != nil {
+		return set{}, fmt.Errorf("%w: %v", errParsingTemplate, err)
+	}
+
+	html, err := htmltemplate.New(layoutFile).Option("missingkey=error").Parse(layout)
+	if err != nil {
+		return set{}, fmt.Errorf("%w: %v", errParsingTemplate, err)
+	}
+
+	html, err = html.New(name + htmlFile).Parse(contents[name+htmlFile])
+	if err != nil {
+		return set{}, fmt.Errorf("%w: %v", errParsingTemplate, err)
+	}
+
+	return set{subject: subject, text: text, html: html}, nil
+}
+
+// cleanSubject joins the lines of the subject and cuts it to the maximum length
+func cleanSubject(subject string) string {
+	subject = strings.Join(strings.Fields(subject), " ")
+	runes := []rune(subject)
+	if len(runes) > maxSubjectLength {
+		return string(runes[:maxSubjectLength-3]) + "..."
+	}
+
+	return subject
+}
+
+// layeredFS reads the files from the overrides, if there are any, and otherwise from the embedded ones
+type layeredFS struct {
+	overrides fs.FS
+}
+
+func (l layeredFS) read(file string) (string, error) {
+	if l.overrides != nil {
+		content, err := fs.ReadFile(l.overrides, file)
+		if err == nil {
+			return string(content), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("%w: %s: %v", errReadingTemplate, file, err)
+		}
+	}
+
+	content, err := embeddedFiles.ReadFile(path.Join(filesDir, file))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", errReadingTemplate, file, err)
+	}
+
+	return string(content), nil
+}
@@ -0,0 +1,61 @@
+package templates
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"notification-scheduler/internal/domain"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	renderer, err := NewRenderer("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{OneShot, Reminder}, renderer.Names())
+
+	notification := domain.Notification{
+		Email:    "larrycapija@testmail.com",
+		Message:  "Give the pills to <b>Firulais</b>",
+		Hours:    []string{"08:30"},
+		TimeZone: "UTC",
+	}
+	email, err := renderer.Render(NameOf(notification), NewData(notification))
+	require.NoError(t, err)
+	assert.Equal(t, "Reminder: Give the pills to <b>Firulais</b>", email.Subject)
+	assert.Contains(t, email.Text, "This is your reminder of 08:30 (UTC)")
+	// The message is escaped in the HTML body
+	assert.Contains(t, email.HTML, "Give the pills to &lt;b&gt;Firulais&lt;/b&gt;")
+	assert.Contains(t, email.HTML, "Pet Place")
+
+	fireAt := time.Date(2024, 7, 1, 23, 30, 0, 0, time.UTC)
+	notification.FireAt = &fireAt
+	notification.TimeZone = "America/Argentina/Buenos_Aires"
+	email, err = renderer.Render(NameOf(notification), NewData(notification))
+	require.NoError(t, err)
+	assert.Contains(t, email.Text, "on Monday, July 1 at 20:30")
+
+	_, err = renderer.Render("birthday", SampleData("birthday"))
+	assert.ErrorIs(t, err, errUnknownTemplate)
+}
+
+func TestRenderWithOverrides(t *testing.T) {
+	overridesDir := t.TempDir()
+	subject := []byte("{{.Message}}\n for {{.Email}}")
+	require.NoError(t, os.WriteFile(filepath.Join(overridesDir, "reminder.subject.tmpl"), subject, 0o600))
+
+	renderer, err := NewRenderer(overridesDir)
+	require.NoError(t, err)
+
+	email, err := renderer.Render(Reminder, SampleData(Reminder))
+	require.NoError(t, err)
+	assert.Equal(t, "Give the pills to Firulais for larrycapija@testmail.com", email.Subject)
+	// The rest of the templates are the embedded ones
+	assert.Contains(t, email.Text, "This is your reminder of 08:30")
+
+	// Broken overrides are found when the renderer is created
+	require.NoError(t, os.WriteFile(filepath.Join(overridesDir, "one_shot.txt.tmpl"), []byte("{{.Message"), 0o600))
+	_, err = NewRenderer(overridesDir)
+	assert.ErrorIs(t, err, errParsingTemplate)
+}
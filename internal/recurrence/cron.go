@@ -0,0 +1,222 @@
+package recurrence
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	monthNames = map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}
+	weekdayNames = map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}
+	// rruleWeekdayNames names of the days of week in RRULEs, indexed by their cron value
+	rruleWeekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+)
+
+// cronRule rule defined by a standard cron expression with five fields: minute, hour, day of month, month and
+// day of week. Minute and hour define the hours of the notification, the rest of the fields define the days.
+// Like in cron, a day of month or day of week field starting with '*', like '*' or '*/2', is unrestricted
+type cronRule struct {
+	hours                []string
+	daysOfMonth          map[int]bool
+	months               map[int]bool
+	weekdays             map[int]bool
+	unrestrictedDayField bool
+}
+
+// parseCron parses a cron expression. Each field accepts '*', numbers, ranges (1-5), lists (1,15) and steps (*/2).
+// Months and days of week also accept their names (JAN, MON). Minutes can only be 0 or 30
+func parseCron(expression string) (*cronRule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields, got %d", errInvalidCron, len(fields))
+	}
+
+	minutes, err := parseCronField(fields[0], 0, 59, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: minute: %w", errInvalidCron, err)
+	}
+
+	hours, err := parseCronField(fields[1], 0, 23, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: hour: %w", errInvalidCron, err)
+	}
+
+	daysOfMonth, err := parseCronField(fields[2], 1, 31, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: day of month: %w", errInvalidCron, err)
+	}
+
+	months, err := parseCronField(fields[3], 1, 12, monthNames)
+	if err != nil {
+		return nil, fmt.Errorf("%w: month: %w", errInvalidCron, err)
+	}
+
+	weekdays, err := parseCronField(fields[4], 0, 7, weekdayNames)
+	if err != nil {
+		return nil, fmt.Errorf("%w: day of week: %w", errInvalidCron, err)
+	}
+
+	// Both 0 and 7 are Sunday
+	if weekdays[7] {
+		weekdays[0] = true
+		delete(weekdays, 7)
+	}
+
+	var scheduleHours []string
+	for hour := 0; hour < 24; hour++ {
+		if !hours[hour] {
+			continue
+		}
+		for minute := 0; minute < 60; minute++ {
+			if !minutes[minute] {
+				continue
+			}
+			if minute != 0 && minute != 30 {
+				return nil, fmt.Errorf("%w: %d", errInvalidCronMinute, minute)
+			}
+			scheduleHours = append(scheduleHours, fmt.Sprintf("%02d:%02d", hour, minute))
+		}
+	}
+
+	return &cronRule{
+		hours:                scheduleHours,
+		daysOfMonth:          daysOfMonth,
+		months:               months,
+		weekdays:             weekdays,
+		unrestrictedDayField: strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// OccursOn follows the cron semantics: if both day of month and day of week are restricted, the day matches
+// if any of them matches. Otherwise both of them must match
+func (cr *cronRule) OccursOn(day time.Time) bool {
+	if !cr.months[int(day.Month())] {
+		return false
+	}
+
+	dayOfMonthMatches := cr.daysOfMonth[day.Day()]
+	weekdayMatches := cr.weekdays[int(day.Weekday())]
+	if cr.unrestrictedDayField {
+		return dayOfMonthMatches && weekdayMatches
+	}
+
+	return dayOfMonthMatches || weekdayMatches
+}
+
+// rrules returns the days of the rule as daily RRULEs. If both day of month and day of week are restricted, the
+// first rule has the days of month and the second one the days of week, limited to the other days of month. If the
+// days of month are all of them, the first rule is enough
+func (cr *cronRule) rrules() []string {
+	rule := "FREQ=DAILY"
+	if len(cr.months) < len(monthNames) {
+		rule += ";BYMONTH=" + joinValues(cr.months, 1, 12, strconv.Itoa)
+	}
+
+	byMonthDay := ";BYMONTHDAY=" + joinValues(cr.daysOfMonth, 1, 31, strconv.Itoa)
+	byDay := ";BYDAY=" + joinValues(cr.weekdays, 0, 6, func(weekday int) string {
+		return rruleWeekdayNames[weekday]
+	})
+
+	if !cr.unrestrictedDayField {
+		otherDaysOfMonth := make(map[int]bool)
+		for day := 1; day <= 31; day++ {
+			if !cr.daysOfMonth[day] {
+				otherDaysOfMonth[day] = true
+			}
+		}
+
+		if len(otherDaysOfMonth) == 0 {
+			return []string{rule + byMonthDay}
+		}
+
+		return []string{rule + byMonthDay, rule + ";BYMONTHDAY=" + joinValues(otherDaysOfMonth, 1, 31, strconv.Itoa) + byDay}
+	}
+
+	if len(cr.daysOfMonth) < 31 {
+		rule += byMonthDay
+	}
+
+	if len(cr.weekdays) < len(weekdayNames) {
+		rule += byDay
+	}
+
+	return []string{rule}
+}
+
+// joinValues returns the values of the set from min to max, formatted and separated by commas
+func joinValues(values map[int]bool, min int, max int, format func(int) string) string {
+	var formatted []string
+	for value := min; value <= max; value++ {
+		if values[value] {
+			formatted = append(formatted, format(value))
+		}
+	}
+
+	return strings.Join(formatted, ",")
+}
+
+// parseCronField returns the set of values defined by the given cron field
+func parseCronField(field string, min int, max int, names map[string]int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("%w: invalid step %s", errInvalidCronField, part)
+			}
+		}
+
+		from, to := min, max
+		if rangePart != "*" {
+			startPart, endPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			from, err = parseCronValue(startPart, min, max, names)
+			if err != nil {
+				return nil, err
+			}
+
+			to = from
+			if isRange {
+				to, err = parseCronValue(endPart, min, max, names)
+				if err != nil {
+					return nil, err
+				}
+			} else if hasStep {
+				to = max
+			}
+
+			if from > to {
+				return nil, fmt.Errorf("%w: invalid range %s", errInvalidCronField, part)
+			}
+		}
+
+		for value := from; value <= to; value += step {
+			values[value] = true
+		}
+	}
+
+	return values, nil
+}
+
+func parseCronValue(value string, min int, max int, names map[string]int) (int, error) {
+	if namedValue, found := names[strings.ToUpper(value)]; found {
+		return namedValue, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < min || number > max {
+		return 0, fmt.Errorf("%w: %s out of range [%d, %d]", errInvalidCronField, value, min, max)
+	}
+
+	return number, nil
+}
@@ -0,0 +1,150 @@
+package recurrence
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseCronHours(t *testing.T) {
+	testCases := []struct {
+		expression string
+		expected   []string
+		wantErr    bool
+	}{
+		{expression: "0 8 * * *", expected: []string{"08:00"}},
+		{expression: "30 8,20 * * *", expected: []string{"08:30", "20:30"}},
+		{expression: "0,30 9-10 * * *", expected: []string{"09:00", "09:30", "10:00", "10:30"}},
+		{expression: "0 */6 * * *", expected: []string{"00:00", "06:00", "12:00", "18:00"}},
+		{expression: "*/30 8 * * *", expected: []string{"08:00", "08:30"}},
+		{expression: "0 20-22/2 * * *", expected: []string{"20:00", "22:00"}},
+		{expression: "15 8 * * *", wantErr: true},
+		{expression: "0 24 * * *", wantErr: true},
+		{expression: "0 8 * *", wantErr: true},
+		{expression: "0 8 * * * *", wantErr: true},
+		{expression: "0 10-8 * * *", wantErr: true},
+		{expression: "0 */0 * * *", wantErr: true},
+		{expression: "0 8 0 * *", wantErr: true},
+		{expression: "0 8 * 13 *", wantErr: true},
+		{expression: "0 8 * * 8", wantErr: true},
+		{expression: "0 8 * FOO *", wantErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.expression, func(t *testing.T) {
+			hours, err := CronHours(testCase.expression)
+			if testCase.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, hours)
+		})
+	}
+}
+
+func TestCronOccursOn(t *testing.T) {
+	// 2024-07-01 is a monday
+	monday := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name       string
+		expression string
+		day        time.Time
+		expected   bool
+	}{
+		{name: "every day", expression: "0 8 * * *", day: monday, expected: true},
+		{name: "day of week", expression: "0 8 * * 1", day: monday, expected: true},
+		{name: "other day of week", expression: "0 8 * * 2", day: monday, expected: false},
+		{name: "day of week name", expression: "0 8 * * mon", day: monday, expected: true},
+		{name: "day of week range", expression: "0 8 * * MON-FRI", day: monday.AddDate(0, 0, 4), expected: true},
+		{name: "weekend outside range", expression: "0 8 * * MON-FRI", day: monday.AddDate(0, 0, 5), expected: false},
+		{name: "sunday as 0", expression: "0 8 * * 0", day: monday.AddDate(0, 0, 6), expected: true},
+		{name: "sunday as 7", expression: "0 8 * * 7", day: monday.AddDate(0, 0, 6), expected: true},
+		{name: "day of month list", expression: "0 8 1,15 * *", day: monday.AddDate(0, 0, 14), expected: true},
+		{name: "day of month not in list", expression: "0 8 1,15 * *", day: monday.AddDate(0, 0, 1), expected: false},
+		{name: "day of month step", expression: "0 8 1-31/10 * *", day: monday.AddDate(0, 0, 10), expected: true},
+		{name: "day of month outside step", expression: "0 8 1-31/10 * *", day: monday.AddDate(0, 0, 9), expected: false},
+		{name: "month", expression: "0 8 * 7 *", day: monday, expected: true},
+		{name: "other month", expression: "0 8 * 8 *", day: monday, expected: false},
+		{name: "month name", expression: "0 8 * JUL *", day: monday, expected: true},
+		{name: "month list of names", expression: "0 8 * JAN,AUG *", day: monday, expected: false},
+		{name: "day of month or day of week, day of month", expression: "0 8 15 * MON", day: monday.AddDate(0, 0, 14),
+			expected: true},
+		{name: "day of month or day of week, day of week", expression: "0 8 15 * MON", day: monday, expected: true},
+		{name: "day of month or day of week, none", expression: "0 8 15 * MON", day: monday.AddDate(0, 0, 1),
+			expected: false},
+		{name: "day of month with star step and day of week, both", expression: "0 8 */2 * MON", day: monday,
+			expected: true},
+		{name: "day of month with star step and day of week, only day of week", expression: "0 8 */2 * MON",
+			day: monday.AddDate(0, 0, 7), expected: false},
+		{name: "day of month with star step and day of week, only day of month", expression: "0 8 */2 * MON",
+			day: monday.AddDate(0, 0, 2), expected: false},
+		{name: "day of week with star step", expression: "0 8 1 * */2", day: monday, expected: false},
+		{name: "month and day of month", expression: "0 8 1 JAN *", day: monday, expected: false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rule, err := Parse(testCase.expression, monday)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, rule.OccursOn(testCase.day))
+		})
+	}
+}
+
+func TestToCalendarRulesFromCron(t *testing.T) {
+	testCases := []struct {
+		expression string
+		expected   []CalendarRule
+	}{
+		{
+			expression: "0 8 * * *",
+			expected:   []CalendarRule{{RRule: "FREQ=DAILY", Expression: "0 8 * * *"}},
+		},
+		{
+			expression: "0 8 * 1,7 MON-FRI",
+			expected: []CalendarRule{
+				{RRule: "FREQ=DAILY;BYMONTH=1,7;BYDAY=MO,TU,WE,TH,FR", Expression: "0 8 * 1,7 MON-FRI"},
+			},
+		},
+		{
+			expression: "0 8 */10 * MON",
+			expected: []CalendarRule{
+				{RRule: "FREQ=DAILY;BYMONTHDAY=1,11,21,31;BYDAY=MO", Expression: "0 8 */10 * MON"},
+			},
+		},
+		{
+			expression: "0 8 15 * MON",
+			expected: []CalendarRule{
+				{RRule: "FREQ=DAILY;BYMONTHDAY=15", Expression: "0 8 15 * *"},
+				{
+					RRule: "FREQ=DAILY;BYMONTHDAY=1,2,3,4,5,6,7,8,9,10,11,12,13,14,16,17,18,19,20,21,22,23,24,25,26,27," +
+						"28,29,30,31;BYDAY=MO",
+					Expression: "0 8 * * MON",
+					Excluded:   "0 8 15 * *",
+				},
+			},
+		},
+		{
+			expression: "0 8 1-31 * MON",
+			expected: []CalendarRule{
+				{
+					RRule: "FREQ=DAILY;BYMONTHDAY=1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17,18,19,20,21,22,23,24,25,26,27," +
+						"28,29,30,31",
+					Expression: "0 8 1-31 * MON",
+				},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.expression, func(t *testing.T) {
+			rules, err := ToCalendarRules(testCase.expression)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, rules)
+		})
+	}
+}
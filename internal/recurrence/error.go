@@ -0,0 +1,13 @@
+package recurrence
+
+import "errors"
+
+var (
+	errInvalidCron           = errors.New("error invalid cron expression")
+	errInvalidCronField      = errors.New("error invalid cron field")
+	errInvalidCronMinute     = errors.New("error cron minutes must be 0 or 30")
+	errInvalidRRule          = errors.New("error invalid RRULE")
+	errUnsupportedRRulePart  = errors.New("error unsupported RRULE part")
+	errInvalidRRuleFrequency = errors.New("error invalid RRULE frequency")
+	errCountAndUntil         = errors.New("error RRULE cannot contain both COUNT and UNTIL")
+)
@@ -0,0 +1,98 @@
+package recurrence
+
+import (
+	"strings"
+	"time"
+)
+
+// Rule decides on which days a notification recurs. The hours of the day are defined by the notification itself
+type Rule interface {
+	// OccursOn returns true if the rule has an occurrence on the day of the given time, in its location
+	OccursOn(day time.Time) bool
+}
+
+// Parse parses the given expression, that can be a cron expression or an iCalendar RRULE. The start is the first
+// day on which the rule can have an occurrence (DTSTART), its location is the one used to evaluate the rule
+func Parse(expression string, start time.Time) (Rule, error) {
+	if IsRRule(expression) {
+		return parseRRule(expression, start)
+	}
+
+	return parseCron(expression)
+}
+
+// IsRRule returns true if the given expression is an iCalendar RRULE, otherwise is taken as a cron expression
+func IsRRule(expression string) bool {
+	expression = strings.ToUpper(strings.TrimSpace(expression))
+	return strings.HasPrefix(expression, "RRULE:") || strings.HasPrefix(expression, "FREQ=") || strings.Contains(expression, ";FREQ=")
+}
+
+// CronHours returns the hours of the day, with format HH:MM, defined by the minute and hour fields of the given
+// cron expression
+func CronHours(expression string) ([]string, error) {
+	rule, err := parseCron(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	return rule.hours, nil
+}
+
+// CalendarRule rule to share a recurrence with other iCalendar applications. RRule has no prefix 'RRULE:', and
+// Expression is an expression with the same occurrences that can be parsed by Parse, besides the days of Excluded.
+// Excluded, if set, is the expression of the days of the previous rules, that RRule leaves out
+type CalendarRule struct {
+	RRule      string
+	Expression string
+	Excluded   string
+}
+
+// ToCalendarRules returns the rules of the expression for other iCalendar applications. RRULEs are kept as they
+// are. Cron expressions are converted to daily rules limited by their days; when both day of month and day of week
+// are restricted there are two rules, one for each of them, because the parts of an RRULE must all match. The days
+// that match both are left out of the second rule, so they only occur once
+func ToCalendarRules(expression string) ([]CalendarRule, error) {
+	if IsRRule(expression) {
+		return []CalendarRule{{RRule: trimRRulePrefix(expression), Expression: expression}}, nil
+	}
+
+	rule, err := parseCron(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	rrules := rule.rrules()
+	if len(rrules) == 1 {
+		return []CalendarRule{{RRule: rrules[0], Expression: expression}}, nil
+	}
+
+	// Each rule keeps one of the restricted fields
+	byMonthDayFields := strings.Fields(expression)
+	byMonthDayFields[4] = "*"
+	byDayFields := strings.Fields(expression)
+	byDayFields[2] = "*"
+	byMonthDayExpression := strings.Join(byMonthDayFields, " ")
+	return []CalendarRule{
+		{RRule: rrules[0], Expression: byMonthDayExpression},
+		{RRule: rrules[1], Expression: strings.Join(byDayFields, " "), Excluded: byMonthDayExpression},
+	}, nil
+}
+
+// startOfDay returns the beginning of the day of the given time, in the given location
+func startOfDay(t time.Time, location *time.Location) time.Time {
+	year, month, day := t.In(location).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, location)
+}
+
+// daysBetween returns the amount of days from one day to another. Both must be the beginning of a day
+func daysBetween(from time.Time, to time.Time) int {
+	// Dates are compared in UTC so DST changes do not alter the amount of days
+	fromUTC := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toUTC := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toUTC.Sub(fromUTC).Hours() / 24)
+}
+
+// daysInMonth returns the amount of days of the month of the given day
+func daysInMonth(day time.Time) int {
+	return time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,309 @@
+package recurrence
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type frequency string
+
+const (
+	daily   frequency = "DAILY"
+	weekly  frequency = "WEEKLY"
+	monthly frequency = "MONTHLY"
+	yearly  frequency = "YEARLY"
+)
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// byDay value of the BYDAY part. Ordinal is only used with MONTHLY frequency, for example 1MO is the first
+// monday of the month and -1FR the last friday. Zero means every weekday of the period
+type byDay struct {
+	ordinal int
+	weekday time.Weekday
+}
+
+// maxCountSearchYears amount of years searched to find the last occurrence of a rule with COUNT. Rules that do not
+// reach COUNT occurrences in that time are not limited
+const maxCountSearchYears = 100
+
+// rrule subset of the iCalendar RRULE (RFC 5545). The supported parts are FREQ, INTERVAL, BYDAY, BYMONTHDAY,
+// COUNT and UNTIL. The rule works with days, COUNT limits the amount of days on which the rule occurs. Once parsed,
+// COUNT is turned into the day of the last occurrence, so it's checked like UNTIL
+type rrule struct {
+	start      time.Time
+	frequency  frequency
+	interval   int
+	byDay      []byDay
+	byMonthDay []int
+	count      int
+	until      *time.Time
+}
+
+// parseRRule parses an RRULE. The prefix 'RRULE:' is optional
+func parseRRule(expression string, start time.Time) (*rrule, error) {
+	expression = trimRRulePrefix(expression)
+	rule := &rrule{
+		start:    startOfDay(start, start.Location()),
+		interval: 1,
+	}
+
+	for _, part := range strings.Split(expression, ";") {
+		name, value, found := strings.Cut(part, "=")
+		if !found || value == "" {
+			return nil, fmt.Errorf("%w: malformed part %s", errInvalidRRule, part)
+		}
+
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rule.frequency = frequency(strings.ToUpper(value))
+		case "INTERVAL":
+			rule.interval, err = strconv.Atoi(value)
+			if err == nil && rule.interval <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "BYDAY":
+			rule.byDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			rule.byMonthDay, err = parseByMonthDay(value)
+		case "COUNT":
+			rule.count, err = strconv.Atoi(value)
+			if err == nil && rule.count <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "UNTIL":
+			var until time.Time
+			until, err = parseUntil(value, start.Location())
+			rule.until = &until
+		default:
+			return nil, fmt.Errorf("%w: %s", errUnsupportedRRulePart, name)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", errInvalidRRule, name, err)
+		}
+	}
+
+	switch rule.frequency {
+	case daily, weekly, monthly, yearly:
+	default:
+		return nil, fmt.Errorf("%w: %s", errInvalidRRuleFrequency, rule.frequency)
+	}
+
+	if rule.count > 0 && rule.until != nil {
+		return nil, errCountAndUntil
+	}
+
+	for _, dayRule := range rule.byDay {
+		if dayRule.ordinal != 0 && rule.frequency != monthly {
+			return nil, fmt.Errorf("%w: BYDAY with ordinal is only supported with MONTHLY frequency", errInvalidRRule)
+		}
+	}
+
+	if rule.count > 0 {
+		rule.until = rule.lastOccurrence()
+	}
+
+	return rule, nil
+}
+
+// lastOccurrence returns the day of the occurrence number COUNT. Nil is returned if the rule does not have that many
+// occurrences in the searched years
+func (r *rrule) lastOccurrence() *time.Time {
+	occurrences := 0
+	searchEnd := r.start.AddDate(maxCountSearchYears, 0, 0)
+	for day := r.start; day.Before(searchEnd); day = day.AddDate(0, 0, 1) {
+		if !r.matches(day) {
+			continue
+		}
+
+		occurrences++
+		if occurrences == r.count {
+			return &day
+		}
+	}
+
+	return nil
+}
+
+// trimRRulePrefix returns the RRULE without the optional prefix 'RRULE:'
+func trimRRulePrefix(expression string) string {
+	expression = strings.TrimSpace(expression)
+	if len(expression) > len("RRULE:") && strings.EqualFold(expression[:len("RRULE:")], "RRULE:") {
+		return expression[len("RRULE:"):]
+	}
+
+	return expression
+}
+
+// OccursOn returns true if the day is an occurrence of the rule, taking into account the limits set by COUNT and UNTIL
+func (r *rrule) OccursOn(day time.Time) bool {
+	day = startOfDay(day, r.start.Location())
+	if r.until != nil && day.After(*r.until) {
+		return false
+	}
+
+	return r.matches(day)
+}
+
+// matches returns true if the day belongs to the rule without taking into account COUNT and UNTIL
+func (r *rrule) matches(day time.Time) bool {
+	if day.Before(r.start) {
+		return false
+	}
+
+	if !r.inInterval(day) {
+		return false
+	}
+
+	if len(r.byDay) == 0 && len(r.byMonthDay) == 0 {
+		return r.matchesStart(day)
+	}
+
+	if len(r.byDay) > 0 && !r.matchesByDay(day) {
+		return false
+	}
+
+	return len(r.byMonthDay) == 0 || r.matchesByMonthDay(day)
+}
+
+// inInterval returns true if the period of the day is one of the periods of the rule, according to INTERVAL
+func (r *rrule) inInterval(day time.Time) bool {
+	var periods int
+	switch r.frequency {
+	case daily:
+		periods = daysBetween(r.start, day)
+	case weekly:
+		// Weeks start on monday
+		periods = daysBetween(weekStart(r.start), weekStart(day)) / 7
+	case monthly:
+		periods = (day.Year()-r.start.Year())*12 + int(day.Month()) - int(r.start.Month())
+	case yearly:
+		periods = day.Year() - r.start.Year()
+	}
+
+	return periods%r.interval == 0
+}
+
+// matchesStart is used when there are no BYDAY nor BYMONTHDAY parts, so the occurrences repeat the start day
+func (r *rrule) matchesStart(day time.Time) bool {
+	switch r.frequency {
+	case weekly:
+		return day.Weekday() == r.start.Weekday()
+	case monthly:
+		return day.Day() == r.start.Day()
+	case yearly:
+		return day.Month() == r.start.Month() && day.Day() == r.start.Day()
+	default:
+		return true
+	}
+}
+
+func (r *rrule) matchesByDay(day time.Time) bool {
+	for _, dayRule := range r.byDay {
+		if dayRule.weekday != day.Weekday() {
+			continue
+		}
+
+		if dayRule.ordinal == 0 {
+			return true
+		}
+
+		if dayRule.ordinal > 0 && (day.Day()-1)/7+1 == dayRule.ordinal {
+			return true
+		}
+
+		if dayRule.ordinal < 0 && (daysInMonth(day)-day.Day())/7+1 == -dayRule.ordinal {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *rrule) matchesByMonthDay(day time.Time) bool {
+	for _, monthDay := range r.byMonthDay {
+		if monthDay > 0 && day.Day() == monthDay {
+			return true
+		}
+
+		if monthDay < 0 && daysInMonth(day)+monthDay+1 == day.Day() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseByDay(value string) ([]byDay, error) {
+	var days []byDay
+	for _, dayValue := range strings.Split(strings.ToUpper(value), ",") {
+		if len(dayValue) < 2 {
+			return nil, fmt.Errorf("invalid day %s", dayValue)
+		}
+
+		weekday, found := rruleWeekdays[dayValue[len(dayValue)-2:]]
+		if !found {
+			return nil, fmt.Errorf("invalid day %s", dayValue)
+		}
+
+		ordinal := 0
+		if ordinalPart := dayValue[:len(dayValue)-2]; ordinalPart != "" {
+			var err error
+			ordinal, err = strconv.Atoi(ordinalPart)
+			if err != nil || ordinal == 0 || ordinal < -5 || ordinal > 5 {
+				return nil, fmt.Errorf("invalid day %s", dayValue)
+			}
+		}
+
+		days = append(days, byDay{ordinal: ordinal, weekday: weekday})
+	}
+
+	return days, nil
+}
+
+func parseByMonthDay(value string) ([]int, error) {
+	var monthDays []int
+	for _, monthDayValue := range strings.Split(value, ",") {
+		monthDay, err := strconv.Atoi(monthDayValue)
+		if err != nil || monthDay == 0 || monthDay < -31 || monthDay > 31 {
+			return nil, fmt.Errorf("invalid month day %s", monthDayValue)
+		}
+		monthDays = append(monthDays, monthDay)
+	}
+
+	return monthDays, nil
+}
+
+// parseUntil parses the UNTIL part, that can be a date (20240131) or a UTC date time (20240131T103000Z). Only the
+// day is kept, in the location of the rule
+func parseUntil(value string, location *time.Location) (time.Time, error) {
+	until, err := time.ParseInLocation("20060102", value, location)
+	if err == nil {
+		return until, nil
+	}
+
+	until, err = time.Parse("20060102T150405Z", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %s", value)
+	}
+
+	return startOfDay(until, location), nil
+}
+
+// weekStart returns the monday of the week of the given day
+func weekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
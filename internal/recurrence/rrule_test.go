@@ -0,0 +1,119 @@
+package recurrence
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRRuleOccursOn(t *testing.T) {
+	// 2024-07-01 is a monday
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name       string
+		expression string
+		day        time.Time
+		expected   bool
+	}{
+		{name: "daily", expression: "FREQ=DAILY", day: start.AddDate(0, 0, 3), expected: true},
+		{name: "before the start", expression: "FREQ=DAILY", day: start.AddDate(0, 0, -1), expected: false},
+		{name: "with prefix", expression: "RRULE:FREQ=DAILY", day: start, expected: true},
+		{name: "daily interval", expression: "FREQ=DAILY;INTERVAL=3", day: start.AddDate(0, 0, 6), expected: true},
+		{name: "daily outside interval", expression: "FREQ=DAILY;INTERVAL=3", day: start.AddDate(0, 0, 4),
+			expected: false},
+		{name: "weekly on the start weekday", expression: "FREQ=WEEKLY", day: start.AddDate(0, 0, 7), expected: true},
+		{name: "weekly on other weekday", expression: "FREQ=WEEKLY", day: start.AddDate(0, 0, 8), expected: false},
+		{name: "weekly by day", expression: "FREQ=WEEKLY;BYDAY=TU,TH", day: start.AddDate(0, 0, 3), expected: true},
+		{name: "weekly by day not listed", expression: "FREQ=WEEKLY;BYDAY=TU,TH", day: start.AddDate(0, 0, 2),
+			expected: false},
+		{name: "biweekly", expression: "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU", day: start.AddDate(0, 0, 15),
+			expected: true},
+		{name: "biweekly odd week", expression: "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU", day: start.AddDate(0, 0, 8),
+			expected: false},
+		{name: "monthly on the start day", expression: "FREQ=MONTHLY", day: start.AddDate(0, 1, 0), expected: true},
+		{name: "monthly on other day", expression: "FREQ=MONTHLY", day: start.AddDate(0, 1, 1), expected: false},
+		{name: "monthly by month day", expression: "FREQ=MONTHLY;BYMONTHDAY=15", day: start.AddDate(0, 0, 14),
+			expected: true},
+		{name: "monthly by last month day", expression: "FREQ=MONTHLY;BYMONTHDAY=-1",
+			day: time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC), expected: true},
+		{name: "monthly by last month day of february", expression: "FREQ=MONTHLY;BYMONTHDAY=-1",
+			day: time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC), expected: true},
+		{name: "monthly first monday", expression: "FREQ=MONTHLY;BYDAY=1MO",
+			day: time.Date(2024, 8, 5, 0, 0, 0, 0, time.UTC), expected: true},
+		{name: "monthly second monday is not the first", expression: "FREQ=MONTHLY;BYDAY=1MO",
+			day: time.Date(2024, 8, 12, 0, 0, 0, 0, time.UTC), expected: false},
+		{name: "monthly last friday", expression: "FREQ=MONTHLY;BYDAY=-1FR",
+			day: time.Date(2024, 8, 30, 0, 0, 0, 0, time.UTC), expected: true},
+		{name: "monthly friday that is not the last", expression: "FREQ=MONTHLY;BYDAY=-1FR",
+			day: time.Date(2024, 8, 23, 0, 0, 0, 0, time.UTC), expected: false},
+		{name: "monthly by day and month day", expression: "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13",
+			day: time.Date(2024, 9, 13, 0, 0, 0, 0, time.UTC), expected: true},
+		{name: "monthly by day and month day, only month day", expression: "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13",
+			day: time.Date(2024, 8, 13, 0, 0, 0, 0, time.UTC), expected: false},
+		{name: "monthly interval", expression: "FREQ=MONTHLY;INTERVAL=2", day: start.AddDate(0, 1, 0),
+			expected: false},
+		{name: "yearly", expression: "FREQ=YEARLY", day: start.AddDate(1, 0, 0), expected: true},
+		{name: "yearly other day", expression: "FREQ=YEARLY", day: start.AddDate(1, 0, 1), expected: false},
+		{name: "count", expression: "FREQ=DAILY;COUNT=3", day: start.AddDate(0, 0, 2), expected: true},
+		{name: "after count", expression: "FREQ=DAILY;COUNT=3", day: start.AddDate(0, 0, 3), expected: false},
+		{name: "count of weekdays", expression: "FREQ=WEEKLY;BYDAY=MO,FR;COUNT=3", day: start.AddDate(0, 0, 7),
+			expected: true},
+		{name: "after count of weekdays", expression: "FREQ=WEEKLY;BYDAY=MO,FR;COUNT=3",
+			day: start.AddDate(0, 0, 11), expected: false},
+		{name: "count never reached", expression: "FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=31;COUNT=2",
+			day: start.AddDate(0, 0, 30), expected: true},
+		{name: "until date", expression: "FREQ=DAILY;UNTIL=20240705", day: start.AddDate(0, 0, 4), expected: true},
+		{name: "after until date", expression: "FREQ=DAILY;UNTIL=20240705", day: start.AddDate(0, 0, 5),
+			expected: false},
+		{name: "until date time", expression: "FREQ=DAILY;UNTIL=20240705T103000Z", day: start.AddDate(0, 0, 4),
+			expected: true},
+		{name: "lowercase", expression: "freq=weekly;byday=mo", day: start.AddDate(0, 0, 7), expected: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rule, err := Parse(testCase.expression, start)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, rule.OccursOn(testCase.day))
+		})
+	}
+}
+
+func TestParseInvalidRRule(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []string{
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240705",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;BYHOUR=8",
+		"FREQ=DAILY;COUNT",
+	}
+
+	for _, expression := range testCases {
+		t.Run(expression, func(t *testing.T) {
+			_, err := Parse(expression, start)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRRuleInLocation(t *testing.T) {
+	location, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	// Days are taken in the location of the start: 2024-07-01 23:00 UTC is tuesday in Tokyo
+	rule, err := Parse("FREQ=WEEKLY;BYDAY=TU", time.Date(2024, 7, 1, 0, 0, 0, 0, location))
+	require.NoError(t, err)
+	assert.True(t, rule.OccursOn(time.Date(2024, 7, 1, 23, 0, 0, 0, time.UTC)))
+	assert.False(t, rule.OccursOn(time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)))
+}
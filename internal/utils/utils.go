@@ -1,11 +1,17 @@
 package utils
 
 import (
+	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
-var hoursRegex = regexp.MustCompile(`^(0?\d|1[0-9]|2[0-4]).(00|30)$`)
+// SlotDuration granularity of the scheduler. Notifications are sent on the hour or at thirty
+const SlotDuration = 30 * time.Minute
+
+var hoursRegex = regexp.MustCompile(`^([01]?\d|2[0-3]):(00|30)$`)
 
 func Contains[T comparable](targets []T, element T) bool {
 	for idx := range targets {
@@ -23,7 +29,103 @@ func ValidHour(hour string) bool {
 	return hoursRegex.MatchString(hour)
 }
 
+// NormalizeHour returns the given hour in its canonical form, HH:MM. For example, 8:30 is returned as 08:30
+func NormalizeHour(hour string) (string, error) {
+	hours, minutes, err := parseHour(hour)
+	if err != nil {
+		return "", err
+	}
+
+	return FormatHour(hours, minutes), nil
+}
+
+// FormatHour returns the canonical form, HH:MM, of the given hours and minutes
+func FormatHour(hours int, minutes int) string {
+	return fmt.Sprintf("%02d:%02d", hours, minutes)
+}
+
 // DateToString transforms the input in a string with format yyyy-mm-dd
 func DateToString(date time.Time) string {
 	return date.Format(time.DateOnly)
 }
+
+// SlotOf returns the beginning of the slot that contains the given time
+func SlotOf(t time.Time) time.Time {
+	return t.Truncate(SlotDuration)
+}
+
+// AlignedWithSlots returns true if the offsets of the given location, the one in force at the given instant and the
+// ones it changes to during the following year, are whole half hours. Otherwise, its hours don't begin a slot
+func AlignedWithSlots(location *time.Location, from time.Time) bool {
+	until := from.AddDate(1, 0, 0)
+	for instant := from.In(location); instant.Before(until); {
+		_, offset := instant.Zone()
+		if offset%int(SlotDuration.Seconds()) != 0 {
+			return false
+		}
+
+		_, end := instant.ZoneBounds()
+		if end.IsZero() {
+			return true
+		}
+		instant = end
+	}
+
+	return true
+}
+
+// LoadLocation returns the time zone with the given IANA name. If the name is empty or unknown the local
+// time zone of the server is returned
+func LoadLocation(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+
+	return location
+}
+
+// FireTime returns the instant at which the given hour happens during the day that contains the given instant,
+// both in the given location. Hours that do not exist on that day because of a DST change are moved forward
+// by the length of the change, and hours that happen twice are taken the first time
+func FireTime(instant time.Time, hour string, location *time.Location) (time.Time, error) {
+	hours, minutes, err := parseHour(hour)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	localInstant := instant.In(location)
+	year, month, day := localInstant.Date()
+	fireTime := time.Date(year, month, day, hours, minutes, 0, 0, location)
+	if fireTime.Hour() == hours && fireTime.Minute() == minutes {
+		return fireTime, nil
+	}
+
+	// The hour was skipped by the change, so it's read with the offset in force before it
+	_, offsetBefore := fireTime.Add(-24 * time.Hour).Zone()
+	return time.Date(year, month, day, hours, minutes, 0, 0, time.FixedZone("", offsetBefore)).In(location), nil
+}
+
+// parseHour returns the hours and minutes of the given hour. An hour without minutes is taken as o'clock
+func parseHour(hour string) (int, int, error) {
+	hoursPart, minutesPart, found := strings.Cut(hour, ":")
+	hours, err := strconv.Atoi(hoursPart)
+	if err != nil || hours < 0 || hours > 23 {
+		return 0, 0, fmt.Errorf("invalid hour: %s", hour)
+	}
+
+	if !found {
+		return hours, 0, nil
+	}
+
+	minutes, err := strconv.Atoi(minutesPart)
+	if err != nil || minutes < 0 || minutes > 59 {
+		return 0, 0, fmt.Errorf("invalid hour: %s", hour)
+	}
+
+	return hours, minutes, nil
+}
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestFireTime(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		instant  time.Time
+		hour     string
+		location *time.Location
+		expected time.Time
+	}{
+		{
+			name:     "standard time",
+			instant:  time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC),
+			hour:     "08:30",
+			location: newYork,
+			expected: time.Date(2024, 1, 15, 13, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "daylight saving time",
+			instant:  time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC),
+			hour:     "08:30",
+			location: newYork,
+			expected: time.Date(2024, 7, 15, 12, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "day of the location instead of the day in UTC",
+			instant:  time.Date(2024, 7, 16, 2, 0, 0, 0, time.UTC),
+			hour:     "20:00",
+			location: newYork,
+			expected: time.Date(2024, 7, 16, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "hour before the spring forward",
+			instant:  time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+			hour:     "01:30",
+			location: newYork,
+			expected: time.Date(2024, 3, 10, 6, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "hour skipped by the spring forward is moved forward",
+			instant:  time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+			hour:     "02:30",
+			location: newYork,
+			expected: time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "hour after the spring forward",
+			instant:  time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+			hour:     "03:30",
+			location: newYork,
+			expected: time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "hour repeated by the fall back is taken the first time",
+			instant:  time.Date(2024, 11, 3, 12, 0, 0, 0, time.UTC),
+			hour:     "01:30",
+			location: newYork,
+			expected: time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "hour after the fall back",
+			instant:  time.Date(2024, 11, 3, 12, 0, 0, 0, time.UTC),
+			hour:     "02:00",
+			location: newYork,
+			expected: time.Date(2024, 11, 3, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "hour skipped by the spring forward in the southern hemisphere",
+			instant:  time.Date(2024, 10, 6, 0, 0, 0, 0, time.UTC),
+			hour:     "02:00",
+			location: sydney,
+			expected: time.Date(2024, 10, 5, 16, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fireTime, err := FireTime(testCase.instant, testCase.hour, testCase.location)
+			require.NoError(t, err)
+			assert.True(t, testCase.expected.Equal(fireTime), "expected %v, got %v", testCase.expected, fireTime)
+		})
+	}
+}
+
+func TestFireTimeInvalidHour(t *testing.T) {
+	_, err := FireTime(time.Now(), "24:00", time.UTC)
+	assert.Error(t, err)
+}
+
+func TestNormalizeHour(t *testing.T) {
+	testCases := []struct {
+		hour     string
+		expected string
+		wantErr  bool
+	}{
+		{hour: "8:30", expected: "08:30"},
+		{hour: "08:30", expected: "08:30"},
+		{hour: "0:00", expected: "00:00"},
+		{hour: "23:30", expected: "23:30"},
+		{hour: "9", expected: "09:00"},
+		{hour: "24:00", wantErr: true},
+		{hour: "8:60", wantErr: true},
+		{hour: "-1:00", wantErr: true},
+		{hour: "eight", wantErr: true},
+		{hour: "", wantErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.hour, func(t *testing.T) {
+			normalized, err := NormalizeHour(testCase.hour)
+			if testCase.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, normalized)
+		})
+	}
+}
+
+func TestSlotOf(t *testing.T) {
+	assert.Equal(t, time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC), SlotOf(time.Date(2024, 7, 1, 8, 29, 59, 0, time.UTC)))
+	assert.Equal(t, time.Date(2024, 7, 1, 8, 30, 0, 0, time.UTC), SlotOf(time.Date(2024, 7, 1, 8, 30, 0, 0, time.UTC)))
+}
+
+func TestHalfHourSlotsAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// The half hours of the day before the change stay aligned with the slots after it
+	for _, hour := range []string{"0:30", "1:00", "1:30", "2:00", "2:30", "3:00", "3:30"} {
+		fireTime, err := FireTime(time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC), hour, newYork)
+		require.NoError(t, err)
+		assert.Equal(t, SlotOf(fireTime), fireTime, hour)
+	}
+}
+
+func TestAlignedWithSlots(t *testing.T) {
+	testCases := []struct {
+		timeZone string
+		expected bool
+	}{
+		{"UTC", true},
+		{"America/Argentina/Buenos_Aires", true},
+		{"America/New_York", true},
+		{"Asia/Kolkata", true},
+		{"Australia/Adelaide", true},
+		{"Australia/Lord_Howe", true},
+		{"Asia/Kathmandu", false},
+		{"Australia/Eucla", false},
+		{"Pacific/Chatham", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.timeZone, func(t *testing.T) {
+			location, err := time.LoadLocation(testCase.timeZone)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, AlignedWithSlots(location, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)))
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"notification-scheduler/src/app"
 	"os"
+	_ "time/tzdata"
 )
 
 const (
@@ -49,7 +50,9 @@ func main() {
 	notificationer.RegisterRoutes(defaultEngine)
 
 	err = notificationer.RunForrestRun(defaultEngine)
-	logrus.Error(err)
+	if err != nil {
+		logrus.Error(err)
+	}
 }
 
 // initLogger Receives the log level to be set in logrus as a string. This method
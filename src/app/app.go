@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -9,14 +10,73 @@ import (
 	"notification-scheduler/internal/domain"
 	"notification-scheduler/internal/externalservices/email"
 	"notification-scheduler/internal/externalservices/telegram"
+	"notification-scheduler/internal/externalservices/webhook"
+	"notification-scheduler/internal/notificationer/channel"
 	"notification-scheduler/internal/notificationer/db"
+	"notification-scheduler/internal/notificationer/dispatcher"
 	"notification-scheduler/internal/notificationer/handler"
 	"notification-scheduler/internal/notificationer/service"
+	"notification-scheduler/internal/notificationer/sweeper"
+	"notification-scheduler/internal/notificationer/templates"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
-const portEnv = "PORT"
+const (
+	portEnv             = "PORT"
+	defaultPort         = "8069"
+	schedulerEnabledEnv = "SCHEDULER_ENABLED"
+	catchUpWindowEnv    = "CATCH_UP_WINDOW"
+	leaseProviderEnv    = "LEASE_PROVIDER"
+	sweepIntervalEnv    = "SWEEP_INTERVAL"
+	retentionPeriodEnv  = "RETENTION_PERIOD"
+	gracePeriodEnv      = "DELETION_GRACE_PERIOD"
+	auditRetentionEnv   = "AUDIT_RETENTION_PERIOD"
+	storageEnv          = "STORAGE"
+	createTablesEnv     = "DYNAMO_CREATE_TABLES"
+	sqlitePathEnv       = "SQLITE_PATH"
+	memoryDataDirEnv    = "MEMORY_DATA_DIR"
+	snapshotIntervalEnv = "SNAPSHOT_INTERVAL"
+	outboxWorkersEnv    = "OUTBOX_WORKERS"
+	maxAttemptsEnv      = "OUTBOX_MAX_ATTEMPTS"
+	baseBackoffEnv      = "OUTBOX_BASE_BACKOFF"
+	maxBackoffEnv       = "OUTBOX_MAX_BACKOFF"
+	pollIntervalEnv     = "OUTBOX_POLL_INTERVAL"
+	telegramURLEnv      = "TELEGRAM_URL"
+	telegramChunkEnv    = "TELEGRAM_CHUNK_SIZE"
+	webhookTimeoutEnv   = "WEBHOOK_TIMEOUT"
+	mailProviderEnv     = "MAIL_PROVIDER"
+	smtpHostEnv         = "SMTP_HOST"
+	smtpPortEnv         = "SMTP_PORT"
+	smtpTLSEnv          = "SMTP_TLS"
+	smtpPoolSizeEnv     = "SMTP_POOL_SIZE"
+	smtpTimeoutEnv      = "SMTP_TIMEOUT"
+	templatesDirEnv     = "TEMPLATES_DIR"
+	shutdownTimeout     = 10 * time.Second
+
+	defaultCatchUpWindow    = 6 * time.Hour
+	defaultSweepInterval    = time.Hour
+	defaultRetentionPeriod  = 30 * 24 * time.Hour
+	defaultGracePeriod      = 7 * 24 * time.Hour
+	defaultAuditRetention   = 365 * 24 * time.Hour
+	defaultSnapshotInterval = 5 * time.Minute
+	defaultOutboxWorkers    = 4
+	defaultMaxAttempts      = 10
+	defaultBaseBackoff      = 30 * time.Second
+	defaultMaxBackoff       = time.Hour
+	defaultPollInterval     = 5 * time.Second
+
+	defaultNotificationsTable = "notifications"
+	defaultMetaTable          = "notification-scheduler-meta"
+	defaultAuditTable         = "notification-scheduler-audit"
+	defaultDeliveriesTable    = "notification-scheduler-deliveries"
+	defaultSQLitePath         = "notification-scheduler.db"
+)
 
 type appHandler interface {
 	RegisterRoutes(r *gin.Engine)
@@ -27,8 +87,21 @@ type appHandler interface {
 	DeleteNotification(c *gin.Context)
 }
 
+type emailSender interface {
+	SendEmail(mail email.Mail) error
+}
+
 type telegramHandler interface {
-	SendNotifications(notifications []domain.Notification) error
+	SendNotifications(notifications map[string]domain.Notification) map[string]error
+}
+
+// runner task that runs in background until the given context is done
+type runner interface {
+	Run(ctx context.Context)
+}
+
+type leaser interface {
+	AcquireLease(name string, owner string, ttl time.Duration) (bool, error)
 }
 
 func loadEmailConfig() (*email.EmailConfig, error) {
@@ -58,56 +131,505 @@ func loadEmailConfig() (*email.EmailConfig, error) {
 	}, nil
 }
 
+// loadSMTPConfig reads the SMTP server and how to connect to it. SMTP_USERNAME and SMTP_PASSWORD are optional, without
+// them the emails are sent without authentication
+func loadSMTPConfig() (email.SMTPConfig, error) {
+	host := os.Getenv(smtpHostEnv)
+	if host == "" {
+		return email.SMTPConfig{}, errors.New("missing smtp host")
+	}
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		return email.SMTPConfig{}, errors.New("missing from")
+	}
+
+	port := os.Getenv(smtpPortEnv)
+	if port == "" {
+		port = email.DefaultSMTPPort
+	}
+
+	tlsMode := email.TLSMode(strings.ToLower(os.Getenv(smtpTLSEnv)))
+	if tlsMode == "" {
+		tlsMode = email.StartTLS
+	}
+	if !email.ValidTLSMode(tlsMode) {
+		return email.SMTPConfig{}, fmt.Errorf("invalid %s: %s", smtpTLSEnv, tlsMode)
+	}
+
+	poolSize, err := intFromEnv(smtpPoolSizeEnv, email.DefaultPoolSize)
+	if err != nil {
+		return email.SMTPConfig{}, err
+	}
+	if poolSize <= 0 {
+		return email.SMTPConfig{}, fmt.Errorf("invalid %s: must be positive", smtpPoolSizeEnv)
+	}
+
+	timeout, err := durationFromEnv(smtpTimeoutEnv, email.DefaultSMTPTimeout)
+	if err != nil {
+		return email.SMTPConfig{}, err
+	}
+
+	return email.SMTPConfig{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+		TLS:      tlsMode,
+		PoolSize: poolSize,
+		Timeout:  timeout,
+	}, nil
+}
+
+func loadDynamoConfig() (*db.DynamoConfig, error) {
+	region := os.Getenv("DYNAMO_REGION")
+	if region == "" {
+		return nil, errors.New("missing dynamo region")
+	}
+
+	notificationsTable := os.Getenv("DYNAMO_NOTIFICATIONS_TABLE")
+	if notificationsTable == "" {
+		notificationsTable = defaultNotificationsTable
+	}
+	metaTable := os.Getenv("DYNAMO_META_TABLE")
+	if metaTable == "" {
+		metaTable = defaultMetaTable
+	}
+	auditTable := os.Getenv("DYNAMO_AUDIT_TABLE")
+	if auditTable == "" {
+		auditTable = defaultAuditTable
+	}
+	deliveriesTable := os.Getenv("DYNAMO_DELIVERIES_TABLE")
+	if deliveriesTable == "" {
+		deliveriesTable = defaultDeliveriesTable
+	}
+
+	return &db.DynamoConfig{
+		Region:             region,
+		Endpoint:           os.Getenv("DYNAMO_ENDPOINT"),
+		AccessKey:          os.Getenv("DYNAMO_ACCESS_KEY"),
+		SecretKey:          os.Getenv("DYNAMO_SECRET_KEY"),
+		NotificationsTable: notificationsTable,
+		MetaTable:          metaTable,
+		AuditTable:         auditTable,
+		DeliveriesTable:    deliveriesTable,
+	}, nil
+}
+
+// boolFromEnv reads a boolean from the given environment variable. If it's not set, the default value is returned
+func boolFromEnv(envVar string, defaultValue bool) (bool, error) {
+	value := os.Getenv(envVar)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", envVar, err)
+	}
+
+	return parsed, nil
+}
+
+// intFromEnv reads an integer from the given environment variable. If it's not set, the default value is returned
+func intFromEnv(envVar string, defaultValue int) (int, error) {
+	value := os.Getenv(envVar)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", envVar, err)
+	}
+
+	return parsed, nil
+}
+
+// durationFromEnv reads a duration, for example 6h, from the given environment variable. If it's not set, the default
+// value is returned
+func durationFromEnv(envVar string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(envVar)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", envVar, err)
+	}
+
+	return duration, nil
+}
+
+// loadTelegramConfig reads the URL of the Telegram Service and how many notifications are sent on each request
+func loadTelegramConfig() (telegram.TelegramConfig, error) {
+	url := os.Getenv(telegramURLEnv)
+	if url == "" {
+		url = telegram.DefaultURL
+	}
+
+	chunkSize, err := intFromEnv(telegramChunkEnv, telegram.DefaultChunkSize)
+	if err != nil {
+		return telegram.TelegramConfig{}, err
+	}
+	if chunkSize <= 0 {
+		return telegram.TelegramConfig{}, fmt.Errorf("invalid %s: must be positive", telegramChunkEnv)
+	}
+
+	return telegram.TelegramConfig{
+		URL:       url,
+		ChunkSize: chunkSize,
+	}, nil
+}
+
+// loadOutboxConfig reads the configuration of the outbox, every value must be positive
+func loadOutboxConfig() (dispatcher.OutboxConfig, error) {
+	workers, err := intFromEnv(outboxWorkersEnv, defaultOutboxWorkers)
+	if err != nil {
+		return dispatcher.OutboxConfig{}, err
+	}
+	maxAttempts, err := intFromEnv(maxAttemptsEnv, defaultMaxAttempts)
+	if err != nil {
+		return dispatcher.OutboxConfig{}, err
+	}
+	baseBackoff, err := durationFromEnv(baseBackoffEnv, defaultBaseBackoff)
+	if err != nil {
+		return dispatcher.OutboxConfig{}, err
+	}
+	maxBackoff, err := durationFromEnv(maxBackoffEnv, defaultMaxBackoff)
+	if err != nil {
+		return dispatcher.OutboxConfig{}, err
+	}
+	pollInterval, err := durationFromEnv(pollIntervalEnv, defaultPollInterval)
+	if err != nil {
+		return dispatcher.OutboxConfig{}, err
+	}
+
+	if workers <= 0 || maxAttempts <= 0 || baseBackoff <= 0 || maxBackoff < baseBackoff || pollInterval <= 0 {
+		return dispatcher.OutboxConfig{}, fmt.Errorf("invalid outbox configuration: values must be positive and %s "+
+			"cannot be lower than %s", maxBackoffEnv, baseBackoffEnv)
+	}
+
+	return dispatcher.OutboxConfig{
+		Workers:      workers,
+		MaxAttempts:  maxAttempts,
+		BaseBackoff:  baseBackoff,
+		MaxBackoff:   maxBackoff,
+		PollInterval: pollInterval,
+	}, nil
+}
+
+// newLeaser returns the leaser set in LEASE_PROVIDER. With 'store', the default, leases are written in the given
+// store, so replicas that share it never dispatch the same slot. With 'memory', leases are kept in this process
+func newLeaser(store leaser) (leaser, error) {
+	switch provider := os.Getenv(leaseProviderEnv); provider {
+	case "", "store":
+		return store, nil
+	case "memory":
+		return db.NewMemoryLeaser(), nil
+	default:
+		return nil, fmt.Errorf("invalid %s: %s", leaseProviderEnv, provider)
+	}
+}
+
+// newPersistor connects to DynamoDB and, if DYNAMO_CREATE_TABLES is true, creates the tables that do not exist. Then
+// the tables are migrated to the current version
+func newPersistor() (*db.Persistor, error) {
+	dynamoConfig, err := loadDynamoConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	persistor, err := db.NewPersistor(dynamoConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	createTables, err := boolFromEnv(createTablesEnv, false)
+	if err != nil {
+		return nil, err
+	}
+	if createTables {
+		err = persistor.CreateTables()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = persistor.Migrate()
+	if err != nil {
+		return nil, err
+	}
+
+	return persistor, nil
+}
+
+// newFakeDB returns the in-memory store. If MEMORY_DATA_DIR is set, the store is persisted in that directory and
+// the returned runner takes its snapshots every SNAPSHOT_INTERVAL
+func newFakeDB() (*db.FakeDB, runner, error) {
+	dataDir := os.Getenv(memoryDataDirEnv)
+	if dataDir == "" {
+		return db.NewFakeDB(nil), nil, nil
+	}
+
+	snapshotInterval, err := durationFromEnv(snapshotIntervalEnv, defaultSnapshotInterval)
+	if err != nil {
+		return nil, nil, err
+	}
+	if snapshotInterval <= 0 {
+		return nil, nil, fmt.Errorf("invalid %s: must be positive", snapshotIntervalEnv)
+	}
+
+	appDB, err := db.NewJournaledFakeDB(dataDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return appDB, db.NewSnapshotter(appDB, snapshotInterval), nil
+}
+
+// newEmailClient returns the email sender set in MAIL_PROVIDER: 'ses', the default, or 'smtp'
+func newEmailClient() (emailSender, error) {
+	switch provider := os.Getenv(mailProviderEnv); provider {
+	case "", "ses":
+		emailConfig, err := loadEmailConfig()
+		if err != nil {
+			return nil, err
+		}
+		session := email.NewAwsSession(emailConfig)
+		err = session.Connect()
+		if err != nil {
+			return nil, err
+		}
+		return &session, nil
+	case "smtp":
+		smtpConfig, err := loadSMTPConfig()
+		if err != nil {
+			return nil, err
+		}
+		return email.NewSMTPClient(smtpConfig), nil
+	default:
+		return nil, fmt.Errorf("invalid %s: %s", mailProviderEnv, provider)
+	}
+}
+
+// newNotificationService returns the service backed by the storage set in STORAGE: 'memory', the default, 'dynamo'
+// or 'sqlite'. The returned runner, if not nil, is a background task the storage needs. Deleted notifications can be
+// restored during the DELETION_GRACE_PERIOD
+func newNotificationService(gracePeriod time.Duration) (*service.NotificationService, runner, error) {
+	switch storage := os.Getenv(storageEnv); storage {
+	case "", "memory":
+		appDB, storageRunner, err := newFakeDB()
+		if err != nil {
+			return nil, nil, err
+		}
+		appLeaser, err := newLeaser(appDB)
+		if err != nil {
+			return nil, nil, err
+		}
+		return service.NewNotificationService(appDB, appLeaser, gracePeriod), storageRunner, nil
+	case "dynamo":
+		appDB, err := newPersistor()
+		if err != nil {
+			return nil, nil, err
+		}
+		appLeaser, err := newLeaser(appDB)
+		if err != nil {
+			return nil, nil, err
+		}
+		return service.NewNotificationService(appDB, appLeaser, gracePeriod), nil, nil
+	case "sqlite":
+		sqlitePath := os.Getenv(sqlitePathEnv)
+		if sqlitePath == "" {
+			sqlitePath = defaultSQLitePath
+		}
+		appDB, err := db.NewSQLiteDB(sqlitePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		appLeaser, err := newLeaser(appDB)
+		if err != nil {
+			return nil, nil, err
+		}
+		return service.NewNotificationService(appDB, appLeaser, gracePeriod), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid %s: %s", storageEnv, storage)
+	}
+}
+
 type App struct {
 	NotificationHandler appHandler
 	Telegramer          telegramHandler
+	Scheduler           runner
+	Outbox              runner
+	Sweeper             runner
+	Snapshotter         runner
 }
 
 // NewApp initializes all dependencies that App requires
 func NewApp() (*App, error) {
-	// DB
-	appDB := db.NewFakeDB(nil)
-
-	// Service
-	notificationService := service.NewNotificationService(appDB)
+	// DB and Service
+	gracePeriod, err := durationFromEnv(gracePeriodEnv, defaultGracePeriod)
+	if err != nil {
+		return nil, err
+	}
+	notificationService, snapshotter, err := newNotificationService(gracePeriod)
+	if err != nil {
+		return nil, err
+	}
 
-	// Aws Client
-	emailConfig, err := loadEmailConfig()
+	// Email Client
+	emailClient, err := newEmailClient()
 	if err != nil {
 		return nil, err
 	}
-	session := email.NewAwsSession(emailConfig)
-	err = session.Connect()
+
+	// Email templates. The files in TEMPLATES_DIR replace the embedded ones with the same name
+	renderer, err := templates.NewRenderer(os.Getenv(templatesDirEnv))
 	if err != nil {
 		return nil, err
 	}
 
 	// Telegramer
 	client := http.Client{Timeout: 5 * time.Second}
-	telegramer := telegram.NewTelegramer(client)
+	telegramConfig, err := loadTelegramConfig()
+	if err != nil {
+		return nil, err
+	}
+	telegramer := telegram.NewTelegramer(client, telegramConfig)
+
+	// Webhooker. It only posts to public addresses
+	webhookTimeout, err := durationFromEnv(webhookTimeoutEnv, webhook.DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	webhooker := webhook.NewWebhooker(webhook.WebhookConfig{Timeout: webhookTimeout})
+
+	// Channels. A notification is sent through every channel its via asks for
+	channels := channel.NewRegistry(
+		channel.NewMailChannel(emailClient, renderer),
+		channel.NewTelegramChannel(telegramer),
+		channel.NewWebhookChannel(webhooker, notificationService),
+	)
+
+	// Dispatcher
+	// How far back the slots missed while the service was down are replayed. Zero disables the catch-up
+	window, err := durationFromEnv(catchUpWindowEnv, defaultCatchUpWindow)
+	if err != nil {
+		return nil, err
+	}
+	notificationDispatcher := dispatcher.NewDispatcher(notificationService, window)
+
+	// Outbox. It sends the deliveries enqueued by the dispatcher
+	outboxConfig, err := loadOutboxConfig()
+	if err != nil {
+		return nil, err
+	}
+	// Each batch is sent on a single request of the Telegramer, well within the lease of its deliveries
+	outboxConfig.BatchSize = telegramConfig.ChunkSize
+	outbox := dispatcher.NewOutbox(notificationService, channels, outboxConfig)
 
 	// Handler
-	notificationHandler := handler.NewNotificationHandler(notificationService, &session, telegramer)
+	notificationHandler := handler.NewNotificationHandler(
+		notificationService, emailClient, notificationDispatcher, channels, renderer,
+	)
+
+	// Sweeper
+	sweepInterval, err := durationFromEnv(sweepIntervalEnv, defaultSweepInterval)
+	if err != nil {
+		return nil, err
+	}
+	retentionPeriod, err := durationFromEnv(retentionPeriodEnv, defaultRetentionPeriod)
+	if err != nil {
+		return nil, err
+	}
+	// A zero audit retention keeps the audit entries forever
+	auditRetention, err := durationFromEnv(auditRetentionEnv, defaultAuditRetention)
+	if err != nil {
+		return nil, err
+	}
 
 	// App
-	return &App{
+	app := &App{
 		NotificationHandler: notificationHandler,
 		Telegramer:          telegramer,
-	}, nil
+		Outbox:              outbox,
+		Snapshotter:         snapshotter,
+	}
+
+	// A zero sweep interval disables the sweeper
+	if sweepInterval > 0 {
+		app.Sweeper = sweeper.NewSweeper(notificationService, sweepInterval, retentionPeriod, gracePeriod, auditRetention)
+	}
+
+	// Scheduler. If disabled, notifications are only sent through the trigger endpoint
+	enabled, err := boolFromEnv(schedulerEnabledEnv, true)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		app.Scheduler = dispatcher.NewScheduler(notificationDispatcher)
+	}
+
+	return app, nil
 }
 
 func (a *App) RegisterRoutes(r *gin.Engine) {
 	a.NotificationHandler.RegisterRoutes(r)
 }
 
+// RunForrestRun starts the HTTP server, the background runners and, if enabled, the scheduler. All of them are stopped
+// when the process receives SIGINT or SIGTERM
 func (a *App) RunForrestRun(r *gin.Engine) error {
 	port := os.Getenv(portEnv)
 	if port == "" {
-		logrus.Info("Using default port (8069)")
+		logrus.Infof("Using default port (%s)", defaultPort)
+		port = defaultPort
 	}
 
-	// ToDo: add thread for ticker
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var wg sync.WaitGroup
+	for _, backgroundRunner := range []runner{a.Scheduler, a.Outbox, a.Sweeper, a.Snapshotter} {
+		if backgroundRunner == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(backgroundRunner runner) {
+			defer wg.Done()
+			backgroundRunner.Run(ctx)
+		}(backgroundRunner)
+	}
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%s", port),
+		Handler: r,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-serverErr:
+	case <-ctx.Done():
+		logrus.Info("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err = server.Shutdown(shutdownCtx)
+	}
+
+	stop()
+	wg.Wait()
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
 
-	err := r.Run(fmt.Sprintf(":%s", port))
 	return err
 }